@@ -0,0 +1,377 @@
+package go_kv
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"go-kv/data"
+	"go-kv/index"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// backupManifestFileName is the name of the manifest file of a backup, it is written last.
+const backupManifestFileName = "backup-manifest"
+
+// BackupManifest describes one backup of a chain, a full backup followed by incremental ones.
+type BackupManifest struct {
+	ID          string       `json:"id"`
+	Parent      string       `json:"parent,omitempty"` // id of the previous backup of the chain, empty for a full backup
+	CreatedAt   time.Time    `json:"created_at"`
+	IndexType   IndexType    `json:"index_type"`
+	SeqNo       uint64       `json:"seq_no"`        // transaction sequence number at backup time
+	LastFileId  uint32       `json:"last_file_id"`  // id of the last data file backed up
+	LastOffset  int64        `json:"last_offset"`   // size of the last data file, the next backup starts after it
+	MergeFileId uint32       `json:"merge_file_id"` // first data file not rewritten by the last merge, 0 if never merged
+	Files       []BackupFile `json:"files"`         // every data file of the database at backup time
+
+	dir string // directory the manifest was read from
+}
+
+// BackupFile is a data file of the database at backup time.
+type BackupFile struct {
+	FileId  uint32 `json:"file_id"`
+	Size    int64  `json:"size"`
+	Shipped bool   `json:"shipped"` // the file is in this backup, otherwise in an earlier backup of the chain
+}
+
+// backupState is what a backup reads from the database at its point in time.
+type backupState struct {
+	fileIds        []uint32 // sealed data files in ascending order
+	seqNo          uint64
+	mergeFileId    uint32
+	familyFileSize int64                               // bytes of the column family catalog written so far
+	indexes        map[uint32]*index.BPlusTreeSnapshot // B+Tree indexes by column family id, the default one included
+}
+
+// close ends the index snapshots of the backup.
+func (s *backupState) close() {
+	for _, snapshot := range s.indexes {
+		_ = snapshot.Close()
+	}
+}
+
+// Backup writes a full copy of the database to destDir while the database keeps serving reads and writes.
+// The copy holds every write made before Backup was called and none made after it:
+// the active data file is sealed, then the sealed data files are hard-linked into destDir,
+// or copied if they cannot be linked, for example because destDir is on another file system.
+// destDir must not exist or be empty, the result is a data directory Open can use directly
+// and the start of a chain of incremental backups.
+// Merge returns ErrBackupIsProgress while a backup runs. On error destDir may hold a partial backup.
+func (db *DB) Backup(destDir string) error {
+	return db.backup(destDir, nil)
+}
+
+// IncrementalBackup writes the data files sealed or rewritten by Merge since the backup of the parent manifest
+// to destDir, together with the small files that change in place. Open cannot use destDir directly,
+// Restore rebuilds a data directory from the manifests of the chain.
+// It returns ErrBackupChainBroken if parent was not written by a backup of a database with the same index type.
+func (db *DB) IncrementalBackup(destDir string, parent *BackupManifest) error {
+	if parent == nil || parent.IndexType != db.options.IndexType {
+		return ErrBackupChainBroken
+	}
+	return db.backup(destDir, parent)
+}
+
+// backup writes a full backup, or an incremental one if parent is not nil.
+func (db *DB) backup(destDir string, parent *BackupManifest) error {
+	// sealing the active data file changes the database
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := checkBackupDir(destDir); err != nil {
+		return err
+	}
+
+	state, err := db.beginBackup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		state.close()
+		db.mut.Lock()
+		db.isBackingUp = false
+		db.mut.Unlock()
+	}()
+	if err = db.writeBackupState(destDir, parent, state); err != nil {
+		return err
+	}
+
+	manifest := &BackupManifest{
+		ID:          newBackupId(),
+		CreatedAt:   time.Now(),
+		IndexType:   db.options.IndexType,
+		SeqNo:       state.seqNo,
+		MergeFileId: state.mergeFileId,
+	}
+	parentSizes := make(map[uint32]int64)
+	if parent != nil {
+		manifest.Parent = parent.ID
+		for _, file := range parent.Files {
+			parentSizes[file.FileId] = file.Size
+		}
+	}
+	// a merge since the parent rewrote the data files before its merge file id
+	merged := parent != nil && parent.MergeFileId != state.mergeFileId
+
+	// sealed data files are never written again, the last one is copied because
+	// the backup appends to it once opened
+	for i, fileId := range state.fileIds {
+		srcPath := data.GetDataFileName(db.options.DirPath, fileId)
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return err
+		}
+		file := BackupFile{FileId: fileId, Size: info.Size()}
+		parentSize, ok := parentSizes[fileId]
+		file.Shipped = parent == nil || !ok || parentSize != file.Size || merged && fileId < state.mergeFileId
+		if file.Shipped {
+			destPath := data.GetDataFileName(destDir, fileId)
+			if i == len(state.fileIds)-1 {
+				err = copyFile(srcPath, destPath, -1)
+			} else {
+				err = linkOrCopyFile(srcPath, destPath)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		manifest.Files = append(manifest.Files, file)
+		manifest.LastFileId, manifest.LastOffset = file.FileId, file.Size
+	}
+
+	if err = writeBackupManifest(destDir, manifest); err != nil {
+		return err
+	}
+	return syncDir(destDir)
+}
+
+// beginBackup seals the active data file and takes what the backup reads at its point in time.
+// The files are copied by writeBackupState after db.mut is released.
+func (db *DB) beginBackup() (*backupState, error) {
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if db.isBackingUp {
+		return nil, ErrBackupIsProgress
+	}
+	db.isBackingUp = true
+	state, err := db.takeBackupState()
+	if err != nil {
+		db.isBackingUp = false
+		return nil, err
+	}
+	return state, nil
+}
+
+// takeBackupState seals the active data file and snapshots the B+Tree indexes.
+// Access this method needs db.mut is required.
+func (db *DB) takeBackupState() (*backupState, error) {
+	// seal the active file unless it is empty, it becomes the last file of the backup
+	if db.activeFile != nil && db.activeFile.WriteOff > 0 {
+		if err := db.syncActiveFile(); err != nil {
+			return nil, err
+		}
+		db.olderFiles[db.activeFile.FileId] = db.activeFile
+		if err := db.setActiveFile(); err != nil {
+			return nil, err
+		}
+	}
+	state := &backupState{seqNo: db.seqNo}
+	for fileId := range db.olderFiles {
+		state.fileIds = append(state.fileIds, fileId)
+	}
+	sort.Slice(state.fileIds, func(i, j int) bool {
+		return state.fileIds[i] < state.fileIds[j]
+	})
+
+	if _, err := os.Stat(filepath.Join(db.options.DirPath, data.MergeFinishedFileName)); err == nil {
+		if state.mergeFileId, err = db.getNonMergeFileId(db.options.DirPath); err != nil {
+			return nil, err
+		}
+	}
+	if db.familyFile != nil {
+		state.familyFileSize = db.familyFile.WriteOff
+	}
+
+	// B+Tree indexes are persisted and not rebuilt by Open, they are copied as of the seal
+	if db.options.IndexType == BPlusTree {
+		state.indexes = make(map[uint32]*index.BPlusTreeSnapshot)
+		indexers := map[uint32]index.Indexer{defaultFamilyId: db.index}
+		for id, cf := range db.families {
+			indexers[id] = cf.index
+		}
+		for id, indexer := range indexers {
+			snapshot, err := indexer.(*index.BPlusTree).Snapshot()
+			if err != nil {
+				state.close()
+				return nil, err
+			}
+			state.indexes[id] = snapshot
+		}
+	}
+	return state, nil
+}
+
+// writeBackupState writes the files that change while the database is open to destDir, as of the backup state.
+func (db *DB) writeBackupState(destDir string, parent *BackupManifest, state *backupState) error {
+	// hint file and merge finished file of the last merge are only replaced by Open
+	if parent == nil || parent.MergeFileId != state.mergeFileId {
+		for _, name := range []string{data.HintFileName, data.MergeFinishedFileName} {
+			srcPath := filepath.Join(db.options.DirPath, name)
+			if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+				continue
+			}
+			if err := copyFile(srcPath, filepath.Join(destDir, name), -1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeSeqNoFile(destDir, state.seqNo); err != nil {
+		return err
+	}
+
+	// the column family catalog is appended to, copy the records written before the seal
+	if state.familyFileSize > 0 {
+		srcPath := filepath.Join(db.options.DirPath, data.ColumnFamilyFileName)
+		if err := copyFile(srcPath, filepath.Join(destDir, data.ColumnFamilyFileName), state.familyFileSize); err != nil {
+			return err
+		}
+	}
+
+	for id, snapshot := range state.indexes {
+		indexDir := destDir
+		if id != defaultFamilyId {
+			indexDir = filepath.Join(destDir, familyDirName(id))
+			if err := os.MkdirAll(indexDir, os.ModePerm); err != nil {
+				return err
+			}
+		}
+		if err := snapshot.CopyTo(indexDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadBackupManifest reads the manifest of the backup in the given directory.
+// A backup without a manifest did not finish.
+func ReadBackupManifest(dirPath string) (*BackupManifest, error) {
+	buf, err := os.ReadFile(filepath.Join(dirPath, backupManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &BackupManifest{dir: dirPath}
+	if err = json.Unmarshal(buf, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeBackupManifest writes the manifest file of a backup and syncs it.
+func writeBackupManifest(dirPath string, manifest *BackupManifest) error {
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dirPath, backupManifestFileName), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = file.Write(buf); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// newBackupId returns a random id for a backup manifest.
+func newBackupId() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// checkBackupDir creates the backup directory if it does not exist.
+// It returns ErrBackupDirNotEmpty if the directory holds any file.
+func checkBackupDir(dirPath string) error {
+	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrBackupDirNotEmpty
+	}
+	return nil
+}
+
+// writeSeqNoFile writes the transaction sequence number to the seqNoFile of the given directory.
+func writeSeqNoFile(dirPath string, seqNo uint64) error {
+	seqNoFile, err := data.OpenSeqNoFile(dirPath)
+	if err != nil {
+		return err
+	}
+	defer seqNoFile.Close()
+
+	record := &data.LogRecord{
+		Key:   []byte(SeqNoKey),
+		Value: []byte(strconv.FormatUint(seqNo, 10)),
+	}
+	encodeLogRecord, _ := data.EncodeLogRecord(record)
+	if err = seqNoFile.Write(encodeLogRecord); err != nil {
+		return err
+	}
+	return seqNoFile.Sync()
+}
+
+// linkOrCopyFile hard-links srcPath to destPath, or copies it if the link fails.
+func linkOrCopyFile(srcPath, destPath string) error {
+	if err := os.Link(srcPath, destPath); err == nil {
+		return nil
+	}
+	return copyFile(srcPath, destPath, -1)
+}
+
+// copyFile copies the first size bytes of srcPath to the new file destPath and syncs it,
+// a negative size copies the whole file.
+func copyFile(srcPath, destPath string, size int64) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	if size < 0 {
+		_, err = io.Copy(dest, src)
+	} else {
+		_, err = io.CopyN(dest, src, size)
+	}
+	if err != nil {
+		return err
+	}
+	return dest.Sync()
+}
+
+// syncDir flushes the entries of a directory to disk.
+func syncDir(dirPath string) error {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
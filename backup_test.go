@@ -0,0 +1,537 @@
+package go_kv
+
+import (
+	"errors"
+	"fmt"
+	"go-kv/data"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// familyPairs returns the key-value pairs of a column family, or of the default one if cf is nil.
+func familyPairs(t *testing.T, db *DB, cf *ColumnFamily) map[string]string {
+	var it *Iterator
+	if cf == nil {
+		it = db.NewIterator(DefaultIteratorOptions)
+	} else {
+		it = cf.NewIterator(DefaultIteratorOptions)
+	}
+	defer it.Close()
+
+	pairs := make(map[string]string)
+	for ; it.Valid(); it.Next() {
+		value, err := it.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs[string(it.Key())] = string(value)
+	}
+	return pairs
+}
+
+func TestDB_Backup(t *testing.T) {
+	tests := []struct {
+		name      string
+		indexType IndexType
+	}{
+		{name: "btree", indexType: Btree},
+		{name: "b+tree", indexType: BPlusTree},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions
+			opts.DirPath, _ = os.MkdirTemp("", "bitcask-go-backup")
+			opts.DataFileSize = 4 * 1024
+			opts.IndexType = tt.indexType
+			db, err := Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer destroyDB(db)
+
+			// several data files, a column family and a transaction
+			want := make(map[string]string)
+			for i := 0; i < 200; i++ {
+				key, value := fmt.Sprintf("key-%03d", i), fmt.Sprintf("value-%03d", i)
+				_ = db.Put([]byte(key), []byte(value))
+				want[key] = value
+			}
+			_ = db.Delete([]byte("key-000"))
+			delete(want, "key-000")
+			users, err := db.CreateColumnFamily("users")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = users.Put([]byte("1"), []byte("alice"))
+			wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+			_ = wb.Put([]byte("tx"), []byte("committed"))
+			if err = wb.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			want["tx"] = "committed"
+
+			backupDir := filepath.Join(opts.DirPath+"-backup", "nested")
+			defer os.RemoveAll(opts.DirPath + "-backup")
+			if err = db.Backup(backupDir); err != nil {
+				t.Fatalf("Backup() error = %v", err)
+			}
+			if err = db.Backup(backupDir); !errors.Is(err, ErrBackupDirNotEmpty) {
+				t.Errorf("Backup() error = %v, wantErr %v", err, ErrBackupDirNotEmpty)
+			}
+
+			// writes after the backup are not part of it
+			_ = db.Put([]byte("after"), []byte("backup"))
+			_ = users.Put([]byte("2"), []byte("bob"))
+
+			backupOpts := opts
+			backupOpts.DirPath = backupDir
+			backup, err := Open(backupOpts)
+			if err != nil {
+				t.Fatalf("Open() backup error = %v", err)
+			}
+			if got := familyPairs(t, backup, nil); !reflect.DeepEqual(got, want) {
+				t.Errorf("backup pairs = %d pairs, want %d pairs", len(got), len(want))
+			}
+			backupUsers, err := backup.ColumnFamily("users")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := familyPairs(t, backup, backupUsers), map[string]string{"1": "alice"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("backup users = %q, want %q", got, want)
+			}
+
+			// the backup is a database of its own, writing to it leaves the source alone
+			for i := 0; i < 100; i++ {
+				_ = backup.Put([]byte(fmt.Sprintf("key-%03d", i)), []byte("overwritten"))
+			}
+			wb = backup.NewWriteBatch(DefaultWriteBatchOptions)
+			_ = wb.Put([]byte("tx"), []byte("backup"))
+			if err = wb.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			if err = backup.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want["after"] = "backup"
+			if got := familyPairs(t, db, nil); !reflect.DeepEqual(got, want) {
+				t.Errorf("source pairs changed by writes to the backup")
+			}
+		})
+	}
+}
+
+func TestDB_Backup_Merge(t *testing.T) {
+	db, opts := openColumnFamilyTestDB(t)
+	_ = db.Put([]byte("a"), []byte("1"))
+	_ = db.Put([]byte("a"), []byte("2"))
+
+	// merge and a second backup are refused while a backup runs
+	db.isBackingUp = true
+	if err := db.Merge(); !errors.Is(err, ErrBackupIsProgress) {
+		t.Errorf("Merge() error = %v, wantErr %v", err, ErrBackupIsProgress)
+	}
+	if err := db.Backup(opts.DirPath + "-backup"); !errors.Is(err, ErrBackupIsProgress) {
+		t.Errorf("Backup() error = %v, wantErr %v", err, ErrBackupIsProgress)
+	}
+	db.isBackingUp = false
+
+	// a merged database is backed up with its hint file
+	if err := db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+	backupDir := opts.DirPath + "-backup"
+	defer os.RemoveAll(backupDir)
+	if err = db.Backup(backupDir); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(backupDir, data.HintFileName)); err != nil {
+		t.Errorf("hint file not backed up: %v", err)
+	}
+	opts.DirPath = backupDir
+	backup, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	if value, err := backup.Get([]byte("a")); err != nil || string(value) != "2" {
+		t.Errorf("Get() = %q, %v, want %q, nil", value, err, "2")
+	}
+}
+
+func TestDB_Backup_ConcurrentPuts(t *testing.T) {
+	opts := DefaultOptions
+	opts.DirPath, _ = os.MkdirTemp("", "bitcask-go-backup-puts")
+	opts.DataFileSize = 4 * 1024
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+
+	// writers keep putting keys while the active file is sealed
+	const writers = 4
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := db.Put([]byte(fmt.Sprintf("%d-%06d", w, i)), []byte("value")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	backupDir := opts.DirPath + "-backup"
+	defer os.RemoveAll(backupDir)
+	err = db.Backup(backupDir)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	// the persisted index of the backup holds exactly the keys of its data files
+	pairs := make(map[IndexType]map[string]string)
+	for _, indexType := range []IndexType{BPlusTree, Btree} {
+		backupOpts := opts
+		backupOpts.DirPath = backupDir
+		backupOpts.IndexType = indexType
+		backupOpts.ReadOnly = true
+		backup, err := Open(backupOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs[indexType] = familyPairs(t, backup, nil)
+		if err = backup.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pairs[Btree]) == 0 {
+		t.Fatal("backup holds no keys")
+	}
+	if !reflect.DeepEqual(pairs[BPlusTree], pairs[Btree]) {
+		t.Errorf("backup index = %d keys, want the %d keys of its data files", len(pairs[BPlusTree]), len(pairs[Btree]))
+	}
+}
+
+func TestDB_IncrementalBackup_Restore(t *testing.T) {
+	db, opts := openColumnFamilyTestDB(t)
+	opts.DataFileSize = 4 * 1024
+	_ = db.Close()
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupRoot, _ := os.MkdirTemp("", "bitcask-go-backup-chain")
+	defer os.RemoveAll(backupRoot)
+
+	put := func(from, to int, value string) {
+		for i := from; i < to; i++ {
+			_ = db.Put([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("%s-%032d", value, i)))
+		}
+	}
+	backup := func(name string, parent *BackupManifest) *BackupManifest {
+		dir := filepath.Join(backupRoot, name)
+		if parent == nil {
+			err = db.Backup(dir)
+		} else {
+			err = db.IncrementalBackup(dir, parent)
+		}
+		if err != nil {
+			t.Fatalf("backup %s error = %v", name, err)
+		}
+		manifest, err := ReadBackupManifest(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return manifest
+	}
+	shipped := func(m *BackupManifest) (n int) {
+		for _, file := range m.Files {
+			if file.Shipped {
+				n++
+			}
+		}
+		return n
+	}
+
+	put(0, 200, "full")
+	full := backup("full", nil)
+	if shipped(full) != len(full.Files) || len(full.Files) < 2 {
+		t.Fatalf("full backup shipped %d of %d files", shipped(full), len(full.Files))
+	}
+
+	// only the data files sealed since the full backup are shipped
+	put(200, 300, "inc1")
+	inc1 := backup("inc1", full)
+	if shipped(inc1) == 0 || shipped(inc1) == len(inc1.Files) {
+		t.Errorf("incremental backup shipped %d of %d files", shipped(inc1), len(inc1.Files))
+	}
+	if inc1.Parent != full.ID || inc1.LastFileId <= full.LastFileId {
+		t.Errorf("incremental manifest = %+v, want to follow %+v", inc1, full)
+	}
+
+	// a merge rewrites the older data files, they are shipped again
+	put(0, 150, "inc2")
+	if err = db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	if db, err = Open(opts); err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+	put(300, 310, "inc2")
+	inc2 := backup("inc2", inc1)
+	if inc2.MergeFileId == 0 {
+		t.Errorf("incremental backup after merge has no merge file id")
+	}
+	want := familyPairs(t, db, nil)
+
+	// writes after the last backup are not restored
+	put(0, 10, "after")
+
+	restoreDir := filepath.Join(backupRoot, "restore")
+	if err = Restore([]*BackupManifest{full, inc1, inc2}, restoreDir); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	restoreOpts := opts
+	restoreOpts.DirPath = restoreDir
+	restored, err := Open(restoreOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if got := familyPairs(t, restored, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %d pairs, want %d pairs", len(got), len(want))
+	}
+
+	// restoring up to an earlier backup of the chain
+	restoreOpts.DirPath = filepath.Join(backupRoot, "restore-inc1")
+	if err = Restore([]*BackupManifest{full, inc1}, restoreOpts.DirPath); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	restoredInc1, err := Open(restoreOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restoredInc1.Close()
+	if got := familyPairs(t, restoredInc1, nil); len(got) != 300 || got["key-000"] != fmt.Sprintf("full-%032d", 0) {
+		t.Errorf("restored %d pairs with key-000 = %q, want 300 pairs from before the merge", len(got), got["key-000"])
+	}
+
+	brokenChains := map[string][]*BackupManifest{
+		"empty":          nil,
+		"no full backup": {inc1, inc2},
+		"missing link":   {full, inc2},
+	}
+	for name, chain := range brokenChains {
+		if err = Restore(chain, filepath.Join(backupRoot, "broken")); !errors.Is(err, ErrBackupChainBroken) {
+			t.Errorf("Restore(%s) error = %v, wantErr %v", name, err, ErrBackupChainBroken)
+		}
+	}
+}
+
+func TestRestoreToPoint(t *testing.T) {
+	db, opts := openColumnFamilyTestDB(t)
+	opts.DataFileSize = 4 * 1024
+	_ = db.Close()
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreRoot, _ := os.MkdirTemp("", "bitcask-go-restore-point")
+	defer os.RemoveAll(restoreRoot)
+
+	put := func(from, to int, value string) {
+		wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+		for i := from; i < to; i++ {
+			_ = db.Put([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("%s-%032d", value, i)))
+			_ = wb.Put([]byte(fmt.Sprintf("tx-%03d", i)), []byte(value))
+		}
+		if err := wb.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// timestamps are in milliseconds, keep the phases apart
+	pause := func() time.Time {
+		time.Sleep(5 * time.Millisecond)
+		now := time.Now()
+		time.Sleep(5 * time.Millisecond)
+		return now
+	}
+
+	put(0, 100, "v1")
+	seqNo1, want1 := db.seqNo, familyPairs(t, db, nil)
+	time1 := pause()
+	_ = db.Delete([]byte("key-099"))
+	users, _ := db.CreateColumnFamily("users")
+	_ = users.Put([]byte("1"), []byte("alice"))
+	put(0, 50, "v2")
+	seqNo2, want2 := db.seqNo, familyPairs(t, db, nil)
+	pause()
+	// the bad deploy
+	put(0, 100, "bad")
+
+	tests := []struct {
+		name      string
+		point     RestorePoint
+		indexType IndexType
+		want      map[string]string
+		wantUsers bool
+		wantErr   error
+	}{
+		{name: "time", point: RestorePoint{Time: time1}, indexType: Btree, want: want1},
+		{name: "time b+tree", point: RestorePoint{Time: time1}, indexType: BPlusTree, want: want1},
+		{name: "sequence number", point: RestorePoint{SeqNo: seqNo2}, indexType: Btree, want: want2, wantUsers: true},
+		{name: "sequence number b+tree", point: RestorePoint{SeqNo: seqNo2}, indexType: BPlusTree, want: want2, wantUsers: true},
+		{name: "first point wins", point: RestorePoint{Time: time.Now(), SeqNo: seqNo1}, indexType: Btree, want: want1},
+		{name: "unknown sequence number", point: RestorePoint{SeqNo: 1000}, indexType: Btree, wantErr: ErrRestorePointNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreOpts := opts
+			restoreOpts.DirPath = filepath.Join(restoreRoot, tt.name)
+			restoreOpts.IndexType = tt.indexType
+			err := db.RestoreToPoint(tt.point, restoreOpts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RestoreToPoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			restored, err := Open(restoreOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer restored.Close()
+			if got := familyPairs(t, restored, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restored %d pairs, want %d pairs", len(got), len(tt.want))
+			}
+			restoredUsers, err := restored.ColumnFamily("users")
+			if (err == nil) != tt.wantUsers {
+				t.Fatalf("ColumnFamily() error = %v, want users column family %v", err, tt.wantUsers)
+			}
+			if tt.wantUsers {
+				if value, err := restoredUsers.Get([]byte("1")); err != nil || string(value) != "alice" {
+					t.Errorf("Get() = %q, %v, want %q, nil", value, err, "alice")
+				}
+			}
+			// the restored database has a sequence number file for write batches
+			if err = restored.NewWriteBatch(DefaultWriteBatchOptions).Put([]byte("new"), []byte("1")); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	// a merge drops the records older points need
+	if err = db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	if db, err = Open(opts); err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+	if err = db.RestoreToPoint(RestorePoint{Time: time1}, opts); !errors.Is(err, ErrRestorePointMerged) {
+		t.Errorf("RestoreToPoint() error = %v, wantErr %v", err, ErrRestorePointMerged)
+	}
+	want := familyPairs(t, db, nil)
+	restoreOpts := opts
+	restoreOpts.DirPath = filepath.Join(restoreRoot, "after merge")
+	if err = db.RestoreToPoint(RestorePoint{Time: time.Now()}, restoreOpts); err != nil {
+		t.Fatalf("RestoreToPoint() error = %v", err)
+	}
+	restored, err := Open(restoreOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if got := familyPairs(t, restored, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %d pairs after merge, want %d pairs", len(got), len(want))
+	}
+
+	// a commit written after the merge without timestamps, as records were before they carried one
+	db.mut.Lock()
+	db.seqNo++
+	legacySeqNo := db.seqNo
+	for _, record := range []*data.LogRecord{
+		{Key: logRecordKeyWithSeq([]byte("legacy"), legacySeqNo), Value: []byte("1"), Type: data.LogRecordNormal},
+		{Key: logRecordKeyWithSeq(txFinKey, legacySeqNo), Type: data.LogRecordTxFinished},
+	} {
+		encRecord, _ := data.EncodeLogRecord(record)
+		if err = db.activeFile.Write(encRecord); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.mut.Unlock()
+	restoreOpts.DirPath = filepath.Join(restoreRoot, "legacy commit")
+	if err = db.RestoreToPoint(RestorePoint{SeqNo: legacySeqNo}, restoreOpts); err != nil {
+		t.Fatalf("RestoreToPoint() error = %v", err)
+	}
+	legacy, err := Open(restoreOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer legacy.Close()
+	if value, err := legacy.Get([]byte("legacy")); err != nil || string(value) != "1" {
+		t.Errorf("Get() = %q, %v, want %q, nil", value, err, "1")
+	}
+	if _, err = legacy.ColumnFamily("users"); err != nil {
+		t.Errorf("ColumnFamily() error = %v, want the users column family", err)
+	}
+
+	// half of a record being appended to the active data file is not read
+	encRecord, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq([]byte("torn"), nonTransactionalSeqNo),
+		Value: []byte("value"),
+		Type:  data.LogRecordNormal,
+	})
+	activeFile, err := os.OpenFile(data.GetDataFileName(opts.DirPath, db.activeFile.FileId), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = activeFile.Write(encRecord[:len(encRecord)/2])
+	_ = activeFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want["legacy"] = "1"
+	restoreOpts.DirPath = filepath.Join(restoreRoot, "torn")
+	if err = db.RestoreToPoint(RestorePoint{}, restoreOpts); err != nil {
+		t.Fatalf("RestoreToPoint() error = %v", err)
+	}
+	torn, err := Open(restoreOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer torn.Close()
+	if got := familyPairs(t, torn, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %d pairs with a torn record, want %d pairs", len(got), len(want))
+	}
+	if err = RestoreToPoint(opts.DirPath, RestorePoint{}, restoreOpts); err == nil {
+		t.Errorf("RestoreToPoint() error = nil, want the torn record read from the directory")
+	}
+}
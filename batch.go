@@ -3,6 +3,7 @@ package go_kv
 import (
 	"encoding/binary"
 	"go-kv/data"
+	"go-kv/index"
 	"sync"
 	"sync/atomic"
 )
@@ -15,13 +16,14 @@ type WriteBatch struct {
 	options       WriteBatchOptions
 	mu            *sync.Mutex
 	db            *DB
-	pendingWrites map[string]*data.LogRecord // key -> log record to be written to disk
+	pendingWrites map[string]*data.LogRecord // column family and key -> log record to be written to disk
 }
 
 // NewWriteBatch creates a new WriteBatch object with the given options.
+// It panics if CheckWriteBatch returns an error.
 func (db *DB) NewWriteBatch(options WriteBatchOptions) *WriteBatch {
-	if db.options.IndexType == BPlusTree && !db.seqNoFileExists && !db.isInitial {
-		panic("sequence number file not found, cannot create write batch")
+	if err := db.CheckWriteBatch(); err != nil {
+		panic(err.Error())
 	}
 
 	return &WriteBatch{
@@ -32,8 +34,27 @@ func (db *DB) NewWriteBatch(options WriteBatchOptions) *WriteBatch {
 	}
 }
 
+// CheckWriteBatch returns ErrSeqNoFileNotFound if no WriteBatch can be created.
+// That is the case for a B+Tree database that was not closed, its sequence number file is missing,
+// so the sequence number of the next transaction is unknown.
+func (db *DB) CheckWriteBatch() error {
+	if db.options.IndexType == BPlusTree && !db.seqNoFileExists && !db.isInitial {
+		return ErrSeqNoFileNotFound
+	}
+	return nil
+}
+
 // Put adds a key-value pair to the WriteBatch.
 func (wb *WriteBatch) Put(key, value []byte) error {
+	return wb.put(defaultFamilyId, key, value)
+}
+
+// PutCF adds a key-value pair of the given column family to the WriteBatch.
+func (wb *WriteBatch) PutCF(cf *ColumnFamily, key, value []byte) error {
+	return wb.put(cf.id, key, value)
+}
+
+func (wb *WriteBatch) put(family uint32, key, value []byte) error {
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
@@ -42,15 +63,31 @@ func (wb *WriteBatch) Put(key, value []byte) error {
 	defer wb.mu.Unlock()
 
 	// temporary storage for the log record
-	wb.pendingWrites[string(key)] = &data.LogRecord{
-		Key:   key,
-		Value: value,
+	wb.pendingWrites[pendingWriteKey(family, key)] = &data.LogRecord{
+		Key:    key,
+		Value:  value,
+		Family: family,
 	}
 	return nil
 }
 
 // Delete adds a delete operation to the WriteBatch.
 func (wb *WriteBatch) Delete(key []byte) error {
+	return wb.delete(defaultFamilyId, wb.db.index, key)
+}
+
+// DeleteCF adds a delete operation of the given column family to the WriteBatch.
+func (wb *WriteBatch) DeleteCF(cf *ColumnFamily, key []byte) error {
+	wb.db.mut.RLock()
+	dropped := cf.dropped
+	wb.db.mut.RUnlock()
+	if dropped {
+		return ErrColumnFamilyDropped
+	}
+	return wb.delete(cf.id, cf.index, key)
+}
+
+func (wb *WriteBatch) delete(family uint32, indexer index.Indexer, key []byte) error {
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
@@ -59,12 +96,13 @@ func (wb *WriteBatch) Delete(key []byte) error {
 	defer wb.mu.Unlock()
 
 	// check if the key exists in the index
-	logRecordPos := wb.db.index.Get(key)
+	pendingKey := pendingWriteKey(family, key)
+	logRecordPos := indexer.Get(key)
 	if logRecordPos == nil {
 		// key not found in index, nothing to delete
-		if wb.pendingWrites[string(key)] != nil {
+		if wb.pendingWrites[pendingKey] != nil {
 			// key found in pending writes, mark it as deleted
-			delete(wb.pendingWrites, string(key))
+			delete(wb.pendingWrites, pendingKey)
 			return nil
 		}
 		// key not found in pending writes, nothing to delete
@@ -72,9 +110,10 @@ func (wb *WriteBatch) Delete(key []byte) error {
 	}
 
 	// temporary storage for the log record
-	wb.pendingWrites[string(key)] = &data.LogRecord{
-		Key:  key,
-		Type: data.LogRecordDeleted,
+	wb.pendingWrites[pendingKey] = &data.LogRecord{
+		Key:    key,
+		Type:   data.LogRecordDeleted,
+		Family: family,
 	}
 	return nil
 }
@@ -89,31 +128,44 @@ func (wb *WriteBatch) Commit() error {
 		return nil
 	}
 
+	// serial commit
+	wb.db.mut.Lock()
+	defer wb.db.mut.Unlock()
+	return wb.commit()
+}
+
+// commit writes the pending writes to disk as one transaction and updates the memory indexes.
+// Access this method needs db.mut is required.
+func (wb *WriteBatch) commit() error {
 	if uint(len(wb.pendingWrites)) > wb.options.MaxBatchNum {
 		return ErrExceedMaxBatchNum
 	}
 
-	// serial commit
-	wb.db.mut.Lock()
-	defer wb.db.mut.Unlock()
+	// every column family of the batch must still exist
+	for _, logRecord := range wb.pendingWrites {
+		if wb.db.indexOf(logRecord.Family) == nil {
+			return ErrColumnFamilyDropped
+		}
+	}
 
 	// get current transaction id
 	seqNo := atomic.AddUint64(&wb.db.seqNo, 1)
 
 	// write the log records to disk
 	positions := make(map[string]*data.LogRecordPos)
-	for _, logRecord := range wb.pendingWrites {
+	for pendingKey, logRecord := range wb.pendingWrites {
 		// encode the key with the sequence number
 		logRecordPos, err := wb.db.appendLogRecord(&data.LogRecord{
-			Key:   logRecordKeyWithSeq(logRecord.Key, seqNo),
-			Value: logRecord.Value,
-			Type:  logRecord.Type,
+			Key:    logRecordKeyWithSeq(logRecord.Key, seqNo),
+			Value:  logRecord.Value,
+			Type:   logRecord.Type,
+			Family: logRecord.Family,
 		})
 		if err != nil {
 			return err
 		}
 
-		positions[string(logRecord.Key)] = logRecordPos
+		positions[pendingKey] = logRecordPos
 	}
 
 	// write the transaction finish record to disk
@@ -127,19 +179,20 @@ func (wb *WriteBatch) Commit() error {
 
 	// sync the active file if required
 	if wb.options.SyncWrites && wb.db.activeFile != nil {
-		if err := wb.db.activeFile.Sync(); err != nil {
+		if err := wb.db.syncActiveFile(); err != nil {
 			return err
 		}
 	}
 
 	// update the index with the new positions
-	for _, record := range wb.pendingWrites {
-		pos := positions[string(record.Key)]
+	for pendingKey, record := range wb.pendingWrites {
+		pos := positions[pendingKey]
+		indexer := wb.db.indexOf(record.Family)
 		switch record.Type {
 		case data.LogRecordDeleted:
-			wb.db.index.Delete(record.Key)
+			indexer.Delete(record.Key)
 		case data.LogRecordNormal:
-			wb.db.index.Put(record.Key, pos)
+			indexer.Put(record.Key, pos)
 		}
 	}
 
@@ -149,6 +202,11 @@ func (wb *WriteBatch) Commit() error {
 	return nil
 }
 
+// pendingWriteKey returns the key of a pending write, keys of different column families never collide.
+func pendingWriteKey(family uint32, key []byte) string {
+	return string(logRecordKeyWithSeq(key, uint64(family)))
+}
+
 // logRecordKeyWithSeq returns the key of a log record with the given sequence number.
 func logRecordKeyWithSeq(key []byte, seqNo uint64) []byte {
 	seq := make([]byte, binary.MaxVarintLen64)
@@ -161,6 +219,12 @@ func logRecordKeyWithSeq(key []byte, seqNo uint64) []byte {
 	return encKey
 }
 
+// ParseLogRecordKey splits the key of a log record read from a data file into the transaction
+// sequence number and the user key. Records written outside a WriteBatch have sequence number 0.
+func ParseLogRecordKey(key []byte) (seqNo uint64, origKey []byte) {
+	return parseLogRecordKey(key)
+}
+
 // parseLogRecordKey parses the sequence number and original key from a log record key.
 func parseLogRecordKey(key []byte) (seqNo uint64, origKey []byte) {
 	seqNo, n := binary.Uvarint(key)
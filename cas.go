@@ -0,0 +1,127 @@
+package go_kv
+
+import (
+	"bytes"
+	"errors"
+	"go-kv/data"
+)
+
+// CompareAndSwap replaces the value of a key with newValue if its current value equals oldValue.
+// A nil oldValue means the key is expected to be absent.
+// The comparison and the write are performed atomically under the database write lock.
+// It returns true if the value was swapped.
+func (db *DB) CompareAndSwap(key, oldValue, newValue []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	value, err := db.getValue(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return false, err
+	}
+
+	// compare current value with old value, nil old value matches a missing key only
+	exists := err == nil
+	if oldValue == nil {
+		if exists {
+			return false, nil
+		}
+	} else if !exists || !bytes.Equal(value, oldValue) {
+		return false, nil
+	}
+
+	if err = db.putValue(key, newValue); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PutIfAbsent inserts a key-value pair only if the key does not exist.
+// It returns ErrKeyExists if the key already exists.
+func (db *DB) PutIfAbsent(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if pos := db.index.Get(key); pos != nil {
+		return ErrKeyExists
+	}
+	return db.putValue(key, value)
+}
+
+// DeleteIfEquals deletes a key only if its current value equals the given value.
+// It returns true if the key was deleted.
+func (db *DB) DeleteIfEquals(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	current, err := db.getValue(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(current, value) {
+		return false, nil
+	}
+
+	if err = db.deleteKey(key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getValue retrieves the value of a key without taking the database lock.
+// Access this method needs db.mut is required.
+func (db *DB) getValue(key []byte) ([]byte, error) {
+	logRecordPos := db.index.Get(key)
+	if logRecordPos == nil {
+		return nil, ErrKeyNotFound
+	}
+	return db.getValueByPosition(logRecordPos)
+}
+
+// putValue appends a normal log record for the key and updates the memory index.
+// Access this method needs db.mut is required.
+func (db *DB) putValue(key, value []byte) error {
+	logRecord := &data.LogRecord{
+		Key:   logRecordKeyWithSeq(key, nonTransactionalSeqNo),
+		Value: value,
+		Type:  data.LogRecordNormal,
+	}
+	pos, err := db.appendLogRecord(logRecord)
+	if err != nil {
+		return err
+	}
+	if ok := db.index.Put(key, pos); !ok {
+		return ErrIndexUpdateFailed
+	}
+	return nil
+}
+
+// deleteKey appends a deleted log record for the key and removes it from the memory index.
+// Access this method needs db.mut is required.
+func (db *DB) deleteKey(key []byte) error {
+	logRecord := &data.LogRecord{
+		Key:  logRecordKeyWithSeq(key, nonTransactionalSeqNo),
+		Type: data.LogRecordDeleted,
+	}
+	if _, err := db.appendLogRecord(logRecord); err != nil {
+		return err
+	}
+	if ok := db.index.Delete(key); !ok {
+		return ErrIndexUpdateFailed
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package go_kv
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDB_CompareAndSwap(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-cas")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	tests := []struct {
+		name      string
+		key       []byte
+		oldValue  []byte
+		newValue  []byte
+		want      bool
+		wantValue []byte
+		wantErr   error
+	}{
+		{
+			name:      "swap absent key with nil old value",
+			key:       []byte("lock"),
+			oldValue:  nil,
+			newValue:  []byte("owner-1"),
+			want:      true,
+			wantValue: []byte("owner-1"),
+		},
+		{
+			name:      "swap existing key with nil old value fails",
+			key:       []byte("lock"),
+			oldValue:  nil,
+			newValue:  []byte("owner-2"),
+			want:      false,
+			wantValue: []byte("owner-1"),
+		},
+		{
+			name:      "swap with wrong old value fails",
+			key:       []byte("lock"),
+			oldValue:  []byte("owner-2"),
+			newValue:  []byte("owner-3"),
+			want:      false,
+			wantValue: []byte("owner-1"),
+		},
+		{
+			name:      "swap with matching old value",
+			key:       []byte("lock"),
+			oldValue:  []byte("owner-1"),
+			newValue:  []byte("owner-2"),
+			want:      true,
+			wantValue: []byte("owner-2"),
+		},
+		{
+			name:     "swap absent key with non-nil old value fails",
+			key:      []byte("missing"),
+			oldValue: []byte("owner-1"),
+			newValue: []byte("owner-2"),
+			want:     false,
+		},
+		{
+			name:    "swap empty key",
+			key:     nil,
+			wantErr: ErrKeyIsEmpty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.CompareAndSwap(tt.key, tt.oldValue, tt.newValue)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CompareAndSwap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CompareAndSwap() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantValue == nil {
+				return
+			}
+			value, err := db.Get(tt.key)
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+			if string(value) != string(tt.wantValue) {
+				t.Errorf("Get() got = %s, want %s", value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestDB_CompareAndSwap_Concurrent(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-cas-concurrent")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	key := []byte("counter")
+	if err = db.Put(key, []byte("0")); err != nil {
+		t.Errorf("Put() error = %v", err)
+	}
+
+	// every goroutine retries until its increment is applied, so no update is lost
+	workers, increments := 8, 50
+	wg := new(sync.WaitGroup)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				for {
+					old, err := db.Get(key)
+					if err != nil {
+						t.Errorf("Get() error = %v", err)
+						return
+					}
+					n, _ := strconv.Atoi(string(old))
+					swapped, err := db.CompareAndSwap(key, old, []byte(strconv.Itoa(n+1)))
+					if err != nil {
+						t.Errorf("CompareAndSwap() error = %v", err)
+						return
+					}
+					if swapped {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := db.Get(key)
+	if err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	if string(value) != strconv.Itoa(workers*increments) {
+		t.Errorf("Get() got = %s, want %d", value, workers*increments)
+	}
+}
+
+func TestDB_PutIfAbsent(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-put-if-absent")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	tests := []struct {
+		name      string
+		key       []byte
+		value     []byte
+		wantValue []byte
+		wantErr   error
+	}{
+		{
+			name:      "put absent key",
+			key:       []byte("lease"),
+			value:     []byte("node-1"),
+			wantValue: []byte("node-1"),
+		},
+		{
+			name:      "put existing key",
+			key:       []byte("lease"),
+			value:     []byte("node-2"),
+			wantValue: []byte("node-1"),
+			wantErr:   ErrKeyExists,
+		},
+		{
+			name:    "put empty key",
+			key:     nil,
+			wantErr: ErrKeyIsEmpty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.PutIfAbsent(tt.key, tt.value); !errors.Is(err, tt.wantErr) {
+				t.Errorf("PutIfAbsent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantValue == nil {
+				return
+			}
+			value, err := db.Get(tt.key)
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+			if string(value) != string(tt.wantValue) {
+				t.Errorf("Get() got = %s, want %s", value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestDB_DeleteIfEquals(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-delete-if-equals")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	if err = db.Put([]byte("lock"), []byte("owner-1")); err != nil {
+		t.Errorf("Put() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		key        []byte
+		value      []byte
+		want       bool
+		wantExists bool
+	}{
+		{
+			name:       "delete with wrong value",
+			key:        []byte("lock"),
+			value:      []byte("owner-2"),
+			want:       false,
+			wantExists: true,
+		},
+		{
+			name:       "delete with matching value",
+			key:        []byte("lock"),
+			value:      []byte("owner-1"),
+			want:       true,
+			wantExists: false,
+		},
+		{
+			name:       "delete missing key",
+			key:        []byte("lock"),
+			value:      []byte("owner-1"),
+			want:       false,
+			wantExists: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.DeleteIfEquals(tt.key, tt.value)
+			if err != nil {
+				t.Errorf("DeleteIfEquals() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DeleteIfEquals() got = %v, want %v", got, tt.want)
+			}
+			_, err = db.Get(tt.key)
+			if exists := err == nil; exists != tt.wantExists {
+				t.Errorf("Get() error = %v, want exists %v", err, tt.wantExists)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Command go-kv-cli is an interactive shell for inspecting and editing a go-kv database.
+//
+//	go-kv-cli -dir /tmp/go-kv [-index auto] [-readonly] [-encoding hex]
+//
+// Commands are read from standard input, one per line, type help for a list of them.
+// With -readonly the database is opened read-only: no file in the directory is changed,
+// pending merge output is left for the next read-write open and the shell refuses put, del, batch and merge.
+package main
+
+import (
+	"flag"
+	"fmt"
+	go_kv "go-kv"
+	"go-kv/internal/dbflag"
+	"log"
+	"os"
+)
+
+func main() {
+	dir := flag.String("dir", "/tmp/go-kv", "directory of the database")
+	indexName := flag.String("index", "auto", dbflag.IndexUsage)
+	readOnly := flag.Bool("readonly", false, "open the database read-only and refuse commands that modify it")
+	encodingName := flag.String("encoding", "string", "encoding of keys and values, string or hex")
+	flag.Parse()
+
+	enc, ok := encodings[*encodingName]
+	if !ok {
+		log.Fatalf("unknown encoding %q, use string or hex", *encodingName)
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+
+	indexType, err := dbflag.IndexType(*indexName, *dir)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+	opts := go_kv.DefaultOptions
+	opts.DirPath = *dir
+	opts.IndexType = indexType
+	opts.ReadOnly = *readOnly
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+
+	sh := &shell{db: db, readOnly: *readOnly, enc: enc, encName: *encodingName, out: os.Stdout}
+	// only prompt when a user is typing, not when commands are piped in
+	stdin, err := os.Stdin.Stat()
+	interactive := err == nil && stdin.Mode()&os.ModeCharDevice != 0
+	if interactive {
+		fmt.Printf("go-kv shell on %s, type help for a list of commands\n", *dir)
+	}
+	sh.run(os.Stdin, interactive)
+
+	if err = db.Close(); err != nil {
+		log.Fatalf("close database: %v", err)
+	}
+}
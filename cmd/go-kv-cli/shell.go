@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	go_kv "go-kv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const defaultScanLimit = 100 // number of pairs printed by scan and prefix when no limit is given
+
+const helpText = `commands:
+  get <key>                                          print the value of a key
+  put <key> <value>                                  set a key
+  del <key>                                          delete a key
+  scan [from <key>] [to <key>] [limit <n>] [reverse] print pairs in [from, to)
+  prefix <prefix> [limit <n>] [reverse]              print pairs whose keys start with the prefix
+  count [prefix]                                     count keys, all keys without a prefix
+  stat                                               print database statistics
+  merge                                              merge the data files
+  batch                                              buffer put and del until commit or discard
+  commit | discard                                   apply or drop the buffered batch
+  export <file|-> [jsonl|csv]                        write all pairs to a file, - for the output
+  import <file> [jsonl|csv]                          load pairs written by export
+  encoding [string|hex]                              print or set the encoding of keys and values
+  help                                               print this help
+  quit | exit                                        leave the shell
+
+Arguments with spaces or escapes are written as Go quoted strings, e.g. "a b\n".
+`
+
+var errReadOnly = errors.New("database is opened read-only")
+
+// encoding converts keys and values between their raw bytes and the text of the shell.
+type encoding interface {
+	decode(s string) ([]byte, error)
+	encode(b []byte) string
+}
+
+// stringEncoding uses the bytes as they are and quotes them when printing is ambiguous.
+type stringEncoding struct{}
+
+func (stringEncoding) decode(s string) ([]byte, error) { return []byte(s), nil }
+
+func (stringEncoding) encode(b []byte) string {
+	s := string(b)
+	if s == "" || !utf8.ValidString(s) || strings.ContainsFunc(s, func(r rune) bool {
+		return r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// hexEncoding writes every byte as two hex digits.
+type hexEncoding struct{}
+
+func (hexEncoding) decode(s string) ([]byte, error) { return hex.DecodeString(s) }
+
+func (hexEncoding) encode(b []byte) string { return hex.EncodeToString(b) }
+
+var encodings = map[string]encoding{
+	"string": stringEncoding{},
+	"hex":    hexEncoding{},
+}
+
+// shell runs the commands read from in against a database and writes their output to out.
+type shell struct {
+	db       *go_kv.DB
+	readOnly bool
+	enc      encoding
+	encName  string
+	batch    *go_kv.WriteBatch // buffered writes between batch and commit, nil outside a batch
+	batchLen int
+	out      io.Writer
+}
+
+// run reads and runs commands until in is exhausted or the user quits.
+// A prompt is printed before each command if prompt is set.
+func (sh *shell) run(in io.Reader, prompt bool) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 64*1024*1024)
+	for {
+		if prompt {
+			if sh.batch != nil {
+				fmt.Fprintf(sh.out, "go-kv(batch %d)> ", sh.batchLen)
+			} else {
+				fmt.Fprint(sh.out, "go-kv> ")
+			}
+		}
+		if !scanner.Scan() {
+			break
+		}
+		args, err := splitArgs(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(sh.out, "error: %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "quit" || args[0] == "exit" {
+			break
+		}
+		if err = sh.exec(args); err != nil {
+			fmt.Fprintf(sh.out, "error: %v\n", err)
+		}
+	}
+	if sh.batch != nil {
+		fmt.Fprintf(sh.out, "discarded %d buffered writes\n", sh.batchLen)
+	}
+}
+
+// exec runs one command.
+func (sh *shell) exec(args []string) error {
+	name, args := args[0], args[1:]
+	switch name {
+	case "get":
+		return sh.get(args)
+	case "put":
+		return sh.put(args)
+	case "del":
+		return sh.del(args)
+	case "scan":
+		return sh.scan(args, nil)
+	case "prefix":
+		if len(args) < 1 {
+			return errors.New("usage: prefix <prefix> [limit <n>] [reverse]")
+		}
+		prefix, err := sh.enc.decode(args[0])
+		if err != nil {
+			return err
+		}
+		return sh.scan(args[1:], prefix)
+	case "count":
+		return sh.count(args)
+	case "stat":
+		return sh.stat()
+	case "merge":
+		if sh.readOnly {
+			return errReadOnly
+		}
+		if err := sh.db.Merge(); err != nil {
+			return err
+		}
+		fmt.Fprintln(sh.out, "OK")
+		return nil
+	case "batch":
+		return sh.beginBatch()
+	case "commit":
+		return sh.commitBatch()
+	case "discard":
+		if sh.batch == nil {
+			return errors.New("no batch in progress")
+		}
+		fmt.Fprintf(sh.out, "discarded %d writes\n", sh.batchLen)
+		sh.batch, sh.batchLen = nil, 0
+		return nil
+	case "export":
+		return sh.export(args)
+	case "import":
+		return sh.importFile(args)
+	case "encoding":
+		return sh.setEncoding(args)
+	case "help":
+		fmt.Fprint(sh.out, helpText)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, type help for a list of commands", name)
+}
+
+func (sh *shell) get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get <key>")
+	}
+	key, err := sh.enc.decode(args[0])
+	if err != nil {
+		return err
+	}
+	value, err := sh.db.Get(key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, sh.enc.encode(value))
+	return nil
+}
+
+func (sh *shell) put(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: put <key> <value>")
+	}
+	if sh.readOnly {
+		return errReadOnly
+	}
+	key, err := sh.enc.decode(args[0])
+	if err != nil {
+		return err
+	}
+	value, err := sh.enc.decode(args[1])
+	if err != nil {
+		return err
+	}
+	if sh.batch != nil {
+		return sh.buffered(sh.batch.Put(key, value))
+	}
+	if err = sh.db.Put(key, value); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "OK")
+	return nil
+}
+
+func (sh *shell) del(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: del <key>")
+	}
+	if sh.readOnly {
+		return errReadOnly
+	}
+	key, err := sh.enc.decode(args[0])
+	if err != nil {
+		return err
+	}
+	if sh.batch != nil {
+		return sh.buffered(sh.batch.Delete(key))
+	}
+	if err = sh.db.Delete(key); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "OK")
+	return nil
+}
+
+// scan prints the pairs selected by the from, to, limit and reverse arguments, restricted to the prefix.
+func (sh *shell) scan(args []string, prefix []byte) error {
+	options := go_kv.IteratorOptions{Prefix: prefix, Limit: defaultScanLimit}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "reverse" {
+			options.Reverse = true
+			continue
+		}
+		// from and to conflict with the prefix of the prefix command
+		allowed := args[i] == "limit" || prefix == nil && (args[i] == "from" || args[i] == "to")
+		if !allowed || i+1 == len(args) {
+			return fmt.Errorf("unexpected argument %q", args[i])
+		}
+
+		var err error
+		switch name, value := args[i], args[i+1]; name {
+		case "from":
+			options.LowerBound, err = sh.enc.decode(value)
+		case "to":
+			options.UpperBound, err = sh.enc.decode(value)
+		case "limit":
+			options.Limit, err = strconv.Atoi(value)
+			if err == nil && options.Limit < 1 {
+				err = errors.New("limit must be a positive integer")
+			}
+		}
+		if err != nil {
+			return err
+		}
+		i++
+	}
+
+	items, itemsErr := sh.db.Items(options)
+	n := 0
+	for key, value := range items {
+		fmt.Fprintf(sh.out, "%s => %s\n", sh.enc.encode(key), sh.enc.encode(value))
+		n++
+	}
+	if err := itemsErr(); err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "(%d pairs)\n", n)
+	return nil
+}
+
+func (sh *shell) count(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: count [prefix]")
+	}
+	var prefix []byte
+	if len(args) == 1 {
+		var err error
+		if prefix, err = sh.enc.decode(args[0]); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(sh.out, sh.db.Count(prefix))
+	return nil
+}
+
+func (sh *shell) stat() error {
+	stat, err := sh.db.Stat()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "keys:            %d\n", stat.KeyNum)
+	fmt.Fprintf(sh.out, "data files:      %d\n", stat.DataFileNum)
+	fmt.Fprintf(sh.out, "column families: %d\n", stat.ColumnFamilyNum)
+	fmt.Fprintf(sh.out, "disk size:       %d bytes\n", stat.DiskSize)
+	return nil
+}
+
+func (sh *shell) beginBatch() error {
+	if sh.readOnly {
+		return errReadOnly
+	}
+	if sh.batch != nil {
+		return errors.New("batch already in progress")
+	}
+	sh.batch, sh.batchLen = sh.db.NewWriteBatch(go_kv.DefaultWriteBatchOptions), 0
+	fmt.Fprintln(sh.out, "OK, put and del are buffered until commit or discard")
+	return nil
+}
+
+// buffered reports the result of buffering one write of the batch.
+func (sh *shell) buffered(err error) error {
+	if err != nil {
+		return err
+	}
+	sh.batchLen++
+	fmt.Fprintln(sh.out, "QUEUED")
+	return nil
+}
+
+func (sh *shell) commitBatch() error {
+	if sh.batch == nil {
+		return errors.New("no batch in progress")
+	}
+	batch, n := sh.batch, sh.batchLen
+	sh.batch, sh.batchLen = nil, 0
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "committed %d writes\n", n)
+	return nil
+}
+
+func (sh *shell) export(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: export <file|-> [jsonl|csv]")
+	}
+	format, err := exportFormat(args)
+	if err != nil {
+		return err
+	}
+	if args[0] == "-" {
+		return sh.db.Export(sh.out, format)
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if err = sh.db.Export(file, format); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "OK")
+	return nil
+}
+
+func (sh *shell) importFile(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: import <file> [jsonl|csv]")
+	}
+	if sh.readOnly {
+		return errReadOnly
+	}
+	if sh.batch != nil {
+		return errors.New("import is not supported inside a batch")
+	}
+	format, err := exportFormat(args)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = sh.db.Import(file, format); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "OK")
+	return nil
+}
+
+// exportFormat returns the format named by the second argument, or by the extension of the file name.
+func exportFormat(args []string) (go_kv.ExportFormat, error) {
+	name := "jsonl"
+	if len(args) == 2 {
+		name = args[1]
+	} else if strings.HasSuffix(strings.ToLower(args[0]), ".csv") {
+		name = "csv"
+	}
+	switch name {
+	case "jsonl":
+		return go_kv.JSONLines, nil
+	case "csv":
+		return go_kv.CSV, nil
+	}
+	return 0, fmt.Errorf("unknown format %q, use jsonl or csv", name)
+}
+
+func (sh *shell) setEncoding(args []string) error {
+	switch len(args) {
+	case 0:
+		fmt.Fprintln(sh.out, sh.encName)
+		return nil
+	case 1:
+		enc, ok := encodings[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown encoding %q, use string or hex", args[0])
+		}
+		sh.enc, sh.encName = enc, args[0]
+		fmt.Fprintln(sh.out, "OK")
+		return nil
+	}
+	return errors.New("usage: encoding [string|hex]")
+}
+
+// splitArgs splits a command line at spaces, arguments starting with a double quote are Go quoted strings.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	for {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if line == "" {
+			return args, nil
+		}
+		if line[0] != '"' {
+			end := strings.IndexFunc(line, unicode.IsSpace)
+			if end < 0 {
+				end = len(line)
+			}
+			args = append(args, line[:end])
+			line = line[end:]
+			continue
+		}
+
+		quoted, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted argument %s", line)
+		}
+		arg, _ := strconv.Unquote(quoted)
+		args = append(args, arg)
+		line = line[len(quoted):]
+	}
+}
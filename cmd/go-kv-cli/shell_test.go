@@ -0,0 +1,132 @@
+package main
+
+import (
+	go_kv "go-kv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runShell runs the commands against a database in a temporary directory and returns the output lines.
+func runShell(t *testing.T, readOnly bool, commands ...string) []string {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-cli")
+	opts.DirPath = dir
+	opts.IndexType = go_kv.Btree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+	for _, key := range []string{"a:1", "a:2", "b:1"} {
+		_ = db.Put([]byte(key), []byte("v"+key))
+	}
+
+	var out strings.Builder
+	sh := &shell{db: db, readOnly: readOnly, enc: stringEncoding{}, encName: "string", out: &out}
+	sh.run(strings.NewReader(strings.Join(commands, "\n")), false)
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestShell_ExportImport(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pairs.csv")
+	if got := runShell(t, false, "export "+file); !reflect.DeepEqual(got, []string{"OK"}) {
+		t.Fatalf("export output = %q", got)
+	}
+	if got := runShell(t, true, "import "+file); !reflect.DeepEqual(got, []string{"error: database is opened read-only"}) {
+		t.Errorf("read-only import output = %q", got)
+	}
+
+	// the pairs of the file overwrite the ones put before
+	got := runShell(t, false, "put a:1 changed", "put c:1 new", "import "+file, "get a:1", "count")
+	if want := []string{"OK", "OK", "OK", "va:1", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("import output = %q, want %q", got, want)
+	}
+}
+
+func TestShell_Commands(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		commands []string
+		want     []string
+	}{
+		{
+			name:     "get put del",
+			commands: []string{"get a:1", "put c:1 hello", "get c:1", "del c:1", "get c:1"},
+			want:     []string{"va:1", "OK", "hello", "OK", "error: key not found"},
+		},
+		{
+			name:     "quoted arguments",
+			commands: []string{`put "key with space" "line\nbreak"`, `get "key with space"`, `get "unterminated`},
+			want:     []string{"OK", `"line\nbreak"`, `error: invalid quoted argument "unterminated`},
+		},
+		{
+			name:     "scan",
+			commands: []string{"scan", "scan from a:2 to b:1", "scan reverse limit 1", "scan limit 0", "scan to"},
+			want: []string{
+				"a:1 => va:1", "a:2 => va:2", "b:1 => vb:1", "(3 pairs)",
+				"a:2 => va:2", "(1 pairs)",
+				"b:1 => vb:1", "(1 pairs)",
+				"error: limit must be a positive integer",
+				`error: unexpected argument "to"`,
+			},
+		},
+		{
+			name:     "prefix and count",
+			commands: []string{"prefix a: reverse", "prefix a: from a:2", "count", "count a:"},
+			want:     []string{"a:2 => va:2", "a:1 => va:1", "(2 pairs)", `error: unexpected argument "from"`, "3", "2"},
+		},
+		{
+			name:     "hex encoding",
+			commands: []string{"encoding hex", "put 00ff 0102", "get 00ff", "prefix 00", "get zz", "encoding", "encoding base64"},
+			want:     []string{"OK", "OK", "0102", "00ff => 0102", "(1 pairs)", "error: encoding/hex: invalid byte: U+007A 'z'", "hex", `error: unknown encoding "base64", use string or hex`},
+		},
+		{
+			name:     "batch",
+			commands: []string{"batch", "put c:1 x", "del a:1", "batch", "commit", "count", "batch", "put d:1 y", "discard", "get d:1", "commit"},
+			want: []string{
+				"OK, put and del are buffered until commit or discard", "QUEUED", "QUEUED",
+				"error: batch already in progress", "committed 2 writes", "3",
+				"OK, put and del are buffered until commit or discard", "QUEUED", "discarded 1 writes",
+				"error: key not found", "error: no batch in progress",
+			},
+		},
+		{
+			name:     "read-only",
+			readOnly: true,
+			commands: []string{"put c:1 x", "del a:1", "batch", "merge", "get a:1", "count"},
+			want: []string{
+				"error: database is opened read-only", "error: database is opened read-only",
+				"error: database is opened read-only", "error: database is opened read-only",
+				"va:1", "3",
+			},
+		},
+		{
+			name:     "export",
+			commands: []string{"prefix a: limit 1", "export - csv", "export - xml"},
+			want: []string{
+				"a:1 => va:1", "(1 pairs)",
+				"key,value,encoding", "a:1,va:1,", "a:2,va:2,", "b:1,vb:1,",
+				`error: unknown format "xml", use jsonl or csv`,
+			},
+		},
+		{
+			name:     "unknown command and quit",
+			commands: []string{"frobnicate", "quit", "get a:1"},
+			want:     []string{`error: unknown command "frobnicate", type help for a list of commands`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runShell(t, tt.readOnly, tt.commands...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
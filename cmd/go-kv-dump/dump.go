@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	go_kv "go-kv"
+	"go-kv/data"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileKind is the kind of a file of the data directory, it decides how the records are decoded.
+type fileKind int
+
+const (
+	dataFile fileKind = iota
+	hintFile
+	seqNoFile
+	mergeFinishedFile
+	columnFamilyFile
+)
+
+// recordTypes are the names of the log record types in the output and in the type filter.
+var recordTypes = map[data.LogRecordType]string{
+	data.LogRecordNormal:       "Normal",
+	data.LogRecordDeleted:      "Deleted",
+	data.LogRecordTxFinished:   "TxFinished",
+	data.LogRecordRangeDeleted: "RangeDeleted",
+}
+
+// record is one line of the output.
+type record struct {
+	File      string `json:"file"`
+	Offset    int64  `json:"offset"`
+	Size      int64  `json:"size,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Family    uint32 `json:"family,omitempty"`
+	SeqNo     uint64 `json:"seq_no,omitempty"` // data files only
+	Time      string `json:"time,omitempty"`   // time the record was written, if it carries one
+	Key       string `json:"key,omitempty"`
+	ValueSize int    `json:"value_size"`
+	Value     string `json:"value,omitempty"` // with -values only
+	Pos       string `json:"pos,omitempty"`   // position of the key in a data file, hint file only
+	CRCOK     bool   `json:"crc_ok"`
+	Error     string `json:"error,omitempty"` // the rest of the file cannot be read
+}
+
+// filter selects the records printed, the zero value selects all of them.
+type filter struct {
+	types   map[string]bool // lower case record type names, nil for all types
+	prefix  []byte          // prefix of the user key
+	seqNo   int64           // sequence number, negative for all
+	family  int64           // column family id, negative for all
+	badOnly bool            // records failing the crc check only
+}
+
+// match reports whether the record is selected, key is the user key.
+func (f *filter) match(r *record, key []byte) bool {
+	switch {
+	case f.types != nil && !f.types[strings.ToLower(r.Type)]:
+		return false
+	case f.badOnly && r.CRCOK:
+		return false
+	case f.seqNo >= 0 && r.SeqNo != uint64(f.seqNo):
+		return false
+	case f.family >= 0 && r.Family != uint32(f.family):
+		return false
+	}
+	return strings.HasPrefix(string(key), string(f.prefix))
+}
+
+// dumper prints the records of the files of a data directory.
+type dumper struct {
+	filter  filter
+	json    bool // one JSON object per line instead of text
+	hex     bool // keys and values in hex instead of quoted strings
+	values  bool // print values as well as their size
+	out     io.Writer
+	records int // records read
+	bad     int // records failing the crc check and files that could not be read to the end
+}
+
+// dumpPath dumps a file, or all files of the data directory in the order the database reads them.
+func (d *dumper) dumpPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		kind, ok := kindOf(filepath.Base(path))
+		if !ok {
+			return fmt.Errorf("%s is not a file of a data directory", path)
+		}
+		return d.dumpFile(path, kind)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if _, ok := kindOf(entry.Name()); ok && !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	// data files have zero padded ids, so the names sort in file id order
+	sort.Strings(names)
+	for _, name := range names {
+		kind, _ := kindOf(name)
+		if err = d.dumpFile(filepath.Join(path, name), kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kindOf returns the kind of a file by its name.
+func kindOf(name string) (fileKind, bool) {
+	switch name {
+	case data.HintFileName:
+		return hintFile, true
+	case data.SeqNoFileName:
+		return seqNoFile, true
+	case data.MergeFinishedFileName:
+		return mergeFinishedFile, true
+	case data.ColumnFamilyFileName:
+		return columnFamilyFile, true
+	}
+	_, err := fileIdOf(name)
+	return dataFile, err == nil
+}
+
+// fileIdOf returns the file id of a data file name, which the database always writes as %09d.data.
+func fileIdOf(name string) (uint32, error) {
+	id, ok := strings.CutSuffix(name, data.DataFileNameSuffix)
+	if !ok {
+		return 0, errors.New("not a data file")
+	}
+	fid, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if filepath.Base(data.GetDataFileName("", uint32(fid))) != name {
+		return 0, errors.New("not a data file")
+	}
+	return uint32(fid), nil
+}
+
+// dumpFile prints the records of one file, walking it with DataFile.ReadLogRecord.
+// The file is opened read-only, so read-only mounts and snapshots can be dumped.
+// Records failing the crc check are printed and skipped, a record that cannot be read ends the file.
+func (d *dumper) dumpFile(path string, kind fileKind) error {
+	name := filepath.Base(path)
+	var fid uint32
+	if kind == dataFile {
+		var err error
+		if fid, err = fileIdOf(name); err != nil {
+			return err
+		}
+	}
+	file, err := data.OpenFileReadOnly(path, fid)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fileSize, err := file.IoManager.Size()
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		logRecord, size, err := file.ReadLogRecord(offset)
+		if errors.Is(err, io.EOF) && offset < fileSize {
+			// the database ignores a partially written last record, report it anyway
+			err = fmt.Errorf("truncated record, %d bytes left", fileSize-offset)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil && !errors.Is(err, data.ErrInvalidCRC) {
+			d.bad++
+			return d.print(&record{File: name, Offset: offset, Error: err.Error()})
+		}
+		d.records++
+
+		r := &record{
+			File:      name,
+			Offset:    offset,
+			Size:      size,
+			Type:      recordTypes[logRecord.Type],
+			Family:    logRecord.Family,
+			ValueSize: len(logRecord.Value),
+			CRCOK:     err == nil,
+		}
+		if r.Type == "" {
+			r.Type = strconv.Itoa(int(logRecord.Type))
+		}
+		if logRecord.Timestamp != 0 {
+			r.Time = time.UnixMilli(logRecord.Timestamp).UTC().Format(time.RFC3339Nano)
+		}
+		if !r.CRCOK {
+			d.bad++
+		}
+		key := logRecord.Key
+		if kind == dataFile {
+			r.SeqNo, key = go_kv.ParseLogRecordKey(logRecord.Key)
+		}
+		if kind == hintFile && r.CRCOK {
+			pos := data.DecodeLogRecordPos(logRecord.Value)
+			r.Pos = fmt.Sprintf("%d:%d", pos.Fid, pos.Offset)
+		}
+		r.Key = d.encode(key)
+		if d.values {
+			r.Value = d.encode(logRecord.Value)
+		}
+
+		if d.filter.match(r, key) {
+			if err = d.print(r); err != nil {
+				return err
+			}
+		}
+		offset += size
+	}
+}
+
+// print writes one record as a JSON object or a line of text.
+func (d *dumper) print(r *record) error {
+	if d.json {
+		return json.NewEncoder(d.out).Encode(r)
+	}
+	if r.Error != "" {
+		_, err := fmt.Fprintf(d.out, "%s@%d error=%q\n", r.File, r.Offset, r.Error)
+		return err
+	}
+
+	crc := "ok"
+	if !r.CRCOK {
+		crc = "INVALID"
+	}
+	line := fmt.Sprintf("%s@%d size=%d type=%s", r.File, r.Offset, r.Size, r.Type)
+	if r.Family != 0 {
+		line += fmt.Sprintf(" family=%d", r.Family)
+	}
+	if strings.HasSuffix(r.File, data.DataFileNameSuffix) {
+		line += fmt.Sprintf(" seq=%d", r.SeqNo)
+	}
+	if r.Time != "" {
+		line += " time=" + r.Time
+	}
+	line += fmt.Sprintf(" key=%s value_size=%d", r.Key, r.ValueSize)
+	if r.Pos != "" {
+		line += " pos=" + r.Pos
+	}
+	if d.values {
+		line += " value=" + r.Value
+	}
+	_, err := fmt.Fprintf(d.out, "%s crc=%s\n", line, crc)
+	return err
+}
+
+// encode formats a key or value as hex or as a quoted string.
+func (d *dumper) encode(b []byte) string {
+	if d.hex {
+		return hex.EncodeToString(b)
+	}
+	if d.json {
+		return string(b)
+	}
+	return strconv.Quote(string(b))
+}
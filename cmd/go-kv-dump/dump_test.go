@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	go_kv "go-kv"
+	"go-kv/data"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeTestDir writes a put, a batch of two puts and a delete to a database in a temporary directory.
+func writeTestDir(t *testing.T) string {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-dump")
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	opts.DirPath = dir
+	opts.IndexType = go_kv.Btree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Put([]byte("user:1"), []byte("alice"))
+	wb := db.NewWriteBatch(go_kv.DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("user:2"), []byte("bob"))
+	_ = wb.Put([]byte("order:1"), []byte("book"))
+	if err = wb.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Delete([]byte("user:1"))
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// dumpJSON dumps the path with the filter and returns the printed records.
+func dumpJSON(t *testing.T, path string, f filter) ([]record, *dumper) {
+	var out strings.Builder
+	d := &dumper{filter: f, json: true, out: &out}
+	if err := d.dumpPath(path); err != nil {
+		t.Fatal(err)
+	}
+	var records []record
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	return records, d
+}
+
+// summary returns the type, sequence number and key of each record.
+// The puts of a batch are written in no particular order, so consecutive records of a batch are sorted by key.
+func summary(records []record) []string {
+	var lines []string
+	batchStart := 0
+	for i, r := range records {
+		if r.SeqNo == 0 || r.Type != "Normal" || i > 0 && records[i-1].SeqNo != r.SeqNo {
+			batchStart = i
+		}
+		lines = append(lines, strings.Join([]string{r.File, r.Type, strconv.FormatUint(r.SeqNo, 10), r.Key}, " "))
+		sort.Strings(lines[batchStart:])
+	}
+	return lines
+}
+
+func TestDumper_Filters(t *testing.T) {
+	dir := writeTestDir(t)
+	dataFileName := filepath.Base(data.GetDataFileName(dir, 0))
+
+	tests := []struct {
+		name   string
+		filter filter
+		want   []string
+	}{
+		{
+			name:   "all",
+			filter: filter{seqNo: -1, family: -1},
+			want: []string{
+				dataFileName + " Normal 0 user:1",
+				dataFileName + " Normal 1 order:1",
+				dataFileName + " Normal 1 user:2",
+				dataFileName + " TxFinished 1 tx-fin",
+				dataFileName + " Deleted 0 user:1",
+				"seq-no Normal 0 seq.no",
+			},
+		},
+		{
+			name:   "type",
+			filter: filter{types: map[string]bool{"deleted": true, "txfinished": true}, seqNo: -1, family: -1},
+			want:   []string{dataFileName + " TxFinished 1 tx-fin", dataFileName + " Deleted 0 user:1"},
+		},
+		{
+			name:   "prefix and seq",
+			filter: filter{prefix: []byte("user:"), seqNo: 1, family: -1},
+			want:   []string{dataFileName + " Normal 1 user:2"},
+		},
+		{
+			name:   "bad only",
+			filter: filter{seqNo: -1, family: -1, badOnly: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, d := dumpJSON(t, dir, tt.filter)
+			if got := summary(records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+			if d.records != 6 || d.bad != 0 {
+				t.Errorf("records, bad = %d, %d, want 6, 0", d.records, d.bad)
+			}
+		})
+	}
+}
+
+func TestDumper_Corrupted(t *testing.T) {
+	dir := writeTestDir(t)
+	fileName := data.GetDataFileName(dir, 0)
+
+	// flip a bit of the value of the first record, the records after it are still readable
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[strings.Index(string(content), "alice")] ^= 1
+	if err = os.WriteFile(fileName, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, d := dumpJSON(t, fileName, filter{seqNo: -1, family: -1, badOnly: true})
+	if len(records) != 1 || records[0].Offset != 0 || records[0].CRCOK {
+		t.Errorf("records = %+v, want the first record with an invalid crc", records)
+	}
+	if d.records != 5 || d.bad != 1 {
+		t.Errorf("records, bad = %d, %d, want 5, 1", d.records, d.bad)
+	}
+
+	// a truncated record ends the file with an error
+	if err = os.WriteFile(fileName, content[:len(content)-2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	records, _ = dumpJSON(t, fileName, filter{seqNo: -1, family: -1})
+	if last := records[len(records)-1]; !strings.HasPrefix(last.Error, "truncated record") {
+		t.Errorf("last record = %+v, want a truncated record error", last)
+	}
+}
+
+func Test_kindOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   fileKind
+		wantOk bool
+	}{
+		{name: "000000001.data", want: dataFile, wantOk: true},
+		{name: "1.data", wantOk: false},
+		{name: "0000000001.data", wantOk: false},
+		{name: "+00000001.data", wantOk: false},
+		{name: data.HintFileName, want: hintFile, wantOk: true},
+		{name: data.ColumnFamilyFileName, want: columnFamilyFile, wantOk: true},
+		{name: "bptree-index", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := kindOf(tt.name)
+			if ok != tt.wantOk || ok && got != tt.want {
+				t.Errorf("kindOf() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestDumper_ReadOnly(t *testing.T) {
+	dir := writeTestDir(t)
+	before, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a file named like a data file but not written by the database is refused
+	fileName := filepath.Join(dir, "1.data")
+	if err = os.WriteFile(fileName, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	d := &dumper{filter: filter{seqNo: -1, family: -1}, out: &out}
+	if err = d.dumpPath(fileName); err == nil {
+		t.Errorf("dumpPath(%s) error = nil, want an error", fileName)
+	}
+	if err = os.Remove(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	// dumping the directory leaves it as it was
+	if records, _ := dumpJSON(t, dir, filter{seqNo: -1, family: -1}); len(records) == 0 {
+		t.Errorf("dumpPath(%s) printed no records", dir)
+	}
+	after, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("directory has %d files after the dump, want %d", len(after), len(before))
+	}
+}
@@ -0,0 +1,60 @@
+// Command go-kv-grpc serves a go-kv database over gRPC.
+//
+//	go-kv-grpc -addr :50051 -dir /tmp/go-kv [-index auto]
+package main
+
+import (
+	"flag"
+	go_kv "go-kv"
+	kvgrpc "go-kv/grpc"
+	"go-kv/internal/dbflag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":50051", "TCP address to listen on")
+	dir := flag.String("dir", "/tmp/go-kv", "directory of the database")
+	indexName := flag.String("index", "auto", dbflag.IndexUsage)
+	flag.Parse()
+
+	indexType, err := dbflag.IndexType(*indexName, *dir)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+	opts := go_kv.DefaultOptions
+	opts.DirPath = *dir
+	opts.IndexType = indexType
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer()
+	kvgrpc.NewServer(db).Register(server)
+
+	// stop the server gracefully and close the database on interrupt
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		server.GracefulStop()
+	}()
+
+	log.Printf("go-kv grpc server listening on %s", *addr)
+	if err = server.Serve(listener); err != nil {
+		log.Printf("serve: %v", err)
+	}
+	if err = db.Close(); err != nil {
+		log.Fatalf("close database: %v", err)
+	}
+}
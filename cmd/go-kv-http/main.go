@@ -0,0 +1,55 @@
+// Command go-kv-http serves a go-kv database over a JSON REST API.
+//
+//	go-kv-http -addr :8080 -dir /tmp/go-kv [-index auto]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	go_kv "go-kv"
+	kvhttp "go-kv/http"
+	"go-kv/internal/dbflag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "TCP address to listen on")
+	dir := flag.String("dir", "/tmp/go-kv", "directory of the database")
+	indexName := flag.String("index", "auto", dbflag.IndexUsage)
+	flag.Parse()
+
+	indexType, err := dbflag.IndexType(*indexName, *dir)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+	opts := go_kv.DefaultOptions
+	opts.DirPath = *dir
+	opts.IndexType = indexType
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+
+	server := &http.Server{Addr: *addr, Handler: kvhttp.NewHandler(db)}
+
+	// shut the server down and close the database on interrupt
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		_ = server.Shutdown(context.Background())
+	}()
+
+	log.Printf("go-kv http server listening on %s", *addr)
+	if err = server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("serve: %v", err)
+	}
+	if err = db.Close(); err != nil {
+		log.Fatalf("close database: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+// Command go-kv-memcached serves a go-kv database over the memcached text protocol.
+//
+//	go-kv-memcached -addr :11212 -dir /tmp/go-kv [-index auto]
+package main
+
+import (
+	"errors"
+	"flag"
+	go_kv "go-kv"
+	"go-kv/internal/dbflag"
+	"go-kv/memcache"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	addr := flag.String("addr", ":11212", "TCP address to listen on")
+	dir := flag.String("dir", "/tmp/go-kv", "directory of the database")
+	indexName := flag.String("index", "auto", dbflag.IndexUsage)
+	flag.Parse()
+
+	indexType, err := dbflag.IndexType(*indexName, *dir)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+	opts := go_kv.DefaultOptions
+	opts.DirPath = *dir
+	opts.IndexType = indexType
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+
+	server, err := memcache.NewServer(db)
+	if err != nil {
+		log.Fatalf("create server: %v", err)
+	}
+
+	// close the server and the database on interrupt
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		_ = server.Close()
+	}()
+
+	log.Printf("go-kv memcached server listening on %s", *addr)
+	if err = server.ListenAndServe(*addr); err != nil && !errors.Is(err, memcache.ErrServerClosed) {
+		log.Printf("serve: %v", err)
+	}
+	if err = db.Close(); err != nil {
+		log.Fatalf("close database: %v", err)
+	}
+}
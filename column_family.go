@@ -0,0 +1,349 @@
+package go_kv
+
+import (
+	"fmt"
+	"go-kv/data"
+	"go-kv/index"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultFamilyId is the column family id of the keys written through the DB methods.
+const defaultFamilyId uint32 = 0
+
+// ColumnFamily is a handle to a named key space of a DB with its own memory index.
+// Keys of different column families never collide, they share data files, merge and sequence numbers.
+type ColumnFamily struct {
+	db      *DB
+	id      uint32        // column family id stored in every log record of the family
+	name    string        // unique name of the column family
+	index   index.Indexer // memory index of the column family
+	dropped bool          // set once the column family is dropped, guarded by db.mut
+}
+
+// CreateColumnFamily creates a new column family with the given name.
+// It returns ErrColumnFamilyExists if a column family with the name already exists.
+func (db *DB) CreateColumnFamily(name string) (*ColumnFamily, error) {
+	if name == "" {
+		return nil, ErrColumnFamilyNameIsEmpty
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if _, ok := db.familyIds[name]; ok {
+		return nil, ErrColumnFamilyExists
+	}
+
+	// column family ids are never reused, records of a dropped family must not come back
+	id := db.nextFamilyId
+	if err := db.writeFamilyRecord(name, id, data.LogRecordNormal); err != nil {
+		return nil, err
+	}
+	cf, err := db.openColumnFamily(name, id)
+	if err != nil {
+		return nil, err
+	}
+	db.nextFamilyId++
+	return cf, nil
+}
+
+// ColumnFamily returns the handle of the column family with the given name.
+// It returns ErrColumnFamilyNotFound if no such column family exists.
+func (db *DB) ColumnFamily(name string) (*ColumnFamily, error) {
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	id, ok := db.familyIds[name]
+	if !ok {
+		return nil, ErrColumnFamilyNotFound
+	}
+	return db.families[id], nil
+}
+
+// ColumnFamilies returns the names of all column families in ascending order.
+func (db *DB) ColumnFamilies() []string {
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	names := make([]string, 0, len(db.familyIds))
+	for name := range db.familyIds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DropColumnFamily drops the column family with the given name.
+// Only a drop record is written, the log records of the family are reclaimed by the next Merge.
+// All iterators of the column family must be closed before it is dropped.
+func (db *DB) DropColumnFamily(name string) error {
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	id, ok := db.familyIds[name]
+	if !ok {
+		return ErrColumnFamilyNotFound
+	}
+	if err := db.writeFamilyRecord(name, id, data.LogRecordDeleted); err != nil {
+		return err
+	}
+
+	cf := db.families[id]
+	cf.dropped = true
+	delete(db.families, id)
+	delete(db.familyIds, name)
+	if err := cf.index.Close(); err != nil {
+		return err
+	}
+	// B+Tree index of the family is persisted in its own directory
+	if db.options.IndexType == BPlusTree {
+		return os.RemoveAll(db.familyDirPath(id))
+	}
+	return nil
+}
+
+// Name returns the name of the column family.
+func (cf *ColumnFamily) Name() string {
+	return cf.name
+}
+
+// Put inserts a key-value pair into the column family.
+func (cf *ColumnFamily) Put(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	cf.db.mut.Lock()
+	defer cf.db.mut.Unlock()
+
+	if cf.dropped {
+		return ErrColumnFamilyDropped
+	}
+
+	// new log record
+	logRecord := &data.LogRecord{
+		Key:    logRecordKeyWithSeq(key, nonTransactionalSeqNo),
+		Value:  value,
+		Type:   data.LogRecordNormal,
+		Family: cf.id,
+	}
+	pos, err := cf.db.appendLogRecord(logRecord)
+	if err != nil {
+		return err
+	}
+
+	// update memory index
+	if ok := cf.index.Put(key, pos); !ok {
+		return ErrIndexUpdateFailed
+	}
+	return nil
+}
+
+// Get retrieves the value of a key from the column family.
+func (cf *ColumnFamily) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+
+	cf.db.mut.RLock()
+	defer cf.db.mut.RUnlock()
+
+	if cf.dropped {
+		return nil, ErrColumnFamilyDropped
+	}
+
+	logRecordPos := cf.index.Get(key)
+	if logRecordPos == nil {
+		return nil, ErrKeyNotFound
+	}
+	return cf.db.getValueByPosition(logRecordPos)
+}
+
+// Delete deletes a key-value pair from the column family.
+func (cf *ColumnFamily) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	cf.db.mut.Lock()
+	defer cf.db.mut.Unlock()
+
+	if cf.dropped {
+		return ErrColumnFamilyDropped
+	}
+
+	// validate key existence
+	if pos := cf.index.Get(key); pos == nil {
+		return nil
+	}
+
+	// new log record
+	logRecord := &data.LogRecord{
+		Key:    logRecordKeyWithSeq(key, nonTransactionalSeqNo),
+		Type:   data.LogRecordDeleted,
+		Family: cf.id,
+	}
+	if _, err := cf.db.appendLogRecord(logRecord); err != nil {
+		return err
+	}
+
+	// update memory index
+	if ok := cf.index.Delete(key); !ok {
+		return ErrIndexUpdateFailed
+	}
+	return nil
+}
+
+// NewIterator creates a new iterator over the column family.
+// The iterator of a dropped column family is always empty.
+func (cf *ColumnFamily) NewIterator(options IteratorOptions) *Iterator {
+	cf.db.mut.RLock()
+	defer cf.db.mut.RUnlock()
+
+	if cf.dropped {
+		return cf.db.newIterator(index.NewBTree(), options)
+	}
+	return cf.db.newIterator(cf.index, options)
+}
+
+// indexOf returns the memory index of the column family with the given id,
+// or nil if the column family does not exist or was dropped.
+// Access this method needs db.mut is required.
+func (db *DB) indexOf(family uint32) index.Indexer {
+	if family == defaultFamilyId {
+		return db.index
+	}
+	if cf, ok := db.families[family]; ok {
+		return cf.index
+	}
+	return nil
+}
+
+// openColumnFamily creates the handle and memory index of a column family and registers it.
+// Access this method needs db.mut is required.
+func (db *DB) openColumnFamily(name string, id uint32) (*ColumnFamily, error) {
+	dirPath := db.options.DirPath
+	if db.options.IndexType == BPlusTree {
+		dirPath = db.familyDirPath(id)
+		if !db.options.ReadOnly {
+			if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+				return nil, err
+			}
+		}
+	}
+	familyIndex, err := db.newIndexer(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cf := &ColumnFamily{
+		db:    db,
+		id:    id,
+		name:  name,
+		index: familyIndex,
+	}
+	db.families[id] = cf
+	db.familyIds[name] = id
+	return cf, nil
+}
+
+// familyDirPath returns the directory of the B+Tree index of a column family.
+func (db *DB) familyDirPath(id uint32) string {
+	return filepath.Join(db.options.DirPath, familyDirName(id))
+}
+
+// familyDirName returns the name of the B+Tree index directory of a column family in a data directory.
+func familyDirName(id uint32) string {
+	return fmt.Sprintf("cf-%09d", id)
+}
+
+// writeFamilyRecord appends a create (normal) or drop (deleted) record to the column family catalog file.
+// Access this method needs db.mut is required.
+func (db *DB) writeFamilyRecord(name string, id uint32, typ data.LogRecordType) error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if db.familyFile == nil {
+		familyFile, err := data.OpenColumnFamilyFile(db.options.DirPath)
+		if err != nil {
+			return err
+		}
+		db.familyFile = familyFile
+	}
+
+	record := &data.LogRecord{
+		Key:       []byte(name),
+		Value:     []byte(strconv.FormatUint(uint64(id), 10)),
+		Type:      typ,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	encRecord, _ := data.EncodeLogRecord(record)
+	if err := db.familyFile.Write(encRecord); err != nil {
+		return err
+	}
+	return db.familyFile.Sync()
+}
+
+// loadColumnFamilies replays the column family catalog file and opens every live column family.
+func (db *DB) loadColumnFamilies() error {
+	db.families = make(map[uint32]*ColumnFamily)
+	db.familyIds = make(map[string]uint32)
+	db.nextFamilyId = defaultFamilyId + 1
+
+	fileName := filepath.Join(db.options.DirPath, data.ColumnFamilyFileName)
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return nil
+	}
+	var familyFile *data.DataFile
+	var err error
+	if db.options.ReadOnly {
+		familyFile, err = data.OpenFileReadOnly(fileName, 0)
+	} else {
+		familyFile, err = data.OpenColumnFamilyFile(db.options.DirPath)
+	}
+	if err != nil {
+		return err
+	}
+	db.familyFile = familyFile
+
+	// the last record of a name wins, every id seen is reserved
+	live := make(map[string]uint32)
+	var offset int64 = 0
+	for {
+		record, size, err := familyFile.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		id, err := strconv.ParseUint(string(record.Value), 10, 32)
+		if err != nil {
+			return ErrDataDirectoryCorrupted
+		}
+		if record.Type == data.LogRecordDeleted {
+			delete(live, string(record.Key))
+		} else {
+			live[string(record.Key)] = uint32(id)
+		}
+		if uint32(id) >= db.nextFamilyId {
+			db.nextFamilyId = uint32(id) + 1
+		}
+
+		offset += size
+	}
+	familyFile.WriteOff = offset
+
+	for name, id := range live {
+		if _, err = db.openColumnFamily(name, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
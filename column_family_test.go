@@ -0,0 +1,218 @@
+package go_kv
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// openColumnFamilyTestDB opens a B-tree indexed database in a new temporary directory.
+func openColumnFamilyTestDB(t *testing.T) (*DB, Options) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-column-family")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, opts
+}
+
+func TestDB_ColumnFamily_Isolation(t *testing.T) {
+	db, _ := openColumnFamilyTestDB(t)
+	defer destroyDB(db)
+
+	users, err := db.CreateColumnFamily("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := db.CreateColumnFamily("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.CreateColumnFamily("users"); !errors.Is(err, ErrColumnFamilyExists) {
+		t.Errorf("CreateColumnFamily() error = %v, wantErr %v", err, ErrColumnFamilyExists)
+	}
+	if _, err = db.CreateColumnFamily(""); !errors.Is(err, ErrColumnFamilyNameIsEmpty) {
+		t.Errorf("CreateColumnFamily() error = %v, wantErr %v", err, ErrColumnFamilyNameIsEmpty)
+	}
+
+	_ = db.Put([]byte("1"), []byte("default"))
+	_ = users.Put([]byte("1"), []byte("alice"))
+	_ = orders.Put([]byte("1"), []byte("order-1"))
+	_ = orders.Put([]byte("2"), []byte("order-2"))
+	_ = orders.Delete([]byte("2"))
+
+	tests := []struct {
+		name    string
+		get     func(key []byte) ([]byte, error)
+		key     string
+		want    string
+		wantErr error
+	}{
+		{name: "default family", get: db.Get, key: "1", want: "default"},
+		{name: "users family", get: users.Get, key: "1", want: "alice"},
+		{name: "orders family", get: orders.Get, key: "1", want: "order-1"},
+		{name: "deleted key", get: orders.Get, key: "2", wantErr: ErrKeyNotFound},
+		{name: "key of another family", get: users.Get, key: "2", wantErr: ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get([]byte(tt.key))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Get() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := db.ColumnFamilies(), []string{"orders", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ColumnFamilies() = %v, want %v", got, want)
+	}
+	if got := len(db.ListKeys()); got != 1 {
+		t.Errorf("ListKeys() length = %d, want 1", got)
+	}
+
+	iterator := orders.NewIterator(DefaultIteratorOptions)
+	defer iterator.Close()
+	var keys []string
+	for ; iterator.Valid(); iterator.Next() {
+		keys = append(keys, string(iterator.Key()))
+	}
+	if want := []string{"1"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("iterator keys = %v, want %v", keys, want)
+	}
+}
+
+func TestDB_DropColumnFamily(t *testing.T) {
+	db, opts := openColumnFamilyTestDB(t)
+
+	users, err := db.CreateColumnFamily("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = users.Put([]byte("old"), []byte("value"))
+	_ = db.Put([]byte("kept"), []byte("value"))
+
+	if err = db.DropColumnFamily("users"); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.DropColumnFamily("users"); !errors.Is(err, ErrColumnFamilyNotFound) {
+		t.Errorf("DropColumnFamily() error = %v, wantErr %v", err, ErrColumnFamilyNotFound)
+	}
+	if err = users.Put([]byte("new"), []byte("value")); !errors.Is(err, ErrColumnFamilyDropped) {
+		t.Errorf("Put() error = %v, wantErr %v", err, ErrColumnFamilyDropped)
+	}
+	if _, err = users.Get([]byte("old")); !errors.Is(err, ErrColumnFamilyDropped) {
+		t.Errorf("Get() error = %v, wantErr %v", err, ErrColumnFamilyDropped)
+	}
+	if _, err = db.ColumnFamily("users"); !errors.Is(err, ErrColumnFamilyNotFound) {
+		t.Errorf("ColumnFamily() error = %v, wantErr %v", err, ErrColumnFamilyNotFound)
+	}
+
+	// a family created again with the same name starts empty
+	users, err = db.CreateColumnFamily("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = users.Put([]byte("new"), []byte("value"))
+
+	reopen := func(db *DB) *DB {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		db, err := Open(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	tests := []struct {
+		name string
+		pre  func(db *DB) *DB
+	}{
+		{name: "after restart", pre: reopen},
+		{
+			name: "after merge and restart",
+			pre: func(db *DB) *DB {
+				if err := db.Merge(); err != nil {
+					t.Fatal(err)
+				}
+				return reopen(db)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db = tt.pre(db)
+			users, err := db.ColumnFamily("users")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = users.Get([]byte("old")); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+			}
+			if _, err = users.Get([]byte("new")); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+			if _, err = db.Get([]byte("kept")); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+		})
+	}
+	destroyDB(db)
+}
+
+func TestWriteBatch_ColumnFamily(t *testing.T) {
+	db, opts := openColumnFamilyTestDB(t)
+
+	users, err := db.CreateColumnFamily("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = users.Put([]byte("gone"), []byte("value"))
+
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("key"), []byte("default"))
+	_ = wb.PutCF(users, []byte("key"), []byte("users"))
+	_ = wb.DeleteCF(users, []byte("gone"))
+	if err = wb.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+	users, _ = db.ColumnFamily("users")
+
+	if value, _ := db.Get([]byte("key")); string(value) != "default" {
+		t.Errorf("Get() got = %s, want default", value)
+	}
+	if value, _ := users.Get([]byte("key")); string(value) != "users" {
+		t.Errorf("Get() got = %s, want users", value)
+	}
+	if _, err = users.Get([]byte("gone")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+
+	// a batch writing to a dropped family is rejected as a whole
+	wb = db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("other"), []byte("default"))
+	_ = wb.PutCF(users, []byte("other"), []byte("users"))
+	_ = db.DropColumnFamily("users")
+	if err = wb.Commit(); !errors.Is(err, ErrColumnFamilyDropped) {
+		t.Errorf("Commit() error = %v, wantErr %v", err, ErrColumnFamilyDropped)
+	}
+	if _, err = db.Get([]byte("other")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+}
@@ -0,0 +1,66 @@
+package go_kv
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Incr increments the integer value of a key by one.
+// It returns the value after the increment.
+func (db *DB) Incr(key []byte) (int64, error) {
+	return db.IncrBy(key, 1)
+}
+
+// Decr decrements the integer value of a key by one.
+// It returns the value after the decrement.
+func (db *DB) Decr(key []byte) (int64, error) {
+	return db.IncrBy(key, -1)
+}
+
+// DecrBy decrements the integer value of a key by the given delta.
+// It returns the value after the decrement.
+func (db *DB) DecrBy(key []byte, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrIntegerOverflow
+	}
+	return db.IncrBy(key, -delta)
+}
+
+// IncrBy increments the integer value of a key by the given delta.
+// The value is stored as a base-10 string, a missing key is treated as zero.
+// The read, the addition and the write are performed atomically under the database write lock.
+// It returns ErrValueNotInteger if the current value is not an integer,
+// and ErrIntegerOverflow if the result does not fit in an int64.
+func (db *DB) IncrBy(key []byte, delta int64) (int64, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	// decode current value, missing key starts from zero
+	var current int64
+	value, err := db.getValue(key)
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		current, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return 0, ErrValueNotInteger
+		}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrIntegerOverflow
+	}
+	current += delta
+
+	if err = db.putValue(key, []byte(strconv.FormatInt(current, 10))); err != nil {
+		return 0, err
+	}
+	return current, nil
+}
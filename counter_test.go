@@ -0,0 +1,138 @@
+package go_kv
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDB_IncrBy(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-incr")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	tests := []struct {
+		name    string
+		pre     func()
+		key     []byte
+		delta   int64
+		want    int64
+		wantErr error
+	}{
+		{
+			name:  "incr missing key starts from zero",
+			pre:   func() {},
+			key:   []byte("hits"),
+			delta: 5,
+			want:  5,
+		},
+		{
+			name:  "decr existing key",
+			pre:   func() {},
+			key:   []byte("hits"),
+			delta: -7,
+			want:  -2,
+		},
+		{
+			name: "incr value written by put",
+			pre: func() {
+				_ = db.Put([]byte("quota"), []byte("100"))
+			},
+			key:   []byte("quota"),
+			delta: 1,
+			want:  101,
+		},
+		{
+			name: "incr non-integer value",
+			pre: func() {
+				_ = db.Put([]byte("name"), []byte("bitcask"))
+			},
+			key:     []byte("name"),
+			delta:   1,
+			wantErr: ErrValueNotInteger,
+		},
+		{
+			name: "incr overflow",
+			pre: func() {
+				_ = db.Put([]byte("max"), []byte(strconv.FormatInt(math.MaxInt64, 10)))
+			},
+			key:     []byte("max"),
+			delta:   1,
+			wantErr: ErrIntegerOverflow,
+		},
+		{
+			name:    "incr empty key",
+			pre:     func() {},
+			key:     nil,
+			delta:   1,
+			wantErr: ErrKeyIsEmpty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pre()
+			got, err := db.IncrBy(tt.key, tt.delta)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("IncrBy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("IncrBy() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDB_Incr_Concurrent(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-incr-concurrent")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	key := []byte("requests")
+	workers, increments := 8, 100
+	wg := new(sync.WaitGroup)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				if _, err := db.Incr(key); err != nil {
+					t.Errorf("Incr() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := db.Decr(key)
+	if err != nil {
+		t.Errorf("Decr() error = %v", err)
+	}
+	if want := int64(workers*increments - 1); got != want {
+		t.Errorf("Decr() got = %v, want %v", got, want)
+	}
+
+	value, err := db.Get(key)
+	if err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	if string(value) != strconv.FormatInt(got, 10) {
+		t.Errorf("Get() got = %s, want %d", value, got)
+	}
+}
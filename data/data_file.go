@@ -20,6 +20,7 @@ const (
 	HintFileName          = "hint-index"
 	MergeFinishedFileName = "merge-finished"
 	SeqNoFileName         = "seq-no"
+	ColumnFamilyFileName  = "column-families"
 )
 
 // DataFile is a struct that represents a data file.
@@ -39,9 +40,24 @@ func OpenDataFile(dirPath string, fileId uint32) (*DataFile, error) {
 	return newDataFile(fileName, fileId)
 }
 
+// OpenDataFileReadOnly opens an existing data file with the given fileId for reading only.
+func OpenDataFileReadOnly(dirPath string, fileId uint32) (*DataFile, error) {
+	return OpenFileReadOnly(GetDataFileName(dirPath, fileId), fileId)
+}
+
+// OpenFileReadOnly opens the existing file at the given path for reading only,
+// fileId is the id of a data file and 0 for the other files.
+func OpenFileReadOnly(fileName string, fileId uint32) (*DataFile, error) {
+	ioManager, err := fio.NewReadOnlyFileIOManager(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &DataFile{FileId: fileId, IoManager: ioManager}, nil
+}
+
 // GetDataFileName returns the file name for the given fileId in the given directory.
 func GetDataFileName(dirPath string, fileId uint32) string {
-	fileName := fmt.Sprintf("%s%09d", dirPath, fileId) + DataFileNameSuffix
+	fileName := filepath.Join(dirPath, fmt.Sprintf("%09d", fileId)+DataFileNameSuffix)
 	return fileName
 }
 
@@ -72,6 +88,11 @@ func OpenSeqNoFile(dirPath string) (*DataFile, error) {
 	return newDataFile(fileName, 0)
 }
 
+// OpenColumnFamilyFile opens the column family catalog file in the given directory.
+func OpenColumnFamilyFile(dirPath string) (*DataFile, error) {
+	return newDataFile(filepath.Join(dirPath, ColumnFamilyFileName), 0)
+}
+
 func newDataFile(fileName string, fileId uint32) (*DataFile, error) {
 	// create a new file IO manager for the file
 	ioManager, err := fio.NewFileIOManager(fileName)
@@ -86,6 +107,8 @@ func newDataFile(fileName string, fileId uint32) (*DataFile, error) {
 }
 
 // ReadLogRecord reads the data from the data file at the given offset.
+// A record failing the crc check is returned together with its size and ErrInvalidCRC,
+// so tools inspecting a corrupted file can skip over it.
 func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 	fileSize, err := df.IoManager.Size()
 	if err != nil {
@@ -118,7 +141,9 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 	recordSize := headerSize + keySize + valueSize
 
 	logRecord := &LogRecord{
-		Type: header.recordType,
+		Type:      header.recordType,
+		Family:    header.family,
+		Timestamp: header.timestamp,
 	}
 	// read the record kv content from the data file
 	if keySize > 0 || valueSize > 0 {
@@ -134,7 +159,7 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 
 	// validate the crc of the record
 	if header.crc != getLogRecordCRC(logRecord, headerBuf[crc32.Size:headerSize]) {
-		return nil, 0, ErrInvalidCRC
+		return logRecord, recordSize, ErrInvalidCRC
 	}
 
 	return logRecord, recordSize, nil
@@ -158,11 +183,12 @@ func (df *DataFile) Write(data []byte) error {
 	return nil
 }
 
-// WriteHintRecord writes the given log record position hint to the data file.
-func (df *DataFile) WriteHintRecord(key []byte, pos *LogRecordPos) error {
+// WriteHintRecord writes the given log record position hint of a column family to the data file.
+func (df *DataFile) WriteHintRecord(key []byte, family uint32, pos *LogRecordPos) error {
 	record := &LogRecord{
-		Key:   key,
-		Value: EncodeLogRecordPos(pos),
+		Key:    key,
+		Value:  EncodeLogRecordPos(pos),
+		Family: family,
 	}
 	encRecord, _ := EncodeLogRecord(record)
 	return df.Write(encRecord)
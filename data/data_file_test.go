@@ -2,7 +2,6 @@ package data
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"reflect"
 	"testing"
@@ -157,11 +156,29 @@ func TestOpenDataFile(t *testing.T) {
 	}
 }
 
+func TestGetDataFileName(t *testing.T) {
+	tests := []struct {
+		dirPath string
+		fileId  uint32
+		want    string
+	}{
+		{dirPath: "/tmp/go-kv", fileId: 1, want: "/tmp/go-kv/000000001.data"},
+		{dirPath: "/tmp/go-kv/", fileId: 1, want: "/tmp/go-kv/000000001.data"},
+		{dirPath: "go-kv", fileId: 123456789, want: "go-kv/123456789.data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dirPath, func(t *testing.T) {
+			if got := GetDataFileName(tt.dirPath, tt.fileId); got != tt.want {
+				t.Errorf("GetDataFileName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestDataFile_ReadLogRecord(t *testing.T) {
 	// remove the old data file
-	fileName := fmt.Sprintf("%s%09d", os.TempDir(), 1) + DataFileNameSuffix
-	err := os.Remove(fileName)
-	if err != nil {
+	err := os.Remove(GetDataFileName(os.TempDir(), 1))
+	if err != nil && !os.IsNotExist(err) {
 		t.Error(err)
 	}
 
@@ -247,3 +264,32 @@ func TestDataFile_ReadLogRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestDataFile_ReadLogRecordInvalidCRC(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-crc")
+	defer os.RemoveAll(dir)
+
+	record := &LogRecord{Type: LogRecordNormal, Key: []byte("key"), Value: []byte("value")}
+	encRecord, size := EncodeLogRecord(record)
+	// flip a bit of the value, the size of the record is unchanged
+	encRecord[len(encRecord)-1] ^= 1
+	dataFile, err := OpenDataFile(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataFile.Close()
+	if err = dataFile.Write(encRecord); err != nil {
+		t.Fatal(err)
+	}
+
+	readRecord, readSize, err := dataFile.ReadLogRecord(0)
+	if !errors.Is(err, ErrInvalidCRC) {
+		t.Errorf("ReadLogRecord() error = %v, wantErr %v", err, ErrInvalidCRC)
+	}
+	if readRecord == nil || string(readRecord.Key) != "key" {
+		t.Errorf("ReadLogRecord() readRecord = %v, want the corrupted record", readRecord)
+	}
+	if readSize != size {
+		t.Errorf("ReadLogRecord() size = %v, want %v", readSize, size)
+	}
+}
@@ -11,20 +11,31 @@ const (
 	LogRecordNormal LogRecordType = iota
 	LogRecordDeleted
 	LogRecordTxFinished
+	LogRecordRangeDeleted // key is the inclusive start, value is the exclusive end of the deleted range
 )
 
 // maxLogRecordHeaderSize is the size of the header of a log record in bytes.
-// crc type key size value size
-// 4  + 1     + 5       +5    = 15 bytes
-const maxLogRecordHeaderSize = binary.MaxVarintLen32*2 + 5
+// crc type key size value size family timestamp
+// 4  + 1     + 5       +5    + 5     + 10      = 30 bytes
+const maxLogRecordHeaderSize = binary.MaxVarintLen32*3 + binary.MaxVarintLen64 + 5
+
+// logRecordFamilyFlag is set in the record type byte when the header carries a column family id.
+// Records of the default family never set it, so their encoding is unchanged.
+const logRecordFamilyFlag = 0x80
+
+// logRecordTimestampFlag is set in the record type byte when the header carries a timestamp.
+// Records written before timestamps were added do not set it.
+const logRecordTimestampFlag = 0x40
 
 // LogRecord represents a record in the log.
 // It contains the key, value, and type of the record.
 // The key and value are byte slices, and the type is a LogRecordType.
 type LogRecord struct {
-	Key   []byte        // key of the record
-	Value []byte        // value of the record
-	Type  LogRecordType // type of the record
+	Key       []byte        // key of the record
+	Value     []byte        // value of the record
+	Type      LogRecordType // type of the record
+	Family    uint32        // column family id of the record, 0 is the default family
+	Timestamp int64         // unix milliseconds the record was written at, 0 if unknown
 }
 
 type logRecordHeader struct {
@@ -32,23 +43,38 @@ type logRecordHeader struct {
 	recordType LogRecordType // type of the LogRecord
 	keySize    uint32        // length of the key
 	valueSize  uint32        // length of the value
+	family     uint32        // column family id of the LogRecord
+	timestamp  int64         // unix milliseconds the LogRecord was written at
 }
 
 // EncodeLogRecord encodes a log record into a byte slice.
-// +---------------------------------------------------------------------------------------+
-// | crc32 | record type | key size 			   | value size     		 | key | value |
-// +---------------------------------------------------------------------------------------+
-// | 4     | 1           | Variable length (max 5) | Variable length (max 5) | n   | n     |
+// +-------------------------------------------------------------------------------------------------------------------+
+// | crc32 | record type | key size 			   | value size     		 | family (optional)       | timestamp (optional)     | key | value |
+// +-------------------------------------------------------------------------------------------------------------------+
+// | 4     | 1           | Variable length (max 5) | Variable length (max 5) | Variable length (max 5) | Variable length (max 10) | n   | n     |
 func EncodeLogRecord(record *LogRecord) ([]byte, int64) {
 	// init header with zeros
 	header := make([]byte, maxLogRecordHeaderSize)
 
 	// the five is stored record type
 	header[4] = byte(record.Type)
+	if record.Family != 0 {
+		header[4] |= logRecordFamilyFlag
+	}
+	if record.Timestamp != 0 {
+		header[4] |= logRecordTimestampFlag
+	}
 	index := 5
 	// after the record type, the key size and value size are stored
 	index += binary.PutVarint(header[index:], int64(len(record.Key)))
 	index += binary.PutVarint(header[index:], int64(len(record.Value)))
+	// column family id is only stored for non-default families
+	if record.Family != 0 {
+		index += binary.PutVarint(header[index:], int64(record.Family))
+	}
+	if record.Timestamp != 0 {
+		index += binary.PutVarint(header[index:], record.Timestamp)
+	}
 
 	var size = index + len(record.Key) + len(record.Value)
 	encBytes := make([]byte, size)
@@ -97,7 +123,7 @@ func decodeLogRecordHeader(buf []byte) (*logRecordHeader, int64) {
 
 	header := &logRecordHeader{
 		crc:        binary.LittleEndian.Uint32(buf[:4]),
-		recordType: LogRecordType(buf[4]),
+		recordType: LogRecordType(buf[4] &^ (logRecordFamilyFlag | logRecordTimestampFlag)),
 	}
 
 	var index = 5
@@ -110,6 +136,18 @@ func decodeLogRecordHeader(buf []byte) (*logRecordHeader, int64) {
 	header.valueSize = uint32(valueSize)
 	index += n
 
+	// get column family id if the header carries one
+	if buf[4]&logRecordFamilyFlag != 0 {
+		family, n := binary.Varint(buf[index:])
+		header.family = uint32(family)
+		index += n
+	}
+	if buf[4]&logRecordTimestampFlag != 0 {
+		timestamp, n := binary.Varint(buf[index:])
+		header.timestamp = timestamp
+		index += n
+	}
+
 	return header, int64(index)
 }
 
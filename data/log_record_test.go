@@ -42,6 +42,29 @@ func TestEncodeLogRecord(t *testing.T) {
 			want:    []byte{43, 153, 86, 17, 1, 8, 20, 110, 97, 109, 101, 98, 105, 116, 99, 97, 115, 107, 45, 103, 111},
 			wantLen: 21,
 		},
+		{
+			name: "column family record",
+			record: &LogRecord{
+				Key:    []byte("name"),
+				Value:  []byte("bitcask-go"),
+				Type:   LogRecordNormal,
+				Family: 3,
+			},
+			want:    []byte{15, 6, 158, 221, 128, 8, 20, 6, 110, 97, 109, 101, 98, 105, 116, 99, 97, 115, 107, 45, 103, 111},
+			wantLen: 22,
+		},
+		{
+			name: "timestamped record",
+			record: &LogRecord{
+				Key:       []byte("name"),
+				Value:     []byte("bitcask-go"),
+				Type:      LogRecordNormal,
+				Family:    3,
+				Timestamp: 1000,
+			},
+			want:    []byte{159, 122, 124, 248, 192, 8, 20, 6, 208, 15, 110, 97, 109, 101, 98, 105, 116, 99, 97, 115, 107, 45, 103, 111},
+			wantLen: 24,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -81,6 +104,18 @@ func Test_decodeLogRecordHeader(t *testing.T) {
 			want:    &logRecordHeader{crc: 290887979, recordType: LogRecordDeleted, keySize: 4, valueSize: 10},
 			wantLen: 7,
 		},
+		{
+			name:    "read column family record header",
+			buf:     []byte{15, 6, 158, 221, 128, 8, 20, 6},
+			want:    &logRecordHeader{crc: 3718120975, recordType: LogRecordNormal, keySize: 4, valueSize: 10, family: 3},
+			wantLen: 8,
+		},
+		{
+			name:    "read timestamped record header",
+			buf:     []byte{159, 122, 124, 248, 192, 8, 20, 6, 208, 15},
+			want:    &logRecordHeader{crc: 4168907423, recordType: LogRecordNormal, keySize: 4, valueSize: 10, family: 3, timestamp: 1000},
+			wantLen: 10,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -29,10 +30,23 @@ type DB struct {
 
 	seqNo uint64 // transaction sequence number for log records
 
-	isMerging bool // flag for merging data files
+	isMerging   bool // flag for merging data files
+	isBackingUp bool // flag for a running backup, guarded by db.mut
 
 	seqNoFileExists bool // flag for seqNoFile existence
 	isInitial       bool // flag for initial database creation
+
+	bytesWrite uint          // bytes written to the active file since the last sync
+	syncStop   chan struct{} // closed to stop the background sync goroutine
+	syncDone   chan struct{} // closed by the background sync goroutine when it exits
+	syncErr    error         // error of the last failed background sync, returned by the next write or Sync
+
+	families     map[uint32]*ColumnFamily // live column families by id
+	familyIds    map[string]uint32        // live column family ids by name
+	nextFamilyId uint32                   // id of the next created column family
+	familyFile   *data.DataFile           // column family catalog file, nil until the first family is created
+
+	watchers map[*watcher]struct{} // subscribers of the events of new writes
 }
 
 // Open opens a (bitcask) database with the given options.
@@ -45,7 +59,9 @@ func Open(options Options) (*DB, error) {
 
 	var isInitial bool
 	// check if data directory exists, create if not
-	if _, err := os.Stat(options.DirPath); os.IsNotExist(err) {
+	if _, err := os.Stat(options.DirPath); options.ReadOnly && err != nil {
+		return nil, err
+	} else if os.IsNotExist(err) {
 		isInitial = true
 		if err = os.MkdirAll(options.DirPath, os.ModePerm); err != nil {
 			return nil, err
@@ -64,20 +80,29 @@ func Open(options Options) (*DB, error) {
 		options:    options,
 		mut:        new(sync.RWMutex),
 		olderFiles: make(map[uint32]*data.DataFile),
-		index:      index.NewIndexer(options.IndexType, options.DirPath, options.SyncWrites),
 		isInitial:  isInitial,
 	}
-
-	// load merge data files
-	if err := db.loadMergeFiles(); err != nil {
+	if db.index, err = db.newIndexer(options.DirPath); err != nil {
 		return nil, err
 	}
 
+	// load merge data files, a read-only database ignores them, the files they replace are still there
+	if !options.ReadOnly {
+		if err := db.loadMergeFiles(); err != nil {
+			return nil, err
+		}
+	}
+
 	// load data files from disk
 	if err := db.loadDataFiles(); err != nil {
 		return nil, err
 	}
 
+	// load column families, their indexes are filled with the default one
+	if err := db.loadColumnFamilies(); err != nil {
+		return nil, err
+	}
+
 	// B+Tree index type, not load hint file and load memory index from data files
 	if options.IndexType != BPlusTree {
 		// load hint file
@@ -105,6 +130,13 @@ func Open(options Options) (*DB, error) {
 		}
 	}
 
+	// start background sync goroutine if a sync interval is configured
+	if options.SyncInterval > 0 && !options.ReadOnly {
+		db.syncStop = make(chan struct{})
+		db.syncDone = make(chan struct{})
+		go db.syncPeriodically()
+	}
+
 	return db, nil
 }
 
@@ -117,9 +149,24 @@ func checkOptions(options Options) error {
 	if options.DataFileSize <= 0 {
 		return errors.New("database DataFileSize is not positive")
 	}
+	if options.SyncInterval < 0 {
+		return errors.New("database SyncInterval is negative")
+	}
 	return nil
 }
 
+// newIndexer creates the memory index of the default or a column family kept in dirPath.
+func (db *DB) newIndexer(dirPath string) (index.Indexer, error) {
+	if db.options.ReadOnly && db.options.IndexType == BPlusTree {
+		bpt, err := index.OpenBPlusTreeReadOnly(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		return bpt, nil
+	}
+	return index.NewIndexer(db.options.IndexType, dirPath, db.options.SyncWrites), nil
+}
+
 // loadDataFiles loads all data files from disk.
 func (db *DB) loadDataFiles() error {
 	dirEntries, err := os.ReadDir(db.options.DirPath)
@@ -147,7 +194,12 @@ func (db *DB) loadDataFiles() error {
 
 	// load data files from disk
 	for idx, fileId := range fileIds {
-		dataFile, err := data.OpenDataFile(db.options.DirPath, uint32(fileId))
+		var dataFile *data.DataFile
+		if db.options.ReadOnly {
+			dataFile, err = data.OpenDataFileReadOnly(db.options.DirPath, uint32(fileId))
+		} else {
+			dataFile, err = data.OpenDataFile(db.options.DirPath, uint32(fileId))
+		}
 		if err != nil {
 			return err
 		}
@@ -184,12 +236,17 @@ func (db *DB) loadIndexFromDataFiles() error {
 	}
 
 	// define a function to update memory index from a log record
-	updateIndex := func(key []byte, typ data.LogRecordType, pos *data.LogRecordPos) error {
+	updateIndex := func(key []byte, typ data.LogRecordType, family uint32, pos *data.LogRecordPos) error {
+		indexer := db.indexOf(family)
+		if indexer == nil {
+			// the column family was dropped, its records wait to be reclaimed by merge
+			return nil
+		}
 		var ok bool
 		if typ == data.LogRecordDeleted {
-			ok = db.index.Delete(key)
+			ok = indexer.Delete(key)
 		} else {
-			ok = db.index.Put(key, pos)
+			ok = indexer.Put(key, pos)
 		}
 		if !ok {
 			return ErrIndexUpdateFailed
@@ -233,16 +290,23 @@ func (db *DB) loadIndexFromDataFiles() error {
 
 			// parse log record key to extract sequence number
 			seqNo, origKey := parseLogRecordKey(record.Key)
-			if seqNo == nonTransactionalSeqNo {
+			if seqNo == nonTransactionalSeqNo && record.Type == data.LogRecordRangeDeleted {
+				// range tombstone, remove every key in range written before it
+				if indexer := db.indexOf(record.Family); indexer != nil {
+					if err = deleteIndexRange(indexer, origKey, record.Value); err != nil {
+						return err
+					}
+				}
+			} else if seqNo == nonTransactionalSeqNo {
 				// non-transactional log record, update memory index directly
-				if err = updateIndex(origKey, record.Type, logRecordPos); err != nil {
+				if err = updateIndex(origKey, record.Type, record.Family, logRecordPos); err != nil {
 					return err
 				}
 			} else {
 				// transactional log record, store in temporary storage
 				if record.Type == data.LogRecordTxFinished {
 					for _, trRecord := range transactionRecords[seqNo] {
-						if err = updateIndex(trRecord.Record.Key, trRecord.Record.Type, trRecord.Pos); err != nil {
+						if err = updateIndex(trRecord.Record.Key, trRecord.Record.Type, trRecord.Record.Family, trRecord.Pos); err != nil {
 							return err
 						}
 					}
@@ -278,32 +342,43 @@ func (db *DB) loadIndexFromDataFiles() error {
 
 // Close closes the database.
 func (db *DB) Close() error {
-	if db.activeFile == nil {
-		return nil
+	// stop background sync goroutine, it must not hold db.mut while closing
+	if db.syncStop != nil {
+		close(db.syncStop)
+		<-db.syncDone
+		db.syncStop = nil
 	}
 
 	db.mut.Lock()
 	defer db.mut.Unlock()
-	// index close
-	if err := db.index.Close(); err != nil {
-		return err
+	// close the event channels of watchers, a database that was never written has them too
+	for w := range db.watchers {
+		db.removeWatcher(w, nil)
 	}
 
-	// save current transaction sequence number to seqNoFile
-	seqNoFile, err := data.OpenSeqNoFile(db.options.DirPath)
-	if err != nil {
+	if db.activeFile == nil {
+		return nil
+	}
+	// index close
+	if err := db.index.Close(); err != nil {
 		return err
 	}
-	record := &data.LogRecord{
-		Key:   []byte(SeqNoKey),
-		Value: []byte(strconv.FormatUint(db.seqNo, 10)),
+	for _, cf := range db.families {
+		if err := cf.index.Close(); err != nil {
+			return err
+		}
 	}
-	encodeLogRecord, _ := data.EncodeLogRecord(record)
-	if err = seqNoFile.Write(encodeLogRecord); err != nil {
-		return err
+	if db.familyFile != nil {
+		if err := db.familyFile.Close(); err != nil {
+			return err
+		}
 	}
-	if err = seqNoFile.Sync(); err != nil {
-		return err
+
+	// save current transaction sequence number to seqNoFile
+	if !db.options.ReadOnly {
+		if err := writeSeqNoFile(db.options.DirPath, db.seqNo); err != nil {
+			return err
+		}
 	}
 
 	// close active data file
@@ -331,7 +406,101 @@ func (db *DB) Sync() error {
 	defer db.mut.Unlock()
 
 	// flush active data file
-	return db.activeFile.Sync()
+	err := db.syncActiveFile()
+	if syncErr := db.takeSyncErr(); syncErr != nil {
+		return syncErr
+	}
+	return err
+}
+
+// syncActiveFile flushes the active data file to disk and resets the unsynced byte counter.
+// Access this method needs db.mut is required.
+func (db *DB) syncActiveFile() error {
+	if err := db.activeFile.Sync(); err != nil {
+		return err
+	}
+	db.bytesWrite = 0
+	return nil
+}
+
+// takeSyncErr returns the error of the last failed background sync and clears it.
+// Access this method needs db.mut is required.
+func (db *DB) takeSyncErr() error {
+	err := db.syncErr
+	db.syncErr = nil
+	return err
+}
+
+// syncPeriodically flushes the active data file to disk every SyncInterval
+// until the database is closed. Nothing is flushed if no bytes were written since the last sync.
+// A failed sync is kept in db.syncErr and reported by the next write or Sync.
+func (db *DB) syncPeriodically() {
+	defer close(db.syncDone)
+
+	ticker := time.NewTicker(db.options.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.mut.Lock()
+			if db.activeFile != nil && db.bytesWrite > 0 {
+				if err := db.syncActiveFile(); err != nil {
+					db.syncErr = err
+				}
+			}
+			db.mut.Unlock()
+		case <-db.syncStop:
+			return
+		}
+	}
+}
+
+// Stat describes the state of a database.
+type Stat struct {
+	KeyNum          int   // number of keys in the default column family
+	DataFileNum     int   // number of data files
+	ColumnFamilyNum int   // number of column families, not counting the default one
+	DiskSize        int64 // size of all files in the data directory in bytes
+}
+
+// Stat returns the current state of the database.
+// The files are listed under the database lock and sized after releasing it,
+// a file removed in between, for example by a merge, is not counted.
+func (db *DB) Stat() (*Stat, error) {
+	db.mut.RLock()
+	stat := &Stat{
+		KeyNum:          db.index.Size(),
+		DataFileNum:     len(db.olderFiles),
+		ColumnFamilyNum: len(db.families),
+	}
+	if db.activeFile != nil {
+		stat.DataFileNum++
+	}
+
+	var paths []string
+	err := filepath.WalkDir(db.options.DirPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	db.mut.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stat.DiskSize += info.Size()
+	}
+	return stat, nil
 }
 
 // Put inserts a key-value pair into the database.
@@ -351,8 +520,13 @@ func (db *DB) Put(key, value []byte) error {
 		Type:  data.LogRecordNormal,
 	}
 
+	// the index is updated under the same lock as the append, so a backup sealing the active file
+	// sees both or neither
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
 	// append log record to active data file
-	pos, err := db.appendLogRecordWithLock(logRecord)
+	pos, err := db.appendLogRecord(logRecord)
 	if err != nil {
 		return err
 	}
@@ -372,6 +546,9 @@ func (db *DB) Delete(key []byte) error {
 		return ErrKeyIsEmpty
 	}
 
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
 	// validate key existence
 	if pos := db.index.Get(key); pos == nil {
 		return nil
@@ -384,7 +561,7 @@ func (db *DB) Delete(key []byte) error {
 	}
 
 	// append log record to active data file
-	_, err := db.appendLogRecordWithLock(logRecord)
+	_, err := db.appendLogRecord(logRecord)
 	if err != nil {
 		return err
 	}
@@ -397,15 +574,16 @@ func (db *DB) Delete(key []byte) error {
 	return nil
 }
 
-// appendLogRecordWithLock appends a log record to the active data file.
-func (db *DB) appendLogRecordWithLock(logRecord *data.LogRecord) (*data.LogRecordPos, error) {
-	db.mut.Lock()
-	defer db.mut.Unlock()
-	return db.appendLogRecord(logRecord)
-}
-
 // appendLogRecord appends a log record to the active data file.
 func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, error) {
+	if db.options.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	// the records written before a failed background sync may not be on disk
+	if err := db.takeSyncErr(); err != nil {
+		return nil, err
+	}
+
 	// if active file is full, create a new one
 	// if active file is not full, append log record to active file
 	if db.activeFile == nil {
@@ -415,13 +593,18 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 		}
 	}
 
+	// stamp the record with the time it is written, records copied by merge keep theirs
+	if logRecord.Timestamp == 0 {
+		logRecord.Timestamp = time.Now().UnixMilli()
+	}
+
 	// encode log record
 	encRecord, size := data.EncodeLogRecord(logRecord)
 	// if active file is full, create a new one
 	// if active file is not full, append log record to active file
 	if db.activeFile.WriteOff+size > db.options.DataFileSize {
 		// persistence logic, sync current memory buffer to disk
-		if err := db.activeFile.Sync(); err != nil {
+		if err := db.syncActiveFile(); err != nil {
 			return nil, err
 		}
 
@@ -440,9 +623,14 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 		return nil, err
 	}
 
-	// if you need immediately flush to disk, call Sync()
-	if db.options.SyncWrites {
-		if err := db.activeFile.Sync(); err != nil {
+	// if you need immediately flush to disk, or enough bytes are written since last sync, call Sync()
+	db.bytesWrite += uint(size)
+	needSync := db.options.SyncWrites
+	if !needSync && db.options.BytesPerSync > 0 && db.bytesWrite >= db.options.BytesPerSync {
+		needSync = true
+	}
+	if needSync {
+		if err := db.syncActiveFile(); err != nil {
 			return nil, err
 		}
 	}
@@ -452,6 +640,7 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 		Fid:    db.activeFile.FileId,
 		Offset: writeOff,
 	}
+	db.notifyWatchers(logRecord, pos)
 	return pos, nil
 }
 
@@ -519,6 +708,50 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 	return db.getValueByPosition(logRecordPos)
 }
 
+// MultiGet retrieves the values of several keys from the database in one pass.
+// The returned slices have the same length and order as keys,
+// a key that is empty or not found gets a nil value and a non-nil error.
+// Reads are ordered by data file and offset to minimize disk seeks.
+func (db *DB) MultiGet(keys [][]byte) ([][]byte, []error) {
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	// lookup all log record positions from memory index
+	type lookup struct {
+		idx int
+		pos *data.LogRecordPos
+	}
+	lookups := make([]lookup, 0, len(keys))
+	for idx, key := range keys {
+		if len(key) == 0 {
+			errs[idx] = ErrKeyIsEmpty
+			continue
+		}
+		logRecordPos := db.index.Get(key)
+		if logRecordPos == nil {
+			errs[idx] = ErrKeyNotFound
+			continue
+		}
+		lookups = append(lookups, lookup{idx: idx, pos: logRecordPos})
+	}
+
+	// group reads by file id and sort by offset
+	sort.Slice(lookups, func(i, j int) bool {
+		if lookups[i].pos.Fid != lookups[j].pos.Fid {
+			return lookups[i].pos.Fid < lookups[j].pos.Fid
+		}
+		return lookups[i].pos.Offset < lookups[j].pos.Offset
+	})
+	for _, l := range lookups {
+		values[l.idx], errs[l.idx] = db.getValueByPosition(l.pos)
+	}
+
+	return values, errs
+}
+
 // getValueByPosition retrieves the value of a key from the database by log record position.
 func (db *DB) getValueByPosition(logRecordPos *data.LogRecordPos) ([]byte, error) {
 	// lookup log record from data file identified by logRecordPos
@@ -549,6 +782,9 @@ func (db *DB) getValueByPosition(logRecordPos *data.LogRecordPos) ([]byte, error
 
 // ListKeys retrieves all keys in the database.
 func (db *DB) ListKeys() [][]byte {
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
 	iterator := db.index.Iterator(true)
 	defer iterator.Close()
 	keys := make([][]byte, db.index.Size())
@@ -581,6 +817,56 @@ func (db *DB) Fold(fn func(key []byte, value []byte) bool) error {
 	return nil
 }
 
+// FoldKeys applies a function to all keys with the given prefix in ascending order,
+// until the function returns false. Values are never read from the data files.
+func (db *DB) FoldKeys(prefix []byte, fn func(key []byte) bool) {
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+	db.foldKeys(prefix, fn)
+}
+
+// ListKeysWithPrefix retrieves the keys with the given prefix in ascending order.
+// A limit of 0 or less returns all of them.
+func (db *DB) ListKeysWithPrefix(prefix []byte, limit int) [][]byte {
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	var keys [][]byte
+	db.foldKeys(prefix, func(key []byte) bool {
+		keys = append(keys, copyBytes(key))
+		return limit <= 0 || len(keys) < limit
+	})
+	return keys
+}
+
+// Count returns the number of keys with the given prefix, an empty prefix counts all keys.
+func (db *DB) Count(prefix []byte) int {
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if len(prefix) == 0 {
+		return db.index.Size()
+	}
+	var count int
+	db.foldKeys(prefix, func(key []byte) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// foldKeys applies a function to all keys with the given prefix in ascending order.
+// Access this method needs db.mut is required.
+func (db *DB) foldKeys(prefix []byte, fn func(key []byte) bool) {
+	iterator := db.NewIterator(IteratorOptions{Prefix: prefix})
+	defer iterator.Close()
+	for ; iterator.Valid(); iterator.Next() {
+		if !fn(iterator.Key()) {
+			break
+		}
+	}
+}
+
 // loadSeqNo loads the current transaction sequence number from seqNoFile.
 func (db *DB) loadSeqNo() error {
 	fileName := filepath.Join(db.options.DirPath, data.SeqNoFileName)
@@ -588,7 +874,13 @@ func (db *DB) loadSeqNo() error {
 		return nil
 	}
 
-	seqNoFile, err := data.OpenSeqNoFile(db.options.DirPath)
+	var seqNoFile *data.DataFile
+	var err error
+	if db.options.ReadOnly {
+		seqNoFile, err = data.OpenFileReadOnly(fileName, 0)
+	} else {
+		seqNoFile, err = data.OpenSeqNoFile(db.options.DirPath)
+	}
 	if err != nil {
 		return err
 	}
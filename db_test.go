@@ -3,10 +3,15 @@ package go_kv
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"go-kv/fio"
 	"go-kv/utils"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 // 测试完成之后销毁 DB 数据目录
@@ -492,3 +497,555 @@ func TestDB_Sync(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_BytesPerSync(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bytes-per-sync")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	opts.BytesPerSync = 1024
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	tests := []struct {
+		name      string
+		valueSize int
+		wantSync  bool
+	}{
+		{
+			name:      "small write is not synced",
+			valueSize: 24,
+			wantSync:  false,
+		},
+		{
+			name:      "write over threshold is synced",
+			valueSize: 2048,
+			wantSync:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err = db.Put(utils.GetTestKey(1), utils.RandomValue(tt.valueSize)); err != nil {
+				t.Errorf("Put() error = %v", err)
+			}
+			if synced := db.bytesWrite == 0; synced != tt.wantSync {
+				t.Errorf("bytesWrite = %d, want synced %v", db.bytesWrite, tt.wantSync)
+			}
+		})
+	}
+}
+
+func TestDB_SyncInterval(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-sync-interval")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	opts.SyncInterval = 10 * time.Millisecond
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+
+	if err = db.Put(utils.GetTestKey(1), utils.RandomValue(24)); err != nil {
+		t.Errorf("Put() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	db.mut.RLock()
+	bytesWrite := db.bytesWrite
+	db.mut.RUnlock()
+	if bytesWrite != 0 {
+		t.Errorf("bytesWrite = %d, want 0 after background sync", bytesWrite)
+	}
+
+	syncDone := db.syncDone
+	destroyDB(db)
+	select {
+	case <-syncDone:
+	default:
+		t.Errorf("background sync goroutine is still running after Close()")
+	}
+}
+
+// failingSyncIOManager is an IOManager whose Sync fails until it is reset.
+type failingSyncIOManager struct {
+	fio.IOManager
+	err error
+}
+
+func (m *failingSyncIOManager) Sync() error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.IOManager.Sync()
+}
+
+func TestDB_SyncInterval_Error(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-sync-interval-error")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	opts.SyncInterval = 10 * time.Millisecond
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+
+	syncErr := errors.New("sync failed")
+	db.mut.Lock()
+	if err = db.setActiveFile(); err != nil {
+		t.Fatal(err)
+	}
+	ioManager := &failingSyncIOManager{IOManager: db.activeFile.IoManager, err: syncErr}
+	db.activeFile.IoManager = ioManager
+	db.mut.Unlock()
+	if err = db.Put(utils.GetTestKey(1), utils.RandomValue(24)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// the failed background sync is reported once, by the next write
+	db.mut.Lock()
+	ioManager.err = nil
+	db.mut.Unlock()
+	if err = db.Put(utils.GetTestKey(2), utils.RandomValue(24)); !errors.Is(err, syncErr) {
+		t.Errorf("Put() error = %v, want %v", err, syncErr)
+	}
+	if _, err = db.Get(utils.GetTestKey(2)); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, want %v for the rejected write", err, ErrKeyNotFound)
+	}
+	if err = db.Put(utils.GetTestKey(2), utils.RandomValue(24)); err != nil {
+		t.Errorf("Put() error = %v, want nil", err)
+	}
+
+	// or by Sync
+	db.mut.Lock()
+	ioManager.err = syncErr
+	db.mut.Unlock()
+	if err = db.Put(utils.GetTestKey(3), utils.RandomValue(24)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	db.mut.Lock()
+	ioManager.err = nil
+	db.mut.Unlock()
+	if err = db.Sync(); !errors.Is(err, syncErr) {
+		t.Errorf("Sync() error = %v, want %v", err, syncErr)
+	}
+	if err = db.Sync(); err != nil {
+		t.Errorf("Sync() error = %v, want nil", err)
+	}
+}
+
+func Test_checkOptions_SyncInterval(t *testing.T) {
+	opts := DefaultOptions
+	opts.SyncInterval = -time.Second
+	if err := checkOptions(opts); err == nil {
+		t.Errorf("checkOptions() error = nil, want error for negative SyncInterval")
+	}
+}
+
+func TestDB_MultiGet(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-multi-get")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	opts.DataFileSize = 4 * 1024
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	// spread keys over several data files and write them out of order
+	want := make(map[string][]byte)
+	for i := 100; i > 0; i-- {
+		value := utils.RandomValue(128)
+		if err = db.Put(utils.GetTestKey(i), value); err != nil {
+			t.Errorf("Put() error = %v", err)
+		}
+		want[string(utils.GetTestKey(i))] = value
+	}
+	if err = db.Delete(utils.GetTestKey(50)); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if len(db.olderFiles) == 0 {
+		t.Errorf("olderFiles length = 0, want more than 0")
+	}
+
+	tests := []struct {
+		name    string
+		keys    [][]byte
+		wantErr []error
+	}{
+		{
+			name:    "get keys from several files",
+			keys:    [][]byte{utils.GetTestKey(99), utils.GetTestKey(1), utils.GetTestKey(42)},
+			wantErr: []error{nil, nil, nil},
+		},
+		{
+			name:    "get missing, deleted and empty keys",
+			keys:    [][]byte{utils.GetTestKey(1000), utils.GetTestKey(50), nil, utils.GetTestKey(7)},
+			wantErr: []error{ErrKeyNotFound, ErrKeyNotFound, ErrKeyIsEmpty, nil},
+		},
+		{
+			name:    "get no keys",
+			keys:    nil,
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, errs := db.MultiGet(tt.keys)
+			if len(values) != len(tt.keys) || len(errs) != len(tt.keys) {
+				t.Errorf("MultiGet() got %d values and %d errors, want %d", len(values), len(errs), len(tt.keys))
+				return
+			}
+			for i, key := range tt.keys {
+				if !errors.Is(errs[i], tt.wantErr[i]) {
+					t.Errorf("MultiGet() errs[%d] = %v, want %v", i, errs[i], tt.wantErr[i])
+				}
+				if tt.wantErr[i] == nil && !bytes.Equal(values[i], want[string(key)]) {
+					t.Errorf("MultiGet() values[%d] = %s, want %s", i, values[i], want[string(key)])
+				}
+				if tt.wantErr[i] != nil && values[i] != nil {
+					t.Errorf("MultiGet() values[%d] = %s, want nil", i, values[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDB_FoldKeys_ListKeysWithPrefix_Count(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-fold-keys")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	for _, key := range []string{"user:1", "user:2", "user:3", "order:1", "order:2"} {
+		if err = db.Put([]byte(key), utils.RandomValue(24)); err != nil {
+			t.Errorf("Put() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		prefix    []byte
+		limit     int
+		wantKeys  []string
+		wantCount int
+	}{
+		{
+			name:      "prefix without limit",
+			prefix:    []byte("user:"),
+			wantKeys:  []string{"user:1", "user:2", "user:3"},
+			wantCount: 3,
+		},
+		{
+			name:      "prefix with limit",
+			prefix:    []byte("user:"),
+			limit:     2,
+			wantKeys:  []string{"user:1", "user:2"},
+			wantCount: 3,
+		},
+		{
+			name:      "empty prefix",
+			prefix:    nil,
+			limit:     1,
+			wantKeys:  []string{"order:1"},
+			wantCount: 5,
+		},
+		{
+			name:      "prefix without keys",
+			prefix:    []byte("session:"),
+			wantKeys:  nil,
+			wantCount: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, key := range db.ListKeysWithPrefix(tt.prefix, tt.limit) {
+				got = append(got, string(key))
+			}
+			if !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("ListKeysWithPrefix() got = %v, want %v", got, tt.wantKeys)
+			}
+
+			var folded []string
+			db.FoldKeys(tt.prefix, func(key []byte) bool {
+				folded = append(folded, string(key))
+				return tt.limit <= 0 || len(folded) < tt.limit
+			})
+			if !reflect.DeepEqual(folded, tt.wantKeys) {
+				t.Errorf("FoldKeys() got = %v, want %v", folded, tt.wantKeys)
+			}
+
+			if count := db.Count(tt.prefix); count != tt.wantCount {
+				t.Errorf("Count() got = %v, want %v", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestDB_Stat(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-stat")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	opts.DataFileSize = 64 * 1024
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+
+	for i := 0; i < 1000; i++ {
+		if err = db.Put(utils.GetTestKey(i), utils.RandomValue(128)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = db.Delete(utils.GetTestKey(0))
+	if _, err = db.CreateColumnFamily("users"); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := db.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.KeyNum != 999 {
+		t.Errorf("KeyNum = %d, want 999", stat.KeyNum)
+	}
+	if stat.DataFileNum < 2 {
+		t.Errorf("DataFileNum = %d, want at least 2", stat.DataFileNum)
+	}
+	if stat.ColumnFamilyNum != 1 {
+		t.Errorf("ColumnFamilyNum = %d, want 1", stat.ColumnFamilyNum)
+	}
+	if stat.DiskSize < 1000*128 {
+		t.Errorf("DiskSize = %d, want at least %d", stat.DiskSize, 1000*128)
+	}
+}
+
+// dirState describes every file under dir by its size and modification time.
+func dirState(t *testing.T, dir string) map[string]string {
+	state := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		state[path] = fmt.Sprintf("%d %v", info.Size(), info.ModTime())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestDB_OpenReadOnly(t *testing.T) {
+	for _, indexType := range []IndexType{Btree, BPlusTree} {
+		t.Run(fmt.Sprint(indexType), func(t *testing.T) {
+			opts := DefaultOptions
+			dir, _ := os.MkdirTemp("", "bitcask-go-read-only")
+			opts.DirPath = dir
+			opts.IndexType = indexType
+			db, err := Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cf, err := db.CreateColumnFamily("cf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 100; i++ {
+				if err = db.Put(utils.GetTestKey(i), utils.GetTestKey(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = cf.Put([]byte("name"), []byte("value")); err != nil {
+				t.Fatal(err)
+			}
+			if err = db.Delete(utils.GetTestKey(0)); err != nil {
+				t.Fatal(err)
+			}
+			// the merge output is applied by the next read-write Open
+			if err = db.Merge(); err != nil {
+				t.Fatal(err)
+			}
+			if err = db.Close(); err != nil {
+				t.Fatal(err)
+			}
+			mergePath := db.getMergePath()
+			defer func() {
+				_ = os.RemoveAll(dir)
+				_ = os.RemoveAll(mergePath)
+			}()
+			before, mergeBefore := dirState(t, dir), dirState(t, mergePath)
+			if len(mergeBefore) == 0 {
+				t.Fatal("Merge() left no merge output")
+			}
+
+			opts.ReadOnly = true
+			opts.SyncInterval = time.Millisecond
+			db, err = Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = db.Get(utils.GetTestKey(0)); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Get() error = %v, wantErr %v", err, ErrKeyNotFound)
+			}
+			if value, err := db.Get(utils.GetTestKey(99)); err != nil || !bytes.Equal(value, utils.GetTestKey(99)) {
+				t.Errorf("Get() = %q, %v, want %q", value, err, utils.GetTestKey(99))
+			}
+			if cf, err = db.ColumnFamily("cf"); err != nil {
+				t.Fatal(err)
+			}
+			if value, err := cf.Get([]byte("name")); err != nil || string(value) != "value" {
+				t.Errorf("ColumnFamily.Get() = %q, %v, want value", value, err)
+			}
+			if _, err = db.familyFile.IoManager.Write([]byte{0}); err == nil {
+				t.Error("column family catalog is writable, want it opened read-only")
+			}
+
+			writes := map[string]func() error{
+				"Put":                func() error { return db.Put([]byte("key"), []byte("value")) },
+				"Delete":             func() error { return db.Delete(utils.GetTestKey(99)) },
+				"ColumnFamily.Put":   func() error { return cf.Put([]byte("key"), []byte("value")) },
+				"CreateColumnFamily": func() error { _, err := db.CreateColumnFamily("other"); return err },
+				"DropColumnFamily":   func() error { return db.DropColumnFamily("cf") },
+				"Merge":              db.Merge,
+				"Backup":             func() error { return db.Backup(filepath.Join(dir, "backup")) },
+				"WriteBatch.Commit": func() error {
+					wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+					if err := wb.Put([]byte("key"), []byte("value")); err != nil {
+						return err
+					}
+					return wb.Commit()
+				},
+			}
+			for name, write := range writes {
+				if err = write(); !errors.Is(err, ErrReadOnly) {
+					t.Errorf("%s() error = %v, wantErr %v", name, err, ErrReadOnly)
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if err = db.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if after := dirState(t, dir); !reflect.DeepEqual(after, before) {
+				t.Errorf("files after read-only Open = %v, want %v", after, before)
+			}
+			if after := dirState(t, mergePath); !reflect.DeepEqual(after, mergeBefore) {
+				t.Errorf("merge files after read-only Open = %v, want %v", after, mergeBefore)
+			}
+		})
+	}
+
+	opts := DefaultOptions
+	opts.DirPath = filepath.Join(os.TempDir(), "bitcask-go-read-only-missing")
+	opts.ReadOnly = true
+	if _, err := Open(opts); err == nil {
+		t.Errorf("Open() error = nil, want error for a missing directory")
+	}
+	if _, err := os.Stat(opts.DirPath); !os.IsNotExist(err) {
+		t.Errorf("Stat() error = %v, want the directory not to be created", err)
+	}
+}
+
+// chmodTree sets the permissions of every file and directory under dir.
+func chmodTree(t *testing.T, dir string, filePerm, dirPerm os.FileMode) {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.Chmod(path, dirPerm)
+		}
+		return os.Chmod(path, filePerm)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_OpenReadOnly_ReadOnlyFiles(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions do not apply to root")
+	}
+	for _, indexType := range []IndexType{Btree, BPlusTree} {
+		t.Run(fmt.Sprint(indexType), func(t *testing.T) {
+			opts := DefaultOptions
+			dir, _ := os.MkdirTemp("", "bitcask-go-read-only-files")
+			defer func() {
+				chmodTree(t, dir, 0644, 0755)
+				_ = os.RemoveAll(dir)
+			}()
+			opts.DirPath = dir
+			opts.IndexType = indexType
+			db, err := Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cf, err := db.CreateColumnFamily("cf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 100; i++ {
+				if err = db.Put(utils.GetTestKey(i), utils.GetTestKey(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = cf.Put([]byte("name"), []byte("value")); err != nil {
+				t.Fatal(err)
+			}
+			if indexType != BPlusTree {
+				// applying the merge on the next Open leaves a hint and a merge finished file
+				if err = db.Merge(); err != nil {
+					t.Fatal(err)
+				}
+				if err = db.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if db, err = Open(opts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// a B+Tree database leaves a sequence number file
+			if err = db.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// as on a read-only mount, no file can be created or opened for writing
+			chmodTree(t, dir, 0444, 0555)
+			opts.ReadOnly = true
+			db, err = Open(opts)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer db.Close()
+			if value, err := db.Get(utils.GetTestKey(99)); err != nil || !bytes.Equal(value, utils.GetTestKey(99)) {
+				t.Errorf("Get() = %q, %v, want %q", value, err, utils.GetTestKey(99))
+			}
+			if cf, err = db.ColumnFamily("cf"); err != nil {
+				t.Fatal(err)
+			}
+			if value, err := cf.Get([]byte("name")); err != nil || string(value) != "value" {
+				t.Errorf("ColumnFamily.Get() = %q, %v, want value", value, err)
+			}
+		})
+	}
+}
@@ -3,12 +3,33 @@ package go_kv
 import "errors"
 
 var (
-	ErrKeyNotFound            = errors.New("key not found")
-	ErrKeyExists              = errors.New("key already exists")
-	ErrKeyIsEmpty             = errors.New("key is empty")
-	ErrIndexUpdateFailed      = errors.New("index update failed")
-	ErrDataFileNotFound       = errors.New("data file not found")
-	ErrDataDirectoryCorrupted = errors.New("data directory is corrupted")
-	ErrExceedMaxBatchNum      = errors.New("exceed max batch number")
-	ErrMergeIsProgress        = errors.New("merge is in progress, try again later")
+	ErrKeyNotFound             = errors.New("key not found")
+	ErrKeyExists               = errors.New("key already exists")
+	ErrKeyIsEmpty              = errors.New("key is empty")
+	ErrIndexUpdateFailed       = errors.New("index update failed")
+	ErrDataFileNotFound        = errors.New("data file not found")
+	ErrDataDirectoryCorrupted  = errors.New("data directory is corrupted")
+	ErrExceedMaxBatchNum       = errors.New("exceed max batch number")
+	ErrMergeIsProgress         = errors.New("merge is in progress, try again later")
+	ErrValueNotInteger         = errors.New("value is not an integer")
+	ErrIntegerOverflow         = errors.New("increment or decrement would overflow")
+	ErrInvalidRange            = errors.New("range start must be less than range end")
+	ErrColumnFamilyNameIsEmpty = errors.New("column family name is empty")
+	ErrColumnFamilyExists      = errors.New("column family already exists")
+	ErrColumnFamilyNotFound    = errors.New("column family not found")
+	ErrColumnFamilyDropped     = errors.New("column family is dropped")
+	ErrWrongType               = errors.New("operation against a key holding the wrong kind of value")
+	ErrScoreIsNaN              = errors.New("sorted set score is not a number")
+	ErrUnknownExportFormat     = errors.New("unknown export format")
+	ErrInvalidImportRecord     = errors.New("invalid import record")
+	ErrBackupIsProgress        = errors.New("backup is in progress, try again later")
+	ErrBackupDirNotEmpty       = errors.New("backup directory is not empty")
+	ErrBackupChainBroken       = errors.New("backup chain is broken")
+	ErrRestorePointNotFound    = errors.New("restore point not found in the data files")
+	ErrRestorePointMerged      = errors.New("restore point is before the last merge")
+	ErrWatchLagged             = errors.New("watcher fell too far behind the writes")
+	ErrTailPositionNotFound    = errors.New("tail position not found in the data files")
+	ErrTailPositionMerged      = errors.New("tail position is in a data file rewritten by merge")
+	ErrReadOnly                = errors.New("database is opened read-only")
+	ErrSeqNoFileNotFound       = errors.New("sequence number file not found, cannot create write batch")
 )
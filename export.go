@@ -0,0 +1,218 @@
+package go_kv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ExportFormat is the format of a logical export of the database.
+type ExportFormat int8
+
+const (
+	// JSONLines writes one JSON object per key-value pair:
+	// {"key":"...","value":"..."}. A key or value that is not valid UTF-8
+	// is written base64 encoded as key_base64 or value_base64 instead.
+	JSONLines ExportFormat = iota + 1
+	// CSV writes a key,value,encoding header and one row per key-value pair.
+	// The encoding column is base64 if both the key and the value are base64 encoded,
+	// which is the case when either of them is not valid UTF-8 or contains a carriage return.
+	CSV
+)
+
+var csvHeader = []string{"key", "value", "encoding"}
+
+// jsonRecord is one line of a JSON Lines export, exactly one of each plain and base64 field is set.
+type jsonRecord struct {
+	Key         *string `json:"key,omitempty"`
+	KeyBase64   []byte  `json:"key_base64,omitempty"`
+	Value       *string `json:"value,omitempty"`
+	ValueBase64 []byte  `json:"value_base64,omitempty"`
+}
+
+// Export writes every live key-value pair of the default column family to w in ascending key order.
+// Values are read with Fold, so writes wait until the export finishes.
+func (db *DB) Export(w io.Writer, format ExportFormat) error {
+	var write func(key, value []byte) error
+	var flush func() error
+	switch format {
+	case JSONLines:
+		bw := bufio.NewWriter(w)
+		encoder := json.NewEncoder(bw)
+		encoder.SetEscapeHTML(false)
+		write = func(key, value []byte) error {
+			return encoder.Encode(newJSONRecord(key, value))
+		}
+		flush = bw.Flush
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		write = func(key, value []byte) error {
+			if csvSafe(key) && csvSafe(value) {
+				return cw.Write([]string{string(key), string(value), ""})
+			}
+			return cw.Write([]string{
+				base64.StdEncoding.EncodeToString(key),
+				base64.StdEncoding.EncodeToString(value),
+				"base64",
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return ErrUnknownExportFormat
+	}
+
+	var writeErr error
+	if err := db.Fold(func(key, value []byte) bool {
+		writeErr = write(key, value)
+		return writeErr == nil
+	}); err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return flush()
+}
+
+// Import reads key-value pairs written by Export from r and puts them into the database.
+// Pairs are committed in WriteBatches of DefaultWriteBatchOptions.MaxBatchNum writes,
+// so an error leaves the batches committed before it in the database.
+func (db *DB) Import(r io.Reader, format ExportFormat) error {
+	var next func() (key, value []byte, err error)
+	switch format {
+	case JSONLines:
+		decoder := json.NewDecoder(r)
+		line := 0
+		next = func() ([]byte, []byte, error) {
+			line++
+			var record jsonRecord
+			if err := decoder.Decode(&record); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil, nil, err
+				}
+				return nil, nil, fmt.Errorf("record %d: %w: %v", line, ErrInvalidImportRecord, err)
+			}
+			key, value, ok := record.decode()
+			if !ok {
+				return nil, nil, fmt.Errorf("record %d: %w", line, ErrInvalidImportRecord)
+			}
+			return key, value, nil
+		}
+	case CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(csvHeader)
+		cr.ReuseRecord = true
+		header, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil || header[0] != csvHeader[0] || header[1] != csvHeader[1] || header[2] != csvHeader[2] {
+			return fmt.Errorf("header: %w", ErrInvalidImportRecord)
+		}
+		next = func() ([]byte, []byte, error) {
+			row, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return nil, nil, err
+			}
+			if err != nil {
+				// a csv.ParseError carries the line
+				return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportRecord, err)
+			}
+			line, _ := cr.FieldPos(0)
+			switch row[2] {
+			case "":
+				return []byte(row[0]), []byte(row[1]), nil
+			case "base64":
+				key, keyErr := base64.StdEncoding.DecodeString(row[0])
+				value, valueErr := base64.StdEncoding.DecodeString(row[1])
+				if keyErr == nil && valueErr == nil {
+					return key, value, nil
+				}
+			}
+			return nil, nil, fmt.Errorf("line %d: %w", line, ErrInvalidImportRecord)
+		}
+	default:
+		return ErrUnknownExportFormat
+	}
+
+	options := DefaultWriteBatchOptions
+	wb := db.NewWriteBatch(options)
+	var pending uint
+	for {
+		key, value, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = wb.Put(key, value); err != nil {
+			return err
+		}
+		// a key repeated within the batch is one pending write, so this may commit early
+		if pending++; pending == options.MaxBatchNum {
+			if err = wb.Commit(); err != nil {
+				return err
+			}
+			pending = 0
+		}
+	}
+	return wb.Commit()
+}
+
+// newJSONRecord returns the JSON Lines record of a key-value pair.
+func newJSONRecord(key, value []byte) *jsonRecord {
+	record := &jsonRecord{}
+	if utf8.Valid(key) {
+		s := string(key)
+		record.Key = &s
+	} else {
+		record.KeyBase64 = key
+	}
+	if utf8.Valid(value) {
+		s := string(value)
+		record.Value = &s
+	} else {
+		record.ValueBase64 = value
+	}
+	return record
+}
+
+// decode returns the key and value of the record, ok is false if either is missing.
+func (r *jsonRecord) decode() (key, value []byte, ok bool) {
+	switch {
+	case r.Key != nil:
+		key = []byte(*r.Key)
+	case r.KeyBase64 != nil:
+		key = r.KeyBase64
+	default:
+		return nil, nil, false
+	}
+	switch {
+	case r.Value != nil:
+		value = []byte(*r.Value)
+	case r.ValueBase64 != nil:
+		value = r.ValueBase64
+	default:
+		return nil, nil, false
+	}
+	return key, value, true
+}
+
+// csvSafe reports whether b survives a round trip through a CSV field unchanged.
+// The CSV reader turns \r\n inside quoted fields into \n.
+func csvSafe(b []byte) bool {
+	return utf8.Valid(b) && bytes.IndexByte(b, '\r') < 0
+}
@@ -0,0 +1,150 @@
+package go_kv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openExportTestDB opens an empty database in a temporary directory.
+func openExportTestDB(t *testing.T, name string) *DB {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-"+name)
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return db
+}
+
+func TestDB_ExportImport(t *testing.T) {
+	pairs := map[string]string{
+		"user:1":        "alice",
+		"user:2":        "line one\r\nline two",
+		"quote,\"key\"": "<b>&</b>",
+		"\xff\x00bin":   "\x00\x01\xfe",
+		"empty":         "",
+	}
+
+	tests := []struct {
+		name   string
+		format ExportFormat
+	}{
+		{name: "json lines", format: JSONLines},
+		{name: "csv", format: CSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := openExportTestDB(t, "export")
+			for key, value := range pairs {
+				if err := src.Put([]byte(key), []byte(value)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_ = src.Put([]byte("deleted"), []byte("x"))
+			_ = src.Delete([]byte("deleted"))
+
+			var buf bytes.Buffer
+			if err := src.Export(&buf, tt.format); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			dst := openExportTestDB(t, "import")
+			if err := dst.Import(&buf, tt.format); err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			_ = dst.Fold(func(key, value []byte) bool {
+				got[string(key)] = string(value)
+				return true
+			})
+			if !reflect.DeepEqual(got, pairs) {
+				t.Errorf("imported pairs = %q, want %q", got, pairs)
+			}
+		})
+	}
+}
+
+func TestDB_ExportFormats(t *testing.T) {
+	db := openExportTestDB(t, "export-formats")
+	_ = db.Put([]byte("a"), []byte("1"))
+	_ = db.Put([]byte("b"), []byte("\xff"))
+
+	var buf bytes.Buffer
+	if err := db.Export(&buf, JSONLines); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"key\":\"a\",\"value\":\"1\"}\n{\"key\":\"b\",\"value_base64\":\"/w==\"}\n"; buf.String() != want {
+		t.Errorf("Export(JSONLines) = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := db.Export(&buf, CSV); err != nil {
+		t.Fatal(err)
+	}
+	if want := "key,value,encoding\na,1,\nYg==,/w==,base64\n"; buf.String() != want {
+		t.Errorf("Export(CSV) = %q, want %q", buf.String(), want)
+	}
+
+	if err := db.Export(&buf, ExportFormat(0)); !errors.Is(err, ErrUnknownExportFormat) {
+		t.Errorf("Export() error = %v, wantErr %v", err, ErrUnknownExportFormat)
+	}
+}
+
+func TestDB_ImportBatches(t *testing.T) {
+	db := openExportTestDB(t, "import-batches")
+
+	// more pairs than fit in one batch
+	n := int(DefaultWriteBatchOptions.MaxBatchNum)*2 + 10
+	var sb strings.Builder
+	sb.WriteString("key,value,encoding\n")
+	for i := 0; i < n; i++ {
+		sb.WriteString(fmt.Sprintf("key-%05d,v,\n", i))
+	}
+	if err := db.Import(strings.NewReader(sb.String()), CSV); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Count(nil); got != n {
+		t.Errorf("Count() = %d, want %d", got, n)
+	}
+}
+
+func TestDB_ImportInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  ExportFormat
+		input   string
+		wantErr error
+	}{
+		{name: "json syntax", format: JSONLines, input: "{\"key\":\"a\",\"value\":\"1\"}\n{\"key\":", wantErr: ErrInvalidImportRecord},
+		{name: "json missing value", format: JSONLines, input: "{\"key\":\"a\"}\n", wantErr: ErrInvalidImportRecord},
+		{name: "json empty key", format: JSONLines, input: "{\"key\":\"\",\"value\":\"1\"}\n", wantErr: ErrKeyIsEmpty},
+		{name: "csv header", format: CSV, input: "k,v,e\na,1,\n", wantErr: ErrInvalidImportRecord},
+		{name: "csv fields", format: CSV, input: "key,value,encoding\na,1\n", wantErr: ErrInvalidImportRecord},
+		{name: "csv base64", format: CSV, input: "key,value,encoding\n!!,1,base64\n", wantErr: ErrInvalidImportRecord},
+		{name: "csv encoding", format: CSV, input: "key,value,encoding\na,1,hex\n", wantErr: ErrInvalidImportRecord},
+		{name: "unknown format", format: ExportFormat(9), wantErr: ErrUnknownExportFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openExportTestDB(t, "import-invalid")
+			if err := db.Import(strings.NewReader(tt.input), tt.format); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Import() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// nothing is committed when the first batch fails
+			if got := db.Count(nil); got != 0 {
+				t.Errorf("Count() = %d, want 0", got)
+			}
+		})
+	}
+}
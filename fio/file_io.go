@@ -19,6 +19,15 @@ func NewFileIOManager(filePath string) (*FileIO, error) {
 	return &FileIO{fd: fd}, nil
 }
 
+// NewReadOnlyFileIOManager opens an existing file for reading, writes to it fail.
+func NewReadOnlyFileIOManager(filePath string) (*FileIO, error) {
+	fd, err := os.OpenFile(filePath, os.O_RDONLY, DataFilePerm)
+	if err != nil {
+		return nil, err
+	}
+	return &FileIO{fd: fd}, nil
+}
+
 func (f FileIO) Read(bytes []byte, offset int64) (int, error) {
 	return f.fd.ReadAt(bytes, offset)
 }
@@ -1,11 +1,18 @@
 module go-kv
 
-go 1.22
+go 1.23
 
-require github.com/google/btree v1.1.2
+require (
+	github.com/google/btree v1.1.2
+	github.com/plar/go-adaptive-radix-tree v1.0.5
+	go.etcd.io/bbolt v1.3.10
+	google.golang.org/grpc v1.67.1
+	google.golang.org/protobuf v1.34.2
+)
 
 require (
-	github.com/plar/go-adaptive-radix-tree v1.0.5 // indirect
-	go.etcd.io/bbolt v1.3.10 // indirect
-	golang.org/x/sys v0.4.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.24.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
 )
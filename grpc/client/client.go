@@ -0,0 +1,183 @@
+// Package client is a Go client for the gRPC service of a go-kv database.
+//
+// The errors of the database are restored from the status codes of the server,
+// so errors.Is(err, go_kv.ErrKeyNotFound) works as it does against a local DB.
+package client
+
+import (
+	"context"
+	"errors"
+	go_kv "go-kv"
+	"go-kv/grpc/kvpb"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client calls the KV service over a gRPC connection.
+type Client struct {
+	kv kvpb.KVClient
+}
+
+// New creates a client using the connection. The connection is owned by the caller.
+func New(conn grpc.ClientConnInterface) *Client {
+	return &Client{kv: kvpb.NewKVClient(conn)}
+}
+
+// Get returns the value of a key, go_kv.ErrKeyNotFound if the key does not exist.
+func (c *Client) Get(ctx context.Context, key []byte) ([]byte, error) {
+	response, err := c.kv.Get(ctx, &kvpb.GetRequest{Key: key})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return response.Value, nil
+}
+
+// Put sets a key to a value.
+func (c *Client) Put(ctx context.Context, key, value []byte) error {
+	_, err := c.kv.Put(ctx, &kvpb.PutRequest{Key: key, Value: value})
+	return fromStatus(err)
+}
+
+// Delete deletes a key.
+func (c *Client) Delete(ctx context.Context, key []byte) error {
+	_, err := c.kv.Delete(ctx, &kvpb.DeleteRequest{Key: key})
+	return fromStatus(err)
+}
+
+// MultiGet returns the values of several keys in one call, in the same order as keys.
+// A key that is not found gets a nil value and go_kv.ErrKeyNotFound, as DB.MultiGet does.
+// The error is set if the call as a whole fails.
+func (c *Client) MultiGet(ctx context.Context, keys [][]byte) ([][]byte, []error, error) {
+	response, err := c.kv.MultiGet(ctx, &kvpb.MultiGetRequest{Keys: keys})
+	if err != nil {
+		return nil, nil, fromStatus(err)
+	}
+	values := make([][]byte, len(response.Results))
+	errs := make([]error, len(response.Results))
+	for i, result := range response.Results {
+		if result.Found {
+			values[i] = result.Value
+		} else {
+			errs[i] = go_kv.ErrKeyNotFound
+		}
+	}
+	return values, errs, nil
+}
+
+// ScanOptions selects the keys of a Scan, the fields match go_kv.IteratorOptions.
+type ScanOptions struct {
+	go_kv.IteratorOptions
+	KeysOnly bool // leave the values nil
+}
+
+// Scan calls fn for each pair selected by the options in key order until fn returns false.
+// Stopping early cancels the stream.
+func (c *Client) Scan(ctx context.Context, options ScanOptions, fn func(key, value []byte) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.kv.Scan(ctx, &kvpb.ScanRequest{
+		Prefix:     options.Prefix,
+		LowerBound: options.LowerBound,
+		UpperBound: options.UpperBound,
+		Reverse:    options.Reverse,
+		Limit:      int64(options.Limit),
+		KeysOnly:   options.KeysOnly,
+	})
+	if err != nil {
+		return fromStatus(err)
+	}
+	for {
+		kv, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fromStatus(err)
+		}
+		if !fn(kv.Key, kv.Value) {
+			return nil
+		}
+	}
+}
+
+// Batch buffers puts and deletes and sends them in one call, where they are applied atomically.
+type Batch struct {
+	c   *Client
+	ops []*kvpb.BatchOp
+}
+
+// NewBatch creates an empty batch.
+func (c *Client) NewBatch() *Batch {
+	return &Batch{c: c}
+}
+
+// Put buffers a put of a key.
+func (b *Batch) Put(key, value []byte) {
+	b.ops = append(b.ops, &kvpb.BatchOp{Kind: kvpb.BatchOp_KIND_PUT, Key: key, Value: value})
+}
+
+// Delete buffers a delete of a key.
+func (b *Batch) Delete(key []byte) {
+	b.ops = append(b.ops, &kvpb.BatchOp{Kind: kvpb.BatchOp_KIND_DELETE, Key: key})
+}
+
+// Commit sends the buffered operations, either all or none of them are applied.
+// The batch is empty afterwards and can be reused.
+func (b *Batch) Commit(ctx context.Context) error {
+	_, err := b.c.kv.Batch(ctx, &kvpb.BatchRequest{Ops: b.ops})
+	if err != nil {
+		return fromStatus(err)
+	}
+	b.ops = nil
+	return nil
+}
+
+// Merge merges the data files of the database.
+func (c *Client) Merge(ctx context.Context) error {
+	_, err := c.kv.Merge(ctx, &kvpb.MergeRequest{})
+	return fromStatus(err)
+}
+
+// Stat returns the statistics of the database.
+func (c *Client) Stat(ctx context.Context) (*go_kv.Stat, error) {
+	response, err := c.kv.Stat(ctx, &kvpb.StatRequest{})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return &go_kv.Stat{
+		KeyNum:          int(response.KeyNum),
+		DataFileNum:     int(response.DataFileNum),
+		ColumnFamilyNum: int(response.ColumnFamilyNum),
+		DiskSize:        response.DiskSize,
+	}, nil
+}
+
+// statusErrors are the database errors restored from status errors with the same code and message.
+var statusErrors = []struct {
+	code codes.Code
+	err  error
+}{
+	{code: codes.NotFound, err: go_kv.ErrKeyNotFound},
+	{code: codes.InvalidArgument, err: go_kv.ErrKeyIsEmpty},
+	{code: codes.InvalidArgument, err: go_kv.ErrExceedMaxBatchNum},
+	{code: codes.FailedPrecondition, err: go_kv.ErrMergeIsProgress},
+}
+
+// fromStatus converts a status error of the server back to the database error it was created from.
+// Other errors are returned unchanged.
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if err == nil || !ok {
+		return err
+	}
+	for _, e := range statusErrors {
+		if st.Code() == e.code && st.Message() == e.err.Error() {
+			return e.err
+		}
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+// Package grpc serves a go-kv database over gRPC, see kvpb/kv.proto for the service definition.
+//
+// Errors of the database are returned with the status code matching them:
+// NOT_FOUND for a missing key, INVALID_ARGUMENT for an empty key or an invalid request,
+// FAILED_PRECONDITION for a merge in progress and INTERNAL otherwise.
+package grpc
+
+import (
+	"context"
+	"errors"
+	go_kv "go-kv"
+	"go-kv/grpc/kvpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the KV service for a DB. The DB is owned by the caller and is not closed by the server.
+type Server struct {
+	kvpb.UnimplementedKVServer
+	db *go_kv.DB
+}
+
+// NewServer creates a server for the database.
+func NewServer(db *go_kv.DB) *Server {
+	return &Server{db: db}
+}
+
+// Register registers the KV service of the server on a gRPC server.
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	kvpb.RegisterKVServer(registrar, s)
+}
+
+func (s *Server) Get(_ context.Context, request *kvpb.GetRequest) (*kvpb.GetResponse, error) {
+	value, err := s.db.Get(request.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.GetResponse{Value: value}, nil
+}
+
+func (s *Server) Put(_ context.Context, request *kvpb.PutRequest) (*kvpb.PutResponse, error) {
+	if err := s.db.Put(request.Key, request.Value); err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.PutResponse{}, nil
+}
+
+func (s *Server) Delete(_ context.Context, request *kvpb.DeleteRequest) (*kvpb.DeleteResponse, error) {
+	if err := s.db.Delete(request.Key); err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.DeleteResponse{}, nil
+}
+
+// MultiGet reports missing keys as not found results, any other error of a key fails the whole call.
+func (s *Server) MultiGet(_ context.Context, request *kvpb.MultiGetRequest) (*kvpb.MultiGetResponse, error) {
+	values, errs := s.db.MultiGet(request.Keys)
+	response := &kvpb.MultiGetResponse{Results: make([]*kvpb.GetResult, len(values))}
+	for i, value := range values {
+		if errs[i] != nil && !errors.Is(errs[i], go_kv.ErrKeyNotFound) {
+			return nil, toStatus(errs[i])
+		}
+		response.Results[i] = &kvpb.GetResult{Found: errs[i] == nil, Value: value}
+	}
+	return response, nil
+}
+
+// Scan sends the selected pairs one message at a time and stops early when the client cancels the stream.
+func (s *Server) Scan(request *kvpb.ScanRequest, stream grpc.ServerStreamingServer[kvpb.KeyValue]) error {
+	if request.Limit < 0 {
+		return status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	iterator := s.db.NewIterator(go_kv.IteratorOptions{
+		Prefix:     request.Prefix,
+		Reverse:    request.Reverse,
+		LowerBound: request.LowerBound,
+		UpperBound: request.UpperBound,
+		Limit:      int(request.Limit),
+	})
+	defer iterator.Close()
+
+	ctx := stream.Context()
+	for ; iterator.Valid(); iterator.Next() {
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		kv := &kvpb.KeyValue{Key: iterator.Key()}
+		if !request.KeysOnly {
+			value, err := iterator.Value()
+			if err != nil {
+				return toStatus(err)
+			}
+			kv.Value = value
+		}
+		if err := stream.Send(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Batch applies all operations of the request in one WriteBatch, either all or none of them are applied.
+func (s *Server) Batch(_ context.Context, request *kvpb.BatchRequest) (*kvpb.BatchResponse, error) {
+	options := go_kv.DefaultWriteBatchOptions
+	if uint(len(request.Ops)) > options.MaxBatchNum {
+		return nil, toStatus(go_kv.ErrExceedMaxBatchNum)
+	}
+	wb := s.db.NewWriteBatch(options)
+	for i, op := range request.Ops {
+		var err error
+		switch op.Kind {
+		case kvpb.BatchOp_KIND_PUT:
+			err = wb.Put(op.Key, op.Value)
+		case kvpb.BatchOp_KIND_DELETE:
+			err = wb.Delete(op.Key)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "op %d: unknown kind %v", i, op.Kind)
+		}
+		if err != nil {
+			return nil, status.Errorf(status.Code(toStatus(err)), "op %d: %v", i, err)
+		}
+	}
+	if err := wb.Commit(); err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.BatchResponse{Applied: int64(len(request.Ops))}, nil
+}
+
+func (s *Server) Merge(_ context.Context, _ *kvpb.MergeRequest) (*kvpb.MergeResponse, error) {
+	if err := s.db.Merge(); err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.MergeResponse{}, nil
+}
+
+func (s *Server) Stat(_ context.Context, _ *kvpb.StatRequest) (*kvpb.StatResponse, error) {
+	stat, err := s.db.Stat()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.StatResponse{
+		KeyNum:          int64(stat.KeyNum),
+		DataFileNum:     int64(stat.DataFileNum),
+		ColumnFamilyNum: int64(stat.ColumnFamilyNum),
+		DiskSize:        stat.DiskSize,
+	}, nil
+}
+
+// toStatus converts an error of the database to a status error with the code matching it.
+func toStatus(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, go_kv.ErrKeyNotFound):
+		code = codes.NotFound
+	case errors.Is(err, go_kv.ErrKeyIsEmpty), errors.Is(err, go_kv.ErrExceedMaxBatchNum):
+		code = codes.InvalidArgument
+	case errors.Is(err, go_kv.ErrMergeIsProgress):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
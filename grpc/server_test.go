@@ -0,0 +1,178 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	go_kv "go-kv"
+	"go-kv/grpc/client"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startTestServer serves a database in a temporary directory on an in-process listener
+// and returns a client connected to it.
+func startTestServer(t *testing.T) *client.Client {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-grpc")
+	opts.DirPath = dir
+	opts.IndexType = go_kv.Btree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	NewServer(db).Register(server)
+	go func() { _ = server.Serve(listener) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Stop()
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return client.New(conn)
+}
+
+func TestServer_GetPutDelete(t *testing.T) {
+	c := startTestServer(t)
+	ctx := context.Background()
+
+	if err := c.Put(ctx, []byte("user:1"), []byte("alice")); err != nil {
+		t.Fatal(err)
+	}
+	value, err := c.Get(ctx, []byte("user:1"))
+	if err != nil || string(value) != "alice" {
+		t.Errorf("Get() = %q, %v, want %q, nil", value, err, "alice")
+	}
+	if err = c.Delete(ctx, []byte("user:1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get(ctx, []byte("user:1")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+	if err = c.Put(ctx, nil, []byte("v")); !errors.Is(err, go_kv.ErrKeyIsEmpty) {
+		t.Errorf("Put() error = %v, wantErr %v", err, go_kv.ErrKeyIsEmpty)
+	}
+}
+
+func TestServer_MultiGet(t *testing.T) {
+	c := startTestServer(t)
+	ctx := context.Background()
+	_ = c.Put(ctx, []byte("a"), []byte("1"))
+	_ = c.Put(ctx, []byte("c"), []byte("3"))
+
+	values, errs, err := c.MultiGet(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]byte{[]byte("1"), nil, []byte("3")}; !reflect.DeepEqual(values, want) {
+		t.Errorf("MultiGet() values = %q, want %q", values, want)
+	}
+	if want := []error{nil, go_kv.ErrKeyNotFound, nil}; !reflect.DeepEqual(errs, want) {
+		t.Errorf("MultiGet() errs = %v, want %v", errs, want)
+	}
+}
+
+func TestServer_Scan(t *testing.T) {
+	c := startTestServer(t)
+	ctx := context.Background()
+	for _, key := range []string{"a:1", "a:2", "a:3", "b:1"} {
+		_ = c.Put(ctx, []byte(key), []byte("v"+key))
+	}
+
+	tests := []struct {
+		name     string
+		options  client.ScanOptions
+		stopAt   int
+		wantKeys []string
+	}{
+		{name: "all", wantKeys: []string{"a:1", "a:2", "a:3", "b:1"}},
+		{name: "prefix", options: client.ScanOptions{IteratorOptions: go_kv.IteratorOptions{Prefix: []byte("a:")}}, wantKeys: []string{"a:1", "a:2", "a:3"}},
+		{name: "reverse limit", options: client.ScanOptions{IteratorOptions: go_kv.IteratorOptions{Reverse: true, Limit: 2}}, wantKeys: []string{"b:1", "a:3"}},
+		{name: "bounds keys only", options: client.ScanOptions{IteratorOptions: go_kv.IteratorOptions{LowerBound: []byte("a:2"), UpperBound: []byte("b")}, KeysOnly: true}, wantKeys: []string{"a:2", "a:3"}},
+		{name: "stop early", stopAt: 1, wantKeys: []string{"a:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			err := c.Scan(ctx, tt.options, func(key, value []byte) bool {
+				keys = append(keys, string(key))
+				wantValue := "v" + string(key)
+				if tt.options.KeysOnly {
+					wantValue = ""
+				}
+				if string(value) != wantValue {
+					t.Errorf("value of %s = %q, want %q", key, value, wantValue)
+				}
+				return len(keys) != tt.stopAt
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("Scan() keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestServer_Batch(t *testing.T) {
+	c := startTestServer(t)
+	ctx := context.Background()
+	_ = c.Put(ctx, []byte("old"), []byte("1"))
+
+	b := c.NewBatch()
+	b.Put([]byte("a"), []byte("1"))
+	b.Delete([]byte("old"))
+	if err := b.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, []byte("old")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+
+	// an invalid operation applies nothing
+	b.Put([]byte("b"), []byte("2"))
+	b.Put(nil, []byte("3"))
+	if err := b.Commit(ctx); err == nil {
+		t.Error("Commit() error = nil, want an error")
+	}
+	if _, err := c.Get(ctx, []byte("b")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+}
+
+func TestServer_MergeAndStat(t *testing.T) {
+	c := startTestServer(t)
+	ctx := context.Background()
+	_ = c.Put(ctx, []byte("a"), []byte("1"))
+	_ = c.Put(ctx, []byte("b"), []byte("2"))
+
+	if err := c.Merge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := c.Stat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.KeyNum != 2 || stat.DiskSize <= 0 {
+		t.Errorf("Stat() = %+v, want 2 keys and a positive disk size", stat)
+	}
+}
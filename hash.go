@@ -0,0 +1,184 @@
+package go_kv
+
+import (
+	"bytes"
+	"errors"
+)
+
+// HSet sets a field of the hash stored at key to the value, creating the hash if it does not exist.
+// The field and the hash metadata are written atomically in one WriteBatch.
+// It returns true if the field is new, false if an existing value was overwritten.
+func (db *DB) HSet(key, field, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return false, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return false, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureHash)
+	if err != nil {
+		return false, err
+	}
+
+	subKey := structureSubKey(key, meta.version, field)
+	isNew := dataCF.index.Get(subKey) == nil
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	if isNew {
+		meta.size++
+		if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+			return false, err
+		}
+	}
+	if err = wb.PutCF(dataCF, subKey, value); err != nil {
+		return false, err
+	}
+	return isNew, wb.commit()
+}
+
+// HGet retrieves the value of a field of the hash stored at key.
+// It returns ErrKeyNotFound if the hash or the field does not exist.
+func (db *DB) HGet(key, field []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return nil, ErrKeyNotFound
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return nil, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureHash)
+	if err != nil {
+		return nil, err
+	}
+	if meta.size == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return db.getFamilyValue(dataCF, structureSubKey(key, meta.version, field))
+}
+
+// HDel deletes a field of the hash stored at key.
+// It returns false if the hash or the field does not exist.
+func (db *DB) HDel(key, field []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return false, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return false, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureHash)
+	if err != nil {
+		return false, err
+	}
+	if meta.size == 0 {
+		return false, nil
+	}
+
+	subKey := structureSubKey(key, meta.version, field)
+	if dataCF.index.Get(subKey) == nil {
+		return false, nil
+	}
+	meta.size--
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+		return false, err
+	}
+	if err = wb.delete(dataCF.id, dataCF.index, subKey); err != nil {
+		return false, err
+	}
+	return true, wb.commit()
+}
+
+// HGetAll retrieves all fields and values of the hash stored at key in ascending field order.
+// It returns no fields if the hash does not exist.
+// The fields are read with a column family iterator after the metadata,
+// fields written or deleted meanwhile may or may not be returned.
+func (db *DB) HGetAll(key []byte) (fields [][]byte, values [][]byte, err error) {
+	if len(key) == 0 {
+		return nil, nil, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return nil, nil, nil
+	}
+
+	db.mut.RLock()
+	if metaCF.dropped || dataCF.dropped {
+		db.mut.RUnlock()
+		return nil, nil, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureHash)
+	db.mut.RUnlock()
+	if err != nil || meta.size == 0 {
+		return nil, nil, err
+	}
+
+	prefix := structureSubKey(key, meta.version, nil)
+	iterator := dataCF.NewIterator(IteratorOptions{Prefix: prefix})
+	defer iterator.Close()
+	for ; iterator.Valid(); iterator.Next() {
+		value, err := iterator.Value()
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, copyBytes(bytes.TrimPrefix(iterator.Key(), prefix)))
+		values = append(values, value)
+	}
+	return fields, values, nil
+}
+
+// HLen returns the number of fields of the hash stored at key, 0 if the hash does not exist.
+func (db *DB) HLen(key []byte) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return 0, nil
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureHash)
+	if err != nil {
+		return 0, err
+	}
+	return int(meta.size), nil
+}
+
+// HExists reports whether a field exists in the hash stored at key.
+func (db *DB) HExists(key, field []byte) (bool, error) {
+	_, err := db.HGet(key, field)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,226 @@
+package go_kv
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDB_Hash(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-hash")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		field     string
+		value     string
+		wantIsNew bool
+	}{
+		{name: "new field", field: "name", value: "alice", wantIsNew: true},
+		{name: "another new field", field: "age", value: "30", wantIsNew: true},
+		{name: "overwrite field", field: "name", value: "bob", wantIsNew: false},
+		{name: "empty field", field: "", value: "empty", wantIsNew: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isNew, err := db.HSet([]byte("user:1"), []byte(tt.field), []byte(tt.value))
+			if err != nil {
+				t.Errorf("HSet() error = %v", err)
+			}
+			if isNew != tt.wantIsNew {
+				t.Errorf("HSet() got = %v, want %v", isNew, tt.wantIsNew)
+			}
+			value, err := db.HGet([]byte("user:1"), []byte(tt.field))
+			if err != nil || string(value) != tt.value {
+				t.Errorf("HGet() got = %s, %v, want %s", value, err, tt.value)
+			}
+		})
+	}
+
+	_, _ = db.HSet([]byte("user:2"), []byte("name"), []byte("carol"))
+	if ok, _ := db.HDel([]byte("user:1"), []byte("")); !ok {
+		t.Errorf("HDel() got = false, want true")
+	}
+	if ok, _ := db.HDel([]byte("user:1"), []byte("missing")); ok {
+		t.Errorf("HDel() got = true, want false")
+	}
+	if ok, _ := db.HExists([]byte("user:1"), []byte("")); ok {
+		t.Errorf("HExists() got = true, want false")
+	}
+	if _, err = db.HGet([]byte("user:1"), []byte("")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("HGet() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+
+	// the hashes survive a restart
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+
+	fields, values, err := db.HGetAll([]byte("user:1"))
+	if err != nil {
+		t.Errorf("HGetAll() error = %v", err)
+	}
+	if want := [][]byte{[]byte("age"), []byte("name")}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("HGetAll() fields = %q, want %q", fields, want)
+	}
+	if want := [][]byte{[]byte("30"), []byte("bob")}; !reflect.DeepEqual(values, want) {
+		t.Errorf("HGetAll() values = %q, want %q", values, want)
+	}
+	if n, _ := db.HLen([]byte("user:1")); n != 2 {
+		t.Errorf("HLen() got = %d, want 2", n)
+	}
+	if ok, _ := db.HExists([]byte("user:1"), []byte("age")); !ok {
+		t.Errorf("HExists() got = false, want true")
+	}
+}
+
+func TestDB_DeleteStructure(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-delete-structure")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		destroyDB(db)
+	}()
+	// dataKeys returns the number of sub-keys of all data structures, reachable or not
+	dataKeys := func() int {
+		dataCF, err := db.ColumnFamily(structureDataFamily)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iterator := dataCF.NewIterator(DefaultIteratorOptions)
+		defer iterator.Close()
+		n := 0
+		for ; iterator.Valid(); iterator.Next() {
+			n++
+		}
+		return n
+	}
+
+	_, _ = db.HSet([]byte("user:1"), []byte("name"), []byte("alice"))
+	_, _ = db.HSet([]byte("user:1"), []byte("age"), []byte("30"))
+	_, _ = db.HSet([]byte("user:2"), []byte("name"), []byte("bob"))
+
+	if ok, err := db.DeleteStructure([]byte("user:1")); !ok || err != nil {
+		t.Errorf("DeleteStructure() got = %v, %v, want true", ok, err)
+	}
+	if ok, err := db.DeleteStructure([]byte("user:1")); ok || err != nil {
+		t.Errorf("DeleteStructure() got = %v, %v, want false", ok, err)
+	}
+	if n, _ := db.HLen([]byte("user:1")); n != 0 {
+		t.Errorf("HLen() got = %d, want 0", n)
+	}
+	// the fields of the old version are deleted, also after reopening
+	if n := dataKeys(); n != 1 {
+		t.Errorf("data keys = %d, want 1", n)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = Open(opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := dataKeys(); n != 1 {
+		t.Errorf("data keys after Open() = %d, want 1", n)
+	}
+
+	// a hash created again at the key does not see the old fields
+	if isNew, _ := db.HSet([]byte("user:1"), []byte("name"), []byte("dave")); !isNew {
+		t.Errorf("HSet() got = false, want true")
+	}
+	fields, _, _ := db.HGetAll([]byte("user:1"))
+	if want := [][]byte{[]byte("name")}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("HGetAll() fields = %q, want %q", fields, want)
+	}
+	if value, _ := db.HGet([]byte("user:2"), []byte("name")); string(value) != "bob" {
+		t.Errorf("HGet() got = %s, want bob", value)
+	}
+}
+
+func TestDB_StructureReadOnly(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-structure-read-only")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// no data structure was written, so reads find nothing instead of creating the column families
+	opts.ReadOnly = true
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	key := []byte("key")
+	if _, err = db.HGet(key, []byte("f")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("HGet() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if ok, err := db.HExists(key, []byte("f")); ok || err != nil {
+		t.Errorf("HExists() = %v, %v, want false, nil", ok, err)
+	}
+	if fields, _, err := db.HGetAll(key); fields != nil || err != nil {
+		t.Errorf("HGetAll() = %q, %v, want no fields", fields, err)
+	}
+	if n, err := db.HLen(key); n != 0 || err != nil {
+		t.Errorf("HLen() = %d, %v, want 0, nil", n, err)
+	}
+	if elements, err := db.LRange(key, 0, -1); elements != nil || err != nil {
+		t.Errorf("LRange() = %q, %v, want no elements", elements, err)
+	}
+	if n, err := db.LLen(key); n != 0 || err != nil {
+		t.Errorf("LLen() = %d, %v, want 0, nil", n, err)
+	}
+	if _, err = db.LIndex(key, 0); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("LIndex() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if ok, err := db.SIsMember(key, []byte("m")); ok || err != nil {
+		t.Errorf("SIsMember() = %v, %v, want false, nil", ok, err)
+	}
+	if members, err := db.SMembers(key); members != nil || err != nil {
+		t.Errorf("SMembers() = %q, %v, want no members", members, err)
+	}
+	if n, err := db.SCard(key); n != 0 || err != nil {
+		t.Errorf("SCard() = %d, %v, want 0, nil", n, err)
+	}
+	if _, err = db.ZScore(key, []byte("m")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("ZScore() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if members, err := db.ZRangeByScore(key, 0, 1); members != nil || err != nil {
+		t.Errorf("ZRangeByScore() = %v, %v, want no members", members, err)
+	}
+	if _, err = db.ZRank(key, []byte("m")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("ZRank() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+	if _, err = db.HSet(key, []byte("f"), []byte("v")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("HSet() error = %v, wantErr %v", err, ErrReadOnly)
+	}
+	if families := db.ColumnFamilies(); len(families) != 0 {
+		t.Errorf("ColumnFamilies() = %v, want none", families)
+	}
+}
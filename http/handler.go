@@ -0,0 +1,293 @@
+// Package http exposes a go-kv database over a JSON REST API.
+//
+//	GET    /kv/{key}                       value of a key as raw bytes
+//	PUT    /kv/{key}                       set a key to the request body
+//	DELETE /kv/{key}                       delete a key
+//	GET    /kv?prefix=&limit=&cursor=      list key-value pairs in ascending key order
+//	POST   /batch                          apply puts and deletes atomically
+//	POST   /admin/merge                    merge the data files
+//	GET    /admin/stats                    database statistics
+//
+// Keys and values are JSON strings in the list and batch bodies. A key or value that is not valid UTF-8
+// is base64 encoded as key_base64 or value_base64 instead, as in a JSON Lines export.
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	go_kv "go-kv"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	defaultListLimit = 100              // number of items listed when no limit is given
+	maxListLimit     = 1000             // maximum number of items listed at once
+	maxBodySize      = 64 * 1024 * 1024 // maximum size of a request body in bytes
+)
+
+// Handler serves the REST API for a DB. The DB is owned by the caller and is not closed by the handler.
+type Handler struct {
+	db  *go_kv.DB
+	mux *http.ServeMux
+}
+
+// Item is a key-value pair in a list response, exactly one of each plain and base64 field is set.
+type Item struct {
+	Key         *string `json:"key,omitempty"`
+	KeyBase64   []byte  `json:"key_base64,omitempty"`
+	Value       *string `json:"value,omitempty"`
+	ValueBase64 []byte  `json:"value_base64,omitempty"`
+}
+
+// ListResponse is the body of a list response.
+// NextCursor is empty on the last page, otherwise it is passed as cursor to get the next page.
+type ListResponse struct {
+	Items      []Item `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// BatchOp is one operation of a batch request, Op is either "put" or "delete".
+// The base64 fields take precedence over the plain ones for keys and values that are not valid UTF-8.
+type BatchOp struct {
+	Op          string `json:"op"`
+	Key         string `json:"key,omitempty"`
+	KeyBase64   []byte `json:"key_base64,omitempty"`
+	Value       string `json:"value,omitempty"`
+	ValueBase64 []byte `json:"value_base64,omitempty"`
+}
+
+// BatchRequest is the body of a batch request.
+type BatchRequest struct {
+	Ops []BatchOp `json:"ops"`
+}
+
+// BatchResponse is the body of a batch response.
+type BatchResponse struct {
+	Applied int `json:"applied"`
+}
+
+// StatsResponse is the body of a stats response.
+type StatsResponse struct {
+	KeyNum          int   `json:"key_num"`
+	DataFileNum     int   `json:"data_file_num"`
+	ColumnFamilyNum int   `json:"column_family_num"`
+	DiskSize        int64 `json:"disk_size"`
+}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler creates a handler serving the REST API for the database.
+func NewHandler(db *go_kv.DB) *Handler {
+	h := &Handler{db: db, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /kv/{key...}", h.get)
+	h.mux.HandleFunc("PUT /kv/{key...}", h.put)
+	h.mux.HandleFunc("DELETE /kv/{key...}", h.delete)
+	h.mux.HandleFunc("GET /kv", h.list)
+	h.mux.HandleFunc("POST /batch", h.batch)
+	h.mux.HandleFunc("POST /admin/merge", h.merge)
+	h.mux.HandleFunc("GET /admin/stats", h.stats)
+	return h
+}
+
+// ServeHTTP dispatches the request to the route matching its method and path.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	value, err := h.db.Get([]byte(r.PathValue("key")))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(value)
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err = h.db.Put([]byte(r.PathValue("key")), value); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.Delete([]byte(r.PathValue("key"))); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// list returns one page of the keys with the prefix, the cursor is the last key of the previous page.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultListLimit
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, maxListLimit)
+	}
+	options := go_kv.IteratorOptions{Prefix: []byte(query.Get("prefix"))}
+	if s := query.Get("cursor"); s != "" {
+		lastKey, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid cursor"})
+			return
+		}
+		// the smallest key after the last key of the previous page
+		options.LowerBound = append(lastKey, 0)
+	}
+
+	// one more item than the limit tells whether there is a next page
+	options.Limit = limit + 1
+	iterator := h.db.NewIterator(options)
+	defer iterator.Close()
+	response := ListResponse{Items: []Item{}}
+	var lastKey []byte
+	for ; iterator.Valid(); iterator.Next() {
+		if len(response.Items) == limit {
+			response.NextCursor = base64.RawURLEncoding.EncodeToString(lastKey)
+			break
+		}
+		value, err := iterator.Value()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		lastKey = append([]byte(nil), iterator.Key()...)
+		response.Items = append(response.Items, newItem(lastKey, value))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// batch applies all operations of the request in one WriteBatch, either all or none of them are applied.
+func (h *Handler) batch(w http.ResponseWriter, r *http.Request) {
+	var request BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid batch request: " + err.Error()})
+		return
+	}
+
+	options := go_kv.DefaultWriteBatchOptions
+	if uint(len(request.Ops)) > options.MaxBatchNum {
+		writeError(w, go_kv.ErrExceedMaxBatchNum)
+		return
+	}
+	wb := h.db.NewWriteBatch(options)
+	for i, op := range request.Ops {
+		var err error
+		switch op.Op {
+		case "put":
+			err = wb.Put(op.key(), op.value())
+		case "delete":
+			err = wb.Delete(op.key())
+		default:
+			err = errors.New("unknown op " + strconv.Quote(op.Op))
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "op " + strconv.Itoa(i) + ": " + err.Error()})
+			return
+		}
+	}
+	if err := wb.Commit(); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, BatchResponse{Applied: len(request.Ops)})
+}
+
+func (h *Handler) merge(w http.ResponseWriter, _ *http.Request) {
+	if err := h.db.Merge(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) stats(w http.ResponseWriter, _ *http.Request) {
+	stat, err := h.db.Stat()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, StatsResponse{
+		KeyNum:          stat.KeyNum,
+		DataFileNum:     stat.DataFileNum,
+		ColumnFamilyNum: stat.ColumnFamilyNum,
+		DiskSize:        stat.DiskSize,
+	})
+}
+
+// newItem returns the list item of a key-value pair.
+func newItem(key, value []byte) Item {
+	var item Item
+	if utf8.Valid(key) {
+		s := string(key)
+		item.Key = &s
+	} else {
+		item.KeyBase64 = key
+	}
+	if utf8.Valid(value) {
+		s := string(value)
+		item.Value = &s
+	} else {
+		item.ValueBase64 = value
+	}
+	return item
+}
+
+// key returns the key of the operation.
+func (op *BatchOp) key() []byte {
+	if op.KeyBase64 != nil {
+		return op.KeyBase64
+	}
+	return []byte(op.Key)
+}
+
+// value returns the value of the operation.
+func (op *BatchOp) value() []byte {
+	if op.ValueBase64 != nil {
+		return op.ValueBase64
+	}
+	return []byte(op.Value)
+}
+
+// writeError writes the error with the status code matching it.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, go_kv.ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, go_kv.ErrKeyIsEmpty), errors.Is(err, go_kv.ErrExceedMaxBatchNum):
+		status = http.StatusBadRequest
+	case errors.Is(err, go_kv.ErrReadOnly):
+		status = http.StatusForbidden
+	case errors.Is(err, go_kv.ErrMergeIsProgress), errors.Is(err, go_kv.ErrColumnFamilyDropped):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// writeJSON writes the body as JSON with the status code.
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,276 @@
+package http
+
+import (
+	"encoding/json"
+	go_kv "go-kv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestHandler opens a database in a temporary directory and returns a handler for it.
+func newTestHandler(t *testing.T) *Handler {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-http")
+	opts.DirPath = dir
+	opts.IndexType = go_kv.Btree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return NewHandler(db)
+}
+
+// serve sends the request to the handler and returns the recorded response.
+func serve(h *Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestHandler_KV(t *testing.T) {
+	h := newTestHandler(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "put", method: http.MethodPut, target: "/kv/user:1", body: "alice", wantStatus: http.StatusNoContent},
+		{name: "get", method: http.MethodGet, target: "/kv/user:1", wantStatus: http.StatusOK, wantBody: "alice"},
+		{name: "put key with slash", method: http.MethodPut, target: "/kv/dir/file", body: "data", wantStatus: http.StatusNoContent},
+		{name: "get key with slash", method: http.MethodGet, target: "/kv/dir/file", wantStatus: http.StatusOK, wantBody: "data"},
+		{name: "get missing", method: http.MethodGet, target: "/kv/user:9", wantStatus: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, target: "/kv/user:1", wantStatus: http.StatusNoContent},
+		{name: "get deleted", method: http.MethodGet, target: "/kv/user:1", wantStatus: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPost, target: "/kv/user:1", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, tt.method, tt.target, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	h := newTestHandler(t)
+	for _, key := range []string{"a:1", "a:2", "a:3", "b:1"} {
+		serve(h, http.MethodPut, "/kv/"+key, "v"+key)
+	}
+
+	// walk all pages of the prefix with the cursor
+	var keys []string
+	target := "/kv?prefix=a:&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatal("too many pages")
+		}
+		w := serve(h, http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+		}
+		var response ListResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range response.Items {
+			if item.Key == nil || item.Value == nil {
+				t.Fatalf("item = %+v, want a plain key and value", item)
+			}
+			if *item.Value != "v"+*item.Key {
+				t.Errorf("value of %s = %s, want %s", *item.Key, *item.Value, "v"+*item.Key)
+			}
+			keys = append(keys, *item.Key)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		target = "/kv?prefix=a:&limit=2&cursor=" + response.NextCursor
+	}
+	if want := []string{"a:1", "a:2", "a:3"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	for _, target := range []string{"/kv?limit=0", "/kv?limit=x", "/kv?cursor=!"} {
+		if w := serve(h, http.MethodGet, target, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want %d", target, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandler_Batch(t *testing.T) {
+	h := newTestHandler(t)
+	serve(h, http.MethodPut, "/kv/old", "1")
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantKeys   []string
+	}{
+		{
+			name:       "apply",
+			body:       `{"ops":[{"op":"put","key":"a","value":"1"},{"op":"put","key":"b","value":"2"},{"op":"delete","key":"old"}]}`,
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"a", "b"},
+		},
+		{
+			name:       "unknown op applies nothing",
+			body:       `{"ops":[{"op":"put","key":"c","value":"3"},{"op":"incr","key":"a"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantKeys:   []string{"a", "b"},
+		},
+		{
+			name:       "empty key applies nothing",
+			body:       `{"ops":[{"op":"delete","key":"a"},{"op":"put","key":"","value":"3"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantKeys:   []string{"a", "b"},
+		},
+		{
+			name:       "invalid json",
+			body:       `{"ops":`,
+			wantStatus: http.StatusBadRequest,
+			wantKeys:   []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodPost, "/batch", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var keys []string
+			for _, key := range h.db.ListKeys() {
+				keys = append(keys, string(key))
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestHandler_Binary(t *testing.T) {
+	h := newTestHandler(t)
+	key, value := []byte{'b', 0xff}, []byte{0xfe, 0x00, 0xff}
+
+	// written with the base64 fields of a batch
+	body, _ := json.Marshal(BatchRequest{Ops: []BatchOp{
+		{Op: "put", KeyBase64: key, ValueBase64: value},
+		{Op: "put", Key: "a", Value: ""},
+	}})
+	if w := serve(h, http.MethodPost, "/batch", string(body)); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	// and listed with them, the plain key of the empty value is kept
+	w := serve(h, http.MethodGet, "/kv", "")
+	var response ListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	empty, name := "", "a"
+	want := []Item{{Key: &name, Value: &empty}, {KeyBase64: key, ValueBase64: value}}
+	if !reflect.DeepEqual(response.Items, want) {
+		t.Errorf("items = %s, want %+v", w.Body, want)
+	}
+}
+
+func TestHandler_Admin(t *testing.T) {
+	h := newTestHandler(t)
+	serve(h, http.MethodPut, "/kv/a", "1")
+	serve(h, http.MethodPut, "/kv/a", "2")
+	serve(h, http.MethodPut, "/kv/b", "3")
+
+	if w := serve(h, http.MethodPost, "/admin/merge", ""); w.Code != http.StatusNoContent {
+		t.Errorf("merge status = %d, want %d, body %s", w.Code, http.StatusNoContent, w.Body)
+	}
+
+	w := serve(h, http.MethodGet, "/admin/stats", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("stats status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+	}
+	var stats StatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.KeyNum != 2 || stats.DataFileNum < 1 || stats.DiskSize <= 0 {
+		t.Errorf("stats = %+v, want 2 keys on disk", stats)
+	}
+}
+
+func TestHandler_ReadOnly(t *testing.T) {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-http")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+	opts.IndexType = go_kv.Btree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(db)
+	serve(h, http.MethodPut, "/kv/a", "1")
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.ReadOnly = true
+	db, err = go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	h = NewHandler(db)
+
+	if w := serve(h, http.MethodGet, "/kv/a", ""); w.Code != http.StatusOK || w.Body.String() != "1" {
+		t.Errorf("get = %d %q, want %d %q", w.Code, w.Body, http.StatusOK, "1")
+	}
+	if w := serve(h, http.MethodPut, "/kv/a", "2"); w.Code != http.StatusForbidden {
+		t.Errorf("put status = %d, want %d, body %s", w.Code, http.StatusForbidden, w.Body)
+	}
+	if w := serve(h, http.MethodPost, "/admin/merge", ""); w.Code != http.StatusForbidden {
+		t.Errorf("merge status = %d, want %d, body %s", w.Code, http.StatusForbidden, w.Body)
+	}
+}
+
+func Test_writeError(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{err: go_kv.ErrKeyNotFound, wantStatus: http.StatusNotFound},
+		{err: go_kv.ErrKeyIsEmpty, wantStatus: http.StatusBadRequest},
+		{err: go_kv.ErrReadOnly, wantStatus: http.StatusForbidden},
+		{err: go_kv.ErrMergeIsProgress, wantStatus: http.StatusConflict},
+		{err: go_kv.ErrColumnFamilyDropped, wantStatus: http.StatusConflict},
+		{err: go_kv.ErrDataDirectoryCorrupted, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, tt.err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
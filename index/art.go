@@ -4,7 +4,6 @@ import (
 	"bytes"
 	goart "github.com/plar/go-adaptive-radix-tree"
 	"go-kv/data"
-	"sort"
 	"sync"
 )
 
@@ -60,9 +59,7 @@ func (art *AdaptiveRadixTree) Size() int {
 
 // Iterator returns an Iterator over the key-value pairs in the ART.
 func (art *AdaptiveRadixTree) Iterator(reverse bool) Iterator {
-	art.lock.RLock()
-	defer art.lock.RUnlock()
-	return newArtIterator(art.tree, reverse)
+	return newArtIterator(art.tree, art.lock, reverse)
 }
 
 // Close releases any resources associated with the BTree.
@@ -70,69 +67,281 @@ func (art *AdaptiveRadixTree) Close() error {
 	return nil
 }
 
+// artChunkSize is the number of keys an artIterator reads from the tree at a time.
+const artChunkSize = 128
+
 // artIterator is an iterator for the AdaptiveRadixTree.
+// go-adaptive-radix-tree can neither seek nor walk backwards, so the iterator reads the live tree
+// in chunks of up to artChunkSize keys starting from a key, using ForEachPrefix on the prefixes
+// that cover the keys after or before it. Reading a chunk costs the chunk size plus at most
+// a few prefix lookups per byte of the start key, so the cost of iterating is proportional
+// to the number of keys visited. Writes show up in the chunks read after them.
 type artIterator struct {
-	currIndex int     // current index in the BTree
+	tree goart.Tree
+	lock *sync.RWMutex
+
+	currIndex int     // current index in values
 	reverse   bool    // whether to iterate in reverse order
-	values    []*Item // slice of values in the BTree
+	values    []*Item // current chunk, in ascending key order if up, otherwise descending
+	up        bool    // whether the chunk was read in ascending key order
+	more      bool    // whether keys may follow the last key of the chunk
+
+	// remember which end the iterator has moved past, so that it can step back onto it
+	beforeFirst bool
+	afterLast   bool
 }
 
 // newArtIterator creates a new iterator for the AdaptiveRadixTree.
-func newArtIterator(tree goart.Tree, reverse bool) *artIterator {
-	var idx int
-	if reverse {
-		idx = tree.Size() - 1
-	}
-	values := make([]*Item, tree.Size())
-	saveValues := func(node goart.Node) bool {
-		item := &Item{
-			Key: node.Key(),
-			pos: node.Value().(*data.LogRecordPos),
-		}
-		values[idx] = item
-		if reverse {
-			idx--
-		} else {
-			idx++
-		}
-		return true
+func newArtIterator(tree goart.Tree, lock *sync.RWMutex, reverse bool) *artIterator {
+	artIter := &artIterator{
+		tree:    tree,
+		lock:    lock,
+		reverse: reverse,
 	}
+	artIter.Rewind()
+	return artIter
+}
+
+// Rewind resets the iterator to the beginning of the ART.
+func (artIter *artIterator) Rewind() {
+	artIter.First()
+}
+
+// Seek moves the iterator to the position of the first key greater than or equal to the given key.
+func (artIter *artIterator) Seek(key []byte) {
+	artIter.load(!artIter.reverse, seekKey(key), true)
+}
 
-	tree.ForEach(saveValues)
+// SeekForPrev moves the iterator to the position of the last key less than or equal to the given key,
+// or greater than or equal to the given key for a reverse iterator.
+func (artIter *artIterator) SeekForPrev(key []byte) {
+	artIter.load(artIter.reverse, seekKey(key), true)
+}
 
-	return &artIterator{
-		currIndex: 0,
-		reverse:   reverse,
-		values:    values,
+// seekKey returns the key to load from for a seek, a nil key to load means no bound.
+func seekKey(key []byte) []byte {
+	if key == nil {
+		return []byte{}
 	}
+	return key
 }
 
-// Rewind resets the iterator to the beginning of the BTree.
-func (artIter *artIterator) Rewind() {
+// First moves the iterator to the first position in iteration order.
+func (artIter *artIterator) First() {
+	artIter.load(!artIter.reverse, nil, true)
+}
+
+// Last moves the iterator to the last position in iteration order.
+func (artIter *artIterator) Last() {
+	artIter.load(artIter.reverse, nil, true)
+}
+
+// Next moves the iterator to the next position.
+func (artIter *artIterator) Next() {
+	artIter.step(!artIter.reverse)
+}
+
+// Prev moves the iterator to the previous position.
+func (artIter *artIterator) Prev() {
+	artIter.step(artIter.reverse)
+}
+
+// step moves the iterator one key up or down in key order.
+func (artIter *artIterator) step(up bool) {
+	switch {
+	case up && artIter.beforeFirst, !up && artIter.afterLast:
+		artIter.load(up, nil, true)
+	case !artIter.Valid():
+		return
+	case artIter.up == up && artIter.currIndex+1 < len(artIter.values):
+		artIter.currIndex++
+	case artIter.up != up && artIter.currIndex > 0:
+		artIter.currIndex--
+	case artIter.up == up && !artIter.more:
+		artIter.values = nil
+		artIter.afterLast, artIter.beforeFirst = up, !up
+	default:
+		artIter.load(up, artIter.Key(), false)
+	}
+}
+
+// load reads the chunk of keys after the given key, or before it if up is false, and moves
+// the iterator to its first key. A nil key starts from the smallest or the largest key.
+func (artIter *artIterator) load(up bool, key []byte, inclusive bool) {
+	artIter.lock.RLock()
+	if up {
+		artIter.values = artIter.ascend(key, inclusive)
+	} else {
+		artIter.values = artIter.descend(key, inclusive)
+	}
+	artIter.lock.RUnlock()
+
 	artIter.currIndex = 0
+	artIter.up = up
+	artIter.more = len(artIter.values) == artChunkSize
+	empty := len(artIter.values) == 0
+	artIter.afterLast, artIter.beforeFirst = empty && up, empty && !up
 }
 
-// Seek moves the iterator to the position of the first key greater than or equal to the given key.
-func (artIter *artIterator) Seek(key []byte) {
-	if artIter.reverse {
-		artIter.currIndex = sort.Search(len(artIter.values), func(i int) bool {
-			return bytes.Compare(artIter.values[i].Key, key) <= 0
+// ascend returns up to artChunkSize items with keys greater than the given key, or equal to it
+// if inclusive, in ascending order. Those are the keys starting with the given key, then
+// for every shorter prefix of it, the keys starting with that prefix and a greater next byte.
+func (artIter *artIterator) ascend(key []byte, inclusive bool) []*Item {
+	start := key
+	if !inclusive {
+		// the key followed by a zero byte is the smallest key greater than the key
+		start = append(key[:len(key):len(key)], 0)
+	}
+	items := make([]*Item, 0, artChunkSize)
+	items = artIter.appendPrefix(items, start, artChunkSize)
+	for i := len(start) - 1; i >= 0 && len(items) < artChunkSize; i-- {
+		items = artIter.appendGreater(items, start[:i], start[i])
+	}
+	return items
+}
+
+// descend returns up to artChunkSize items with keys less than the given key, or equal to it
+// if inclusive, in descending order. A nil key returns the largest keys of the tree.
+// Those are, for every prefix of the key, the keys starting with the prefix and a smaller next byte
+// followed by the prefix itself.
+func (artIter *artIterator) descend(key []byte, inclusive bool) []*Item {
+	items := make([]*Item, 0, artChunkSize)
+	if key == nil {
+		return artIter.appendPrefixReverse(items, nil)
+	}
+	if inclusive {
+		items = artIter.appendKey(items, key)
+	}
+	for i := len(key) - 1; i >= 0 && len(items) < artChunkSize; i-- {
+		items = artIter.appendLess(items, key[:i], key[i])
+	}
+	return items
+}
+
+// appendGreater appends the items with keys starting with prefix and a next byte greater than c
+// in ascending order, until there are artChunkSize items.
+func (artIter *artIterator) appendGreater(items []*Item, prefix []byte, c byte) []*Item {
+	// walk the subtree of the prefix past the smaller keys, unless there are too many of them
+	skipped := 0
+	artIter.tree.ForEachPrefix(prefix, func(node goart.Node) bool {
+		if node.Kind() != goart.Leaf || !bytes.HasPrefix(node.Key(), prefix) {
+			return true
+		}
+		if key := node.Key(); len(key) <= len(prefix) || key[len(prefix)] <= c {
+			skipped++
+			return skipped <= artChunkSize
+		}
+		items = append(items, &Item{
+			Key: node.Key(),
+			pos: node.Value().(*data.LogRecordPos),
 		})
-	} else {
-		artIter.currIndex = sort.Search(len(artIter.values), func(i int) bool {
-			return bytes.Compare(artIter.values[i].Key, key) >= 0
+		return len(items) < artChunkSize
+	})
+	if skipped <= artChunkSize {
+		return items
+	}
+	// otherwise look up every greater next byte
+	for next := int(c) + 1; next <= 0xff && len(items) < artChunkSize; next++ {
+		items = artIter.appendPrefix(items, append(prefix[:len(prefix):len(prefix)], byte(next)), artChunkSize)
+	}
+	return items
+}
+
+// appendLess appends the items with keys starting with prefix and a next byte less than c,
+// followed by the item of the prefix itself, in descending order, until there are artChunkSize items.
+func (artIter *artIterator) appendLess(items []*Item, prefix []byte, c byte) []*Item {
+	// read the smaller keys of the subtree of the prefix at once, unless there are too many of them
+	var less []*Item
+	artIter.tree.ForEachPrefix(prefix, func(node goart.Node) bool {
+		if node.Kind() != goart.Leaf || !bytes.HasPrefix(node.Key(), prefix) {
+			return true
+		}
+		if key := node.Key(); len(key) > len(prefix) && key[len(prefix)] >= c {
+			return false
+		}
+		less = append(less, &Item{
+			Key: node.Key(),
+			pos: node.Value().(*data.LogRecordPos),
 		})
+		return len(less) <= artChunkSize
+	})
+	if len(less) <= artChunkSize {
+		for i := len(less) - 1; i >= 0 && len(items) < artChunkSize; i-- {
+			items = append(items, less[i])
+		}
+		return items
+	}
+	// otherwise split them by every smaller next byte
+	for next := int(c) - 1; next >= 0 && len(items) < artChunkSize; next-- {
+		items = artIter.appendPrefixReverse(items, append(prefix[:len(prefix):len(prefix)], byte(next)))
 	}
+	if len(items) < artChunkSize {
+		items = artIter.appendKey(items, prefix)
+	}
+	return items
 }
 
-// Next moves the iterator to the next position.
-func (artIter *artIterator) Next() {
-	artIter.currIndex += 1
+// appendPrefix appends the items with keys starting with prefix in ascending order, until there are n items.
+func (artIter *artIterator) appendPrefix(items []*Item, prefix []byte, n int) []*Item {
+	if len(items) >= n {
+		return items
+	}
+	if prefix == nil {
+		// ForEachPrefix visits nothing for a nil prefix
+		prefix = []byte{}
+	}
+	artIter.tree.ForEachPrefix(prefix, func(node goart.Node) bool {
+		// the callback is also called for inner nodes
+		if node.Kind() != goart.Leaf || !bytes.HasPrefix(node.Key(), prefix) {
+			return true
+		}
+		items = append(items, &Item{
+			Key: node.Key(),
+			pos: node.Value().(*data.LogRecordPos),
+		})
+		return len(items) < n
+	})
+	return items
+}
+
+// appendPrefixReverse appends the items with keys starting with prefix in descending order,
+// until there are artChunkSize items. A subtree of up to artChunkSize keys is read at once,
+// a larger one is split by the byte after the prefix, largest first.
+func (artIter *artIterator) appendPrefixReverse(items []*Item, prefix []byte) []*Item {
+	if len(items) >= artChunkSize {
+		return items
+	}
+	subtree := artIter.appendPrefix(nil, prefix, artChunkSize+1)
+	if len(subtree) <= artChunkSize {
+		for i := len(subtree) - 1; i >= 0 && len(items) < artChunkSize; i-- {
+			items = append(items, subtree[i])
+		}
+		return items
+	}
+	for c := 0xff; c >= 0 && len(items) < artChunkSize; c-- {
+		items = artIter.appendPrefixReverse(items, append(prefix[:len(prefix):len(prefix)], byte(c)))
+	}
+	if len(items) < artChunkSize {
+		items = artIter.appendKey(items, prefix)
+	}
+	return items
+}
+
+// appendKey appends the item of the given key if it is in the tree.
+func (artIter *artIterator) appendKey(items []*Item, key []byte) []*Item {
+	value, found := artIter.tree.Search(key)
+	if !found {
+		return items
+	}
+	return append(items, &Item{
+		Key: key,
+		pos: value.(*data.LogRecordPos),
+	})
 }
 
 // Valid returns whether the iterator is currently pointing to a valid position.
 func (artIter *artIterator) Valid() bool {
-	return artIter.currIndex < len(artIter.values)
+	return artIter.currIndex >= 0 && artIter.currIndex < len(artIter.values)
 }
 
 // Key returns the current key.
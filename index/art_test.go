@@ -4,6 +4,7 @@ import (
 	art "github.com/plar/go-adaptive-radix-tree"
 	"go-kv/data"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -400,6 +401,7 @@ func Test_artIterator_Value(t *testing.T) {
 func Test_newArtIterator(t *testing.T) {
 	type args struct {
 		tree    art.Tree
+		lock    *sync.RWMutex
 		reverse bool
 	}
 	tests := []struct {
@@ -411,7 +413,7 @@ func Test_newArtIterator(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := newArtIterator(tt.args.tree, tt.args.reverse); !reflect.DeepEqual(got, tt.want) {
+			if got := newArtIterator(tt.args.tree, tt.args.lock, tt.args.reverse); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("newArtIterator() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1,9 +1,13 @@
 package index
 
 import (
+	"bytes"
+	"fmt"
 	"go-kv/data"
 	"go.etcd.io/bbolt"
+	"os"
 	"path/filepath"
+	"time"
 )
 
 const bptreeIndexFileName = "bptree-index"
@@ -38,6 +42,34 @@ func NewBPlusTree(dirPath string, syncWrites bool) *BPlusTree {
 	return &BPlusTree{tree: tree}
 }
 
+// BPlusTreeExists reports whether dirPath holds a B+ tree index file.
+func BPlusTreeExists(dirPath string) bool {
+	_, err := os.Stat(filepath.Join(dirPath, bptreeIndexFileName))
+	return err == nil
+}
+
+// OpenBPlusTreeReadOnly opens an existing B+ tree index for reading, Put and Delete on it panic.
+// It gives up after a second while a process holding the index for writing keeps it locked.
+func OpenBPlusTreeReadOnly(dirPath string) (*BPlusTree, error) {
+	opts := *bbolt.DefaultOptions
+	opts.ReadOnly = true
+	opts.Timeout = time.Second
+	tree, err := bbolt.Open(filepath.Join(dirPath, bptreeIndexFileName), 0644, &opts)
+	if err != nil {
+		return nil, fmt.Errorf("open b+ tree index: %w", err)
+	}
+	if err = tree.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket(indexBucketName) == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		return nil
+	}); err != nil {
+		_ = tree.Close()
+		return nil, fmt.Errorf("open b+ tree index: %w", err)
+	}
+	return &BPlusTree{tree: tree}, nil
+}
+
 // Put inserts a key-value pair into the index.
 // If the key already exists, it returns false and the value is not updated.
 // Otherwise, it returns true and the value is updated.
@@ -110,6 +142,44 @@ func (B *BPlusTree) Close() error {
 	return B.tree.Close()
 }
 
+// BPlusTreeSnapshot is a read transaction of a B+ tree index, writes made after it began are not part of it.
+// It must be closed, the index file cannot grow while it is open.
+type BPlusTreeSnapshot struct {
+	tx *bbolt.Tx
+}
+
+// Snapshot begins a read transaction of the index.
+func (B *BPlusTree) Snapshot() (*BPlusTreeSnapshot, error) {
+	tx, err := B.tree.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &BPlusTreeSnapshot{tx: tx}, nil
+}
+
+// CopyTo writes the index file as of the snapshot to the given directory.
+func (s *BPlusTreeSnapshot) CopyTo(dirPath string) error {
+	return s.tx.CopyFile(filepath.Join(dirPath, bptreeIndexFileName), 0644)
+}
+
+// Close ends the read transaction.
+func (s *BPlusTreeSnapshot) Close() error {
+	return s.tx.Rollback()
+}
+
+// Load puts every key of the iterator into the index in a single transaction.
+func (B *BPlusTree) Load(it Iterator) error {
+	return B.tree.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := bucket.Put(it.Key(), data.EncodeLogRecordPos(it.Value())); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // bptreeIterator is an iterator for the B+ tree index.
 type bptreeIterator struct {
 	tx      *bbolt.Tx
@@ -117,6 +187,11 @@ type bptreeIterator struct {
 	reverse bool
 	currKey []byte
 	currVal []byte
+
+	// the bbolt cursor stays on the first or last key when it moves past the ends,
+	// these flags remember which end the iterator has moved past
+	beforeFirst bool
+	afterLast   bool
 }
 
 // newBptreeIterator creates a new iterator for the B+ tree index.
@@ -131,25 +206,95 @@ func newBptreeIterator(tree *bbolt.DB, reverse bool) *bptreeIterator {
 }
 
 func (b *bptreeIterator) Rewind() {
+	b.First()
+}
+
+func (b *bptreeIterator) Seek(key []byte) {
 	if b.reverse {
-		b.currKey, b.currVal = b.cursor.Last()
+		b.seekLessOrEqual(key)
 	} else {
-		b.currKey, b.currVal = b.cursor.First()
+		b.seekGreaterOrEqual(key)
 	}
 }
 
-func (b *bptreeIterator) Seek(key []byte) {
-	b.currKey, b.currVal = b.cursor.Seek(key)
+func (b *bptreeIterator) SeekForPrev(key []byte) {
+	if b.reverse {
+		b.seekGreaterOrEqual(key)
+	} else {
+		b.seekLessOrEqual(key)
+	}
 }
 
-func (b *bptreeIterator) Next() {
+func (b *bptreeIterator) First() {
+	if b.reverse {
+		b.setPosition(b.cursor.Last())
+	} else {
+		b.setPosition(b.cursor.First())
+	}
+}
+
+func (b *bptreeIterator) Last() {
 	if b.reverse {
-		b.currKey, b.currVal = b.cursor.Prev()
+		b.setPosition(b.cursor.First())
 	} else {
-		b.currKey, b.currVal = b.cursor.Next()
+		b.setPosition(b.cursor.Last())
+	}
+}
+
+func (b *bptreeIterator) Next() {
+	b.step(!b.reverse)
+}
+
+func (b *bptreeIterator) Prev() {
+	b.step(b.reverse)
+}
+
+// seekGreaterOrEqual moves the cursor to the first key greater than or equal to the given key.
+func (b *bptreeIterator) seekGreaterOrEqual(key []byte) {
+	b.setPosition(b.cursor.Seek(key))
+	if b.currKey == nil {
+		b.afterLast = true
+	}
+}
+
+// seekLessOrEqual moves the cursor to the last key less than or equal to the given key.
+func (b *bptreeIterator) seekLessOrEqual(key []byte) {
+	// the cursor always seeks forward, step back if it passed the given key
+	b.setPosition(b.cursor.Seek(key))
+	if b.currKey == nil {
+		b.setPosition(b.cursor.Last())
+	} else if bytes.Compare(b.currKey, key) > 0 {
+		b.setPosition(b.cursor.Prev())
+	}
+	if b.currKey == nil {
+		b.beforeFirst = true
 	}
 }
 
+// step moves the cursor one key up or down in key order.
+func (b *bptreeIterator) step(up bool) {
+	switch {
+	case up && b.beforeFirst:
+		b.setPosition(b.cursor.First())
+	case up && b.afterLast, !up && b.beforeFirst:
+		return
+	case !up && b.afterLast:
+		b.setPosition(b.cursor.Last())
+	case up:
+		b.setPosition(b.cursor.Next())
+		b.afterLast = b.currKey == nil
+	default:
+		b.setPosition(b.cursor.Prev())
+		b.beforeFirst = b.currKey == nil
+	}
+}
+
+// setPosition sets the current key and value and clears the past-the-ends flags.
+func (b *bptreeIterator) setPosition(key, value []byte) {
+	b.currKey, b.currVal = key, value
+	b.beforeFirst, b.afterLast = false, false
+}
+
 func (b *bptreeIterator) Valid() bool {
 	return b.currKey != nil && len(b.currKey) != 0
 }
@@ -1,10 +1,8 @@
 package index
 
 import (
-	"bytes"
 	"github.com/google/btree"
 	"go-kv/data"
-	"sort"
 	"sync"
 )
 
@@ -77,13 +75,16 @@ func (B *BTree) Size() int {
 }
 
 // Iterator returns a new iterator for the BTree.
+// The iterator walks a copy-on-write clone of the tree, so it is created in constant time
+// and is not affected by later writes.
 func (B *BTree) Iterator(reverse bool) Iterator {
 	if B.tree == nil {
 		return nil
 	}
-	B.lock.RLock()
-	defer B.lock.RUnlock()
-	return newBtreeIterator(B.tree, reverse)
+	// cloning marks the tree as copy-on-write, so it needs the write lock
+	B.lock.Lock()
+	defer B.lock.Unlock()
+	return newBtreeIterator(B.tree.Clone(), reverse)
 }
 
 // Close releases any resources associated with the BTree.
@@ -92,76 +93,160 @@ func (B *BTree) Close() error {
 }
 
 // btreeIterator is an iterator for the BTree.
+// Every move is a O(log n) lookup relative to the current key,
+// so the cost of iterating is proportional to the number of keys visited.
 type btreeIterator struct {
-	currIndex int     // current index in the BTree
-	reverse   bool    // whether to iterate in reverse order
-	values    []*Item // slice of values in the BTree
+	tree    *btree.BTree // copy-on-write snapshot of the BTree
+	reverse bool         // whether to iterate in reverse order
+	curr    *Item        // current item, nil if the iterator is not valid
+
+	// remember which end the iterator has moved past, so that it can step back onto it
+	beforeFirst bool
+	afterLast   bool
 }
 
 // newBtreeIterator creates a new iterator for the BTree.
 func newBtreeIterator(tree *btree.BTree, reverse bool) *btreeIterator {
-	var idx int
-	values := make([]*Item, tree.Len())
-
-	// save all the values in the BTree into the values slice
-	save := func(item btree.Item) bool {
-		values[idx] = item.(*Item)
-		idx++
-		return true
+	b := &btreeIterator{
+		tree:    tree,
+		reverse: reverse,
 	}
+	b.Rewind()
+	return b
+}
+
+// Rewind resets the iterator to the beginning of the BTree.
+func (b *btreeIterator) Rewind() {
+	b.First()
+}
 
-	if reverse {
-		tree.Descend(save)
+// Seek moves the iterator to the position of the first key greater than or equal to the given key.
+func (b *btreeIterator) Seek(key []byte) {
+	if b.reverse {
+		b.seekLessOrEqual(key)
 	} else {
-		tree.Ascend(save)
+		b.seekGreaterOrEqual(key)
 	}
+}
 
-	return &btreeIterator{
-		currIndex: 0,
-		reverse:   reverse,
-		values:    values,
+// SeekForPrev moves the iterator to the position of the last key less than or equal to the given key,
+// or greater than or equal to the given key for a reverse iterator.
+func (b *btreeIterator) SeekForPrev(key []byte) {
+	if b.reverse {
+		b.seekGreaterOrEqual(key)
+	} else {
+		b.seekLessOrEqual(key)
 	}
 }
 
-// Rewind resets the iterator to the beginning of the BTree.
-func (b *btreeIterator) Rewind() {
-	b.currIndex = 0
+// First moves the iterator to the first position in iteration order.
+func (b *btreeIterator) First() {
+	if b.reverse {
+		b.setPosition(b.tree.Max())
+	} else {
+		b.setPosition(b.tree.Min())
+	}
 }
 
-// Seek moves the iterator to the position of the first key greater than or equal to the given key.
-func (b *btreeIterator) Seek(key []byte) {
+// Last moves the iterator to the last position in iteration order.
+func (b *btreeIterator) Last() {
 	if b.reverse {
-		b.currIndex = sort.Search(len(b.values), func(i int) bool {
-			return bytes.Compare(b.values[i].Key, key) <= 0
-		})
+		b.setPosition(b.tree.Min())
 	} else {
-		b.currIndex = sort.Search(len(b.values), func(i int) bool {
-			return bytes.Compare(b.values[i].Key, key) >= 0
-		})
+		b.setPosition(b.tree.Max())
 	}
 }
 
 // Next moves the iterator to the next position.
 func (b *btreeIterator) Next() {
-	b.currIndex += 1
+	b.step(!b.reverse)
+}
+
+// Prev moves the iterator to the previous position.
+func (b *btreeIterator) Prev() {
+	b.step(b.reverse)
+}
+
+// seekGreaterOrEqual moves the iterator to the first key greater than or equal to the given key.
+func (b *btreeIterator) seekGreaterOrEqual(key []byte) {
+	b.setPosition(nil)
+	b.tree.AscendGreaterOrEqual(&Item{Key: key}, func(item btree.Item) bool {
+		b.curr = item.(*Item)
+		return false
+	})
+	b.afterLast = b.curr == nil
+}
+
+// seekLessOrEqual moves the iterator to the last key less than or equal to the given key.
+func (b *btreeIterator) seekLessOrEqual(key []byte) {
+	b.setPosition(nil)
+	b.tree.DescendLessOrEqual(&Item{Key: key}, func(item btree.Item) bool {
+		b.curr = item.(*Item)
+		return false
+	})
+	b.beforeFirst = b.curr == nil
+}
+
+// step moves the iterator one key up or down in key order.
+func (b *btreeIterator) step(up bool) {
+	switch {
+	case up && b.beforeFirst:
+		b.setPosition(b.tree.Min())
+	case !up && b.afterLast:
+		b.setPosition(b.tree.Max())
+	case b.curr == nil:
+		return
+	case up:
+		curr := b.curr
+		b.setPosition(nil)
+		b.tree.AscendGreaterOrEqual(curr, func(item btree.Item) bool {
+			if !curr.Less(item) {
+				return true
+			}
+			b.curr = item.(*Item)
+			return false
+		})
+		b.afterLast = b.curr == nil
+	default:
+		curr := b.curr
+		b.setPosition(nil)
+		b.tree.DescendLessOrEqual(curr, func(item btree.Item) bool {
+			if !item.Less(curr) {
+				return true
+			}
+			b.curr = item.(*Item)
+			return false
+		})
+		b.beforeFirst = b.curr == nil
+	}
+}
+
+// setPosition sets the current item and clears the past-the-ends flags.
+func (b *btreeIterator) setPosition(item btree.Item) {
+	b.curr = nil
+	if item != nil {
+		b.curr = item.(*Item)
+	}
+	b.beforeFirst, b.afterLast = false, false
 }
 
 // Valid returns whether the iterator is currently pointing to a valid position.
 func (b *btreeIterator) Valid() bool {
-	return b.currIndex < len(b.values)
+	return b.curr != nil
 }
 
 // Key returns the current key.
 func (b *btreeIterator) Key() []byte {
-	return b.values[b.currIndex].Key
+	return b.curr.Key
 }
 
 // Value returns the current value.
 func (b *btreeIterator) Value() *data.LogRecordPos {
-	return b.values[b.currIndex].pos
+	return b.curr.pos
 }
 
 // Close releases any resources associated with the iterator.
 func (b *btreeIterator) Close() {
-	b.values = nil
+	b.tree = nil
+	b.curr = nil
 }
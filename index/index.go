@@ -70,9 +70,23 @@ type Iterator interface {
 	// Seek moves the iterator to the position of the first key greater than or equal to the given key.
 	Seek(key []byte)
 
+	// SeekForPrev moves the iterator to the position of the last key less than or equal to the given key,
+	// or greater than or equal to the given key for a reverse iterator.
+	SeekForPrev(key []byte)
+
+	// First moves the iterator to the first position in iteration order.
+	First()
+
+	// Last moves the iterator to the last position in iteration order.
+	Last()
+
 	// Next moves the iterator to the next position.
 	Next()
 
+	// Prev moves the iterator to the previous position.
+	// Moving back from past the last position lands on the last position.
+	Prev()
+
 	// Valid returns whether the iterator is valid.
 	Valid() bool
 
@@ -0,0 +1,240 @@
+package index
+
+import (
+	"bytes"
+	"go-kv/data"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// newTestIndexers returns one indexer of every index type filled with the given keys.
+func newTestIndexers(t *testing.T, keys []string) map[string]Indexer {
+	dir, err := os.MkdirTemp("", "bitcask-go-index-iterator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	indexers := map[string]Indexer{
+		"btree":  NewIndexer(Btree, dir, false),
+		"art":    NewIndexer(ART, dir, false),
+		"bptree": NewIndexer(BPTree, dir, false),
+	}
+	for _, indexer := range indexers {
+		for i, key := range keys {
+			indexer.Put([]byte(key), &data.LogRecordPos{Fid: 1, Offset: int64(i)})
+		}
+		t.Cleanup(func() { _ = indexer.Close() })
+	}
+	return indexers
+}
+
+// iteratorKey returns the current key of the iterator, or an empty string if it is not valid.
+func iteratorKey(it Iterator) string {
+	if !it.Valid() {
+		return ""
+	}
+	return string(it.Key())
+}
+
+func TestIterator_Bidirectional(t *testing.T) {
+	tests := []struct {
+		name    string
+		reverse bool
+		moves   func(it Iterator) []string
+		want    []string
+	}{
+		{
+			name: "last then prev",
+			moves: func(it Iterator) []string {
+				var keys []string
+				for it.Last(); it.Valid(); it.Prev() {
+					keys = append(keys, iteratorKey(it))
+				}
+				return keys
+			},
+			want: []string{"e", "d", "c", "b", "a"},
+		},
+		{
+			name:    "last then prev reverse",
+			reverse: true,
+			moves: func(it Iterator) []string {
+				var keys []string
+				for it.Last(); it.Valid(); it.Prev() {
+					keys = append(keys, iteratorKey(it))
+				}
+				return keys
+			},
+			want: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name: "next and prev around the ends",
+			moves: func(it Iterator) []string {
+				var keys []string
+				it.Last()
+				it.Next()
+				keys = append(keys, iteratorKey(it))
+				it.Prev()
+				keys = append(keys, iteratorKey(it))
+				it.First()
+				it.Prev()
+				keys = append(keys, iteratorKey(it))
+				it.Next()
+				keys = append(keys, iteratorKey(it))
+				return keys
+			},
+			want: []string{"", "e", "", "a"},
+		},
+		{
+			name: "seek for prev",
+			moves: func(it Iterator) []string {
+				var keys []string
+				for _, key := range []string{"c", "cc", "0", "z"} {
+					it.SeekForPrev([]byte(key))
+					keys = append(keys, iteratorKey(it))
+				}
+				return keys
+			},
+			want: []string{"c", "c", "", "e"},
+		},
+		{
+			name:    "seek for prev reverse",
+			reverse: true,
+			moves: func(it Iterator) []string {
+				var keys []string
+				for _, key := range []string{"c", "cc", "0", "z"} {
+					it.SeekForPrev([]byte(key))
+					keys = append(keys, iteratorKey(it))
+				}
+				return keys
+			},
+			want: []string{"c", "d", "a", ""},
+		},
+		{
+			name:    "seek then prev reverse",
+			reverse: true,
+			moves: func(it Iterator) []string {
+				var keys []string
+				it.Seek([]byte("cc"))
+				keys = append(keys, iteratorKey(it))
+				it.Prev()
+				keys = append(keys, iteratorKey(it))
+				it.Next()
+				it.Next()
+				keys = append(keys, iteratorKey(it))
+				return keys
+			},
+			want: []string{"c", "d", "b"},
+		},
+	}
+	for name, indexer := range newTestIndexers(t, []string{"c", "a", "e", "b", "d"}) {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.name, func(t *testing.T) {
+				it := indexer.Iterator(tt.reverse)
+				defer it.Close()
+				if got := tt.moves(it); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("keys = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestIterator_WritesDuringIteration(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexer Indexer
+		want    []string
+	}{
+		{
+			// the btree iterator walks a copy-on-write snapshot taken when it was created
+			name:    "btree snapshot",
+			indexer: NewBTree(),
+			want:    []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			// the art iterator reads the live tree in chunks, the keys fit in the first one
+			name:    "art chunk",
+			indexer: NewART(),
+			want:    []string{"a", "b", "c", "d", "e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, key := range []string{"a", "b", "c", "d", "e"} {
+				tt.indexer.Put([]byte(key), &data.LogRecordPos{Fid: 1, Offset: int64(i)})
+			}
+
+			it := tt.indexer.Iterator(false)
+			defer it.Close()
+			var keys []string
+			for it.Rewind(); it.Valid(); it.Next() {
+				keys = append(keys, string(it.Key()))
+				if string(it.Key()) == "b" {
+					tt.indexer.Delete([]byte("c"))
+					tt.indexer.Put([]byte("bb"), &data.LogRecordPos{Fid: 1, Offset: 10})
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("keys = %q, want %q", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestIterator_ARTChunks(t *testing.T) {
+	// keys of several lengths sharing prefixes, so that seeks cross many nodes and chunks
+	rnd := rand.New(rand.NewSource(1))
+	btree, art := NewBTree(), NewART()
+	var keys [][]byte
+	for i := 0; i < 3000; i++ {
+		key := make([]byte, 1+rnd.Intn(4))
+		for j := range key {
+			key[j] = "abc\x00\xff"[rnd.Intn(5)]
+		}
+		keys = append(keys, key)
+		btree.Put(key, &data.LogRecordPos{Fid: 1, Offset: int64(i)})
+		art.Put(key, &data.LogRecordPos{Fid: 1, Offset: int64(i)})
+	}
+
+	for _, reverse := range []bool{false, true} {
+		want, got := btree.Iterator(reverse), art.Iterator(reverse)
+		moves := []func(it Iterator, key []byte){
+			func(it Iterator, key []byte) { it.Next() },
+			func(it Iterator, key []byte) { it.Prev() },
+			func(it Iterator, key []byte) { it.Seek(key) },
+			func(it Iterator, key []byte) { it.SeekForPrev(key) },
+			func(it Iterator, key []byte) { it.First() },
+			func(it Iterator, key []byte) { it.Last() },
+		}
+		for i := 0; i < 20000; i++ {
+			move := rnd.Intn(len(moves))
+			if move > 1 && rnd.Intn(50) > 0 {
+				// mostly walk, so that the walks cross chunks
+				move = rnd.Intn(2)
+			}
+			key := append(keys[rnd.Intn(len(keys))], "a\x00"[:rnd.Intn(3)]...)
+			moves[move](want, key)
+			moves[move](got, key)
+			if want.Valid() != got.Valid() || want.Valid() && !bytes.Equal(want.Key(), got.Key()) {
+				t.Fatalf("reverse %v, move %d: key = %q, want %q", reverse, i, iteratorKey(got), iteratorKey(want))
+			}
+		}
+	}
+
+	// a write past the current chunk shows up when the walk reaches it
+	it := art.Iterator(false)
+	defer it.Close()
+	art.Put([]byte("\xff\xff\xff\xff\xff"), &data.LogRecordPos{Fid: 1})
+	var last string
+	count := 0
+	for it.Rewind(); it.Valid(); it.Next() {
+		last = iteratorKey(it)
+		count++
+	}
+	if last != "\xff\xff\xff\xff\xff" || count != art.Size() {
+		t.Errorf("walk saw %d keys ending with %q, want %d", count, last, art.Size())
+	}
+}
@@ -0,0 +1,43 @@
+// Package dbflag parses the command line flags the go-kv commands share for opening a database.
+package dbflag
+
+import (
+	"fmt"
+	go_kv "go-kv"
+)
+
+// IndexUsage is the usage text of the -index flag.
+const IndexUsage = "index type, btree, art or bptree, auto detects it from the directory"
+
+// indexTypes maps the values of the -index flag to index types.
+var indexTypes = map[string]go_kv.IndexType{
+	"btree":  go_kv.Btree,
+	"art":    go_kv.ART,
+	"bptree": go_kv.BPlusTree,
+}
+
+// IndexType returns the index type to open the database in dirPath with for the value of the -index flag.
+// auto uses the type the directory was written with, or the default one for a new database.
+// A type whose on-disk layout differs from the one of the directory is refused,
+// writing with it would leave the database unreadable with the original type.
+func IndexType(name, dirPath string) (go_kv.IndexType, error) {
+	detected, ok := go_kv.DetectIndexType(dirPath)
+	if name == "auto" {
+		if !ok {
+			return go_kv.DefaultOptions.IndexType, nil
+		}
+		return detected, nil
+	}
+
+	typ, known := indexTypes[name]
+	if !known {
+		return 0, fmt.Errorf("unknown index type %q, use btree, art, bptree or auto", name)
+	}
+	if ok && (typ == go_kv.BPlusTree) != (detected == go_kv.BPlusTree) {
+		if detected == go_kv.BPlusTree {
+			return 0, fmt.Errorf("%s holds a bptree database, open it with -index bptree", dirPath)
+		}
+		return 0, fmt.Errorf("%s holds a btree or art database, open it with -index btree or art", dirPath)
+	}
+	return typ, nil
+}
@@ -0,0 +1,62 @@
+package dbflag
+
+import (
+	"fmt"
+	go_kv "go-kv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexType(t *testing.T) {
+	root, _ := os.MkdirTemp("", "bitcask-go-dbflag")
+	defer func() {
+		_ = os.RemoveAll(root)
+	}()
+	dirs := make(map[go_kv.IndexType]string)
+	for _, typ := range []go_kv.IndexType{go_kv.Btree, go_kv.BPlusTree} {
+		opts := go_kv.DefaultOptions
+		opts.DirPath = filepath.Join(root, fmt.Sprint(typ))
+		opts.IndexType = typ
+		db, err := go_kv.Open(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = db.Put([]byte("key"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		dirs[typ] = opts.DirPath
+	}
+	missing := filepath.Join(root, "missing")
+
+	tests := []struct {
+		name    string
+		index   string
+		dir     string
+		want    go_kv.IndexType
+		wantErr bool
+	}{
+		{name: "auto btree", index: "auto", dir: dirs[go_kv.Btree], want: go_kv.Btree},
+		{name: "auto bptree", index: "auto", dir: dirs[go_kv.BPlusTree], want: go_kv.BPlusTree},
+		{name: "auto new", index: "auto", dir: missing, want: go_kv.DefaultOptions.IndexType},
+		{name: "art on btree", index: "art", dir: dirs[go_kv.Btree], want: go_kv.ART},
+		{name: "btree on new", index: "btree", dir: missing, want: go_kv.Btree},
+		{name: "bptree on btree", index: "bptree", dir: dirs[go_kv.Btree], wantErr: true},
+		{name: "btree on bptree", index: "btree", dir: dirs[go_kv.BPlusTree], wantErr: true},
+		{name: "unknown", index: "hash", dir: missing, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IndexType(tt.index, tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IndexType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IndexType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package netserver accepts connections for the protocol servers of go-kv and tracks them,
+// so that closing a server ends all of its connections.
+package netserver
+
+import (
+	"log"
+	"net"
+	"runtime/debug"
+	"sync"
+)
+
+// Server serves each accepted connection in its own goroutine.
+type Server struct {
+	errClosed error // returned by Serve after Close
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// New creates a server whose Serve returns errClosed after Close is called.
+func New(errClosed error) *Server {
+	return &Server{
+		errClosed: errClosed,
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves connections with serveConn until Close is called.
+func (s *Server) ListenAndServe(addr string, serveConn func(conn net.Conn)) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener, serveConn)
+}
+
+// Serve accepts connections on the listener and serves each of them with serveConn in its own goroutine
+// until Close is called, then it returns the closed error of the server.
+// The connection is closed when serveConn returns.
+func (s *Server) Serve(listener net.Listener, serveConn func(conn net.Conn)) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = listener.Close()
+		return s.errClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return s.errClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return s.errClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serve(conn, serveConn)
+	}
+}
+
+// serve runs serveConn for the connection, then closes it and stops tracking it.
+// A panic in serveConn is logged and only ends its own connection.
+func (s *Server) serve(conn net.Conn, serveConn func(conn net.Conn)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("netserver: panic serving %v: %v\n%s", conn.RemoteAddr(), r, debug.Stack())
+		}
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+		s.wg.Done()
+	}()
+	serveConn(conn)
+}
+
+// Close stops accepting connections, closes all open connections and waits for their goroutines to exit.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,66 @@
+package go_kv
+
+import "iter"
+
+// All returns an iterator over all key-value pairs in ascending key order.
+// The iteration stops at the first error reading a value, use Items to observe it.
+func (db *DB) All() iter.Seq2[[]byte, []byte] {
+	seq, _ := db.Items(DefaultIteratorOptions)
+	return seq
+}
+
+// Scan returns an iterator over the key-value pairs whose keys start with the given prefix.
+// The iteration stops at the first error reading a value, use Items to observe it.
+func (db *DB) Scan(prefix []byte) iter.Seq2[[]byte, []byte] {
+	seq, _ := db.Items(IteratorOptions{Prefix: prefix})
+	return seq
+}
+
+// Range returns an iterator over the key-value pairs with keys in the range [lo, hi).
+// A nil lo or hi leaves the range unbounded on that side.
+// The iteration stops at the first error reading a value, use Items to observe it.
+func (db *DB) Range(lo, hi []byte) iter.Seq2[[]byte, []byte] {
+	seq, _ := db.Items(IteratorOptions{LowerBound: lo, UpperBound: hi})
+	return seq
+}
+
+// Keys returns an iterator over the keys selected by the options.
+// Values are never read from the data files.
+func (db *DB) Keys(options IteratorOptions) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		iterator := db.NewIterator(options)
+		defer iterator.Close()
+		for ; iterator.Valid(); iterator.Next() {
+			if !yield(copyBytes(iterator.Key())) {
+				return
+			}
+		}
+	}
+}
+
+// Items returns an iterator over the key-value pairs selected by the options,
+// and a function that reports the error which stopped the last iteration, if any.
+// The underlying Iterator is closed when the loop finishes or breaks early.
+func (db *DB) Items(options IteratorOptions) (iter.Seq2[[]byte, []byte], func() error) {
+	var err error
+	seq := func(yield func([]byte, []byte) bool) {
+		err = nil
+		iterator := db.NewIterator(options)
+		defer iterator.Close()
+		for ; iterator.Valid(); iterator.Next() {
+			var value []byte
+			if value, err = iterator.Value(); err != nil {
+				return
+			}
+			if !yield(copyBytes(iterator.Key()), value) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return err }
+}
+
+// copyBytes returns a copy of b, keys of the B+Tree index are only valid while its iterator is open.
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
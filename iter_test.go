@@ -0,0 +1,142 @@
+package go_kv
+
+import (
+	"errors"
+	"iter"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newIterTestDB opens a database of the given index type filled with the given keys, each value equal to its key.
+func newIterTestDB(t *testing.T, indexType IndexType, keys []string) *DB {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-iter")
+	opts.DirPath = dir
+	opts.IndexType = indexType
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err = db.Put([]byte(key), []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestDB_All_Scan_Range(t *testing.T) {
+	keys := []string{"a1", "a2", "b1", "b2", "c1"}
+	tests := []struct {
+		name string
+		seq  func(db *DB) iter.Seq2[[]byte, []byte]
+		want []string
+	}{
+		{
+			name: "all",
+			seq:  func(db *DB) iter.Seq2[[]byte, []byte] { return db.All() },
+			want: []string{"a1", "a2", "b1", "b2", "c1"},
+		},
+		{
+			name: "scan prefix",
+			seq:  func(db *DB) iter.Seq2[[]byte, []byte] { return db.Scan([]byte("b")) },
+			want: []string{"b1", "b2"},
+		},
+		{
+			name: "range",
+			seq:  func(db *DB) iter.Seq2[[]byte, []byte] { return db.Range([]byte("a2"), []byte("c1")) },
+			want: []string{"a2", "b1", "b2"},
+		},
+		{
+			name: "range unbounded",
+			seq:  func(db *DB) iter.Seq2[[]byte, []byte] { return db.Range([]byte("b2"), nil) },
+			want: []string{"b2", "c1"},
+		},
+	}
+	db := newIterTestDB(t, Btree, keys)
+	defer destroyDB(db)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for key, value := range tt.seq(db) {
+				if string(key) != string(value) {
+					t.Errorf("value = %s, want %s", value, key)
+				}
+				got = append(got, string(key))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDB_Keys(t *testing.T) {
+	db := newIterTestDB(t, Btree, []string{"a1", "a2", "b1", "b2", "c1"})
+	defer destroyDB(db)
+
+	var got []string
+	for key := range db.Keys(IteratorOptions{Prefix: []byte("a"), Reverse: true}) {
+		got = append(got, string(key))
+	}
+	if want := []string{"a2", "a1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestDB_Items_BreakEarly(t *testing.T) {
+	// a leaked B+Tree iterator keeps a read transaction open, which blocks closing the index
+	db := newIterTestDB(t, BPlusTree, []string{"a1", "a2", "b1", "b2", "c1"})
+
+	seq, errFn := db.Items(DefaultIteratorOptions)
+	var got []string
+	for key := range seq {
+		got = append(got, string(key))
+		if len(got) == 2 {
+			break
+		}
+	}
+	if err := errFn(); err != nil {
+		t.Errorf("Items() error = %v", err)
+	}
+	if want := []string{"a1", "a2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		destroyDB(db)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() blocked, iterator was not closed after break")
+	}
+}
+
+func TestDB_Items_Error(t *testing.T) {
+	db := newIterTestDB(t, Btree, []string{"a1", "a2"})
+	defer destroyDB(db)
+
+	// roll the active file without keeping the old one, so its values can no longer be read
+	db.mut.Lock()
+	if err := db.setActiveFile(); err != nil {
+		t.Fatal(err)
+	}
+	db.mut.Unlock()
+
+	seq, errFn := db.Items(DefaultIteratorOptions)
+	var visited int
+	for range seq {
+		visited++
+	}
+	if visited != 0 {
+		t.Errorf("visited = %d, want 0", visited)
+	}
+	if err := errFn(); !errors.Is(err, ErrDataFileNotFound) {
+		t.Errorf("Items() error = %v, wantErr %v", err, ErrDataFileNotFound)
+	}
+}
@@ -10,39 +10,176 @@ type Iterator struct {
 	indexIter index.Iterator // iterator over the index
 	db        *DB
 	options   IteratorOptions
+
+	lowerBound []byte // inclusive lower bound, derived from LowerBound and Prefix
+	upperBound []byte // exclusive upper bound, derived from UpperBound and Prefix
+	count      int    // number of keys visited since the last positioning call
 }
 
 // NewIterator creates a new iterator over the KV store.
 func (db *DB) NewIterator(options IteratorOptions) *Iterator {
-	indexIter := db.index.Iterator(options.Reverse)
-	return &Iterator{
-		indexIter: indexIter,
-		db:        db,
-		options:   options,
+	return db.newIterator(db.index, options)
+}
+
+// newIterator creates a new iterator over the given memory index.
+func (db *DB) newIterator(indexer index.Indexer, options IteratorOptions) *Iterator {
+	indexIter := indexer.Iterator(options.Reverse)
+	lowerBound, upperBound := iteratorBounds(options)
+	iterator := &Iterator{
+		indexIter:  indexIter,
+		db:         db,
+		options:    options,
+		lowerBound: lowerBound,
+		upperBound: upperBound,
 	}
+	iterator.Rewind()
+	return iterator
 }
 
-// Rewind resets the iterator to the beginning of the index.
+// iteratorBounds translates the prefix and bounds of the options into one key range [lower, upper).
+// A nil bound means the range is unbounded on that side.
+func iteratorBounds(options IteratorOptions) (lower, upper []byte) {
+	lower, upper = options.LowerBound, options.UpperBound
+	if len(options.Prefix) == 0 {
+		return lower, upper
+	}
+
+	if bytes.Compare(options.Prefix, lower) > 0 {
+		lower = options.Prefix
+	}
+	if prefixUpper := prefixUpperBound(options.Prefix); prefixUpper != nil &&
+		(len(upper) == 0 || bytes.Compare(prefixUpper, upper) < 0) {
+		upper = prefixUpper
+	}
+	return lower, upper
+}
+
+// Rewind resets the iterator to the first key within the bounds.
 func (i *Iterator) Rewind() {
-	i.indexIter.Rewind()
-	i.skipToNext()
+	i.count = 0
+	if i.options.Reverse {
+		if len(i.upperBound) == 0 {
+			i.indexIter.Rewind()
+			return
+		}
+		i.seekBeforeUpperBound()
+		return
+	}
+
+	if len(i.lowerBound) == 0 {
+		i.indexIter.Rewind()
+		return
+	}
+	i.indexIter.Seek(i.lowerBound)
 }
 
-// Seek moves the iterator to the position just after the given key.
+// Seek moves the iterator to the first key greater than or equal to the given key,
+// or less than or equal to the given key for a reverse iterator. The key is clamped to the bounds.
 func (i *Iterator) Seek(key []byte) {
+	i.count = 0
+	if i.options.Reverse {
+		if len(i.upperBound) > 0 && bytes.Compare(key, i.upperBound) >= 0 {
+			i.seekBeforeUpperBound()
+			return
+		}
+		i.indexIter.Seek(key)
+		return
+	}
+
+	if bytes.Compare(key, i.lowerBound) < 0 {
+		key = i.lowerBound
+	}
 	i.indexIter.Seek(key)
-	i.skipToNext()
+}
+
+// seekBeforeUpperBound moves a reverse iterator to the last key less than the upper bound.
+func (i *Iterator) seekBeforeUpperBound() {
+	i.indexIter.Seek(i.upperBound)
+	if i.indexIter.Valid() && bytes.Equal(i.indexIter.Key(), i.upperBound) {
+		i.indexIter.Next()
+	}
+}
+
+// SeekForPrev moves the iterator to the last key less than or equal to the given key,
+// or greater than or equal to the given key for a reverse iterator. The key is clamped to the bounds.
+func (i *Iterator) SeekForPrev(key []byte) {
+	i.count = 0
+	if i.options.Reverse {
+		if bytes.Compare(key, i.lowerBound) < 0 {
+			key = i.lowerBound
+		}
+		i.indexIter.SeekForPrev(key)
+		return
+	}
+
+	if len(i.upperBound) > 0 && bytes.Compare(key, i.upperBound) >= 0 {
+		i.seekForPrevUpperBound()
+		return
+	}
+	i.indexIter.SeekForPrev(key)
+}
+
+// First moves the iterator to the first key within the bounds in iteration order.
+func (i *Iterator) First() {
+	i.Rewind()
+}
+
+// Last moves the iterator to the last key within the bounds in iteration order.
+func (i *Iterator) Last() {
+	i.count = 0
+	if i.options.Reverse {
+		if len(i.lowerBound) == 0 {
+			i.indexIter.Last()
+			return
+		}
+		i.indexIter.SeekForPrev(i.lowerBound)
+		return
+	}
+
+	if len(i.upperBound) == 0 {
+		i.indexIter.Last()
+		return
+	}
+	i.seekForPrevUpperBound()
+}
+
+// seekForPrevUpperBound moves a forward iterator to the last key less than the upper bound.
+func (i *Iterator) seekForPrevUpperBound() {
+	i.indexIter.SeekForPrev(i.upperBound)
+	if i.indexIter.Valid() && bytes.Equal(i.indexIter.Key(), i.upperBound) {
+		i.indexIter.Prev()
+	}
 }
 
 // Next moves the iterator to the next position.
 func (i *Iterator) Next() {
 	i.indexIter.Next()
-	i.skipToNext()
+	i.count++
 }
 
-// Valid returns true if the iterator is pointing to a valid position.
+// Prev moves the iterator to the previous position.
+func (i *Iterator) Prev() {
+	i.indexIter.Prev()
+	if i.count > 0 {
+		i.count--
+	}
+}
+
+// Valid returns true if the iterator is pointing to a valid position
+// within the bounds and the limit.
 func (i *Iterator) Valid() bool {
-	return i.indexIter.Valid()
+	if !i.indexIter.Valid() {
+		return false
+	}
+	if i.options.Limit > 0 && i.count >= i.options.Limit {
+		return false
+	}
+
+	key := i.indexIter.Key()
+	if len(i.lowerBound) > 0 && bytes.Compare(key, i.lowerBound) < 0 {
+		return false
+	}
+	return len(i.upperBound) == 0 || bytes.Compare(key, i.upperBound) < 0
 }
 
 // Key returns the current key.
@@ -62,18 +199,3 @@ func (i *Iterator) Value() ([]byte, error) {
 func (i *Iterator) Close() {
 	i.indexIter.Close()
 }
-
-func (i *Iterator) skipToNext() {
-	prefixLen := len(i.options.Prefix)
-	if prefixLen == 0 {
-		return
-	}
-
-	for ; i.indexIter.Valid(); i.indexIter.Next() {
-		key := i.indexIter.Key()
-		//if prefixLen <= len(key) && bytes.Compare(i.options.Prefix, key[:prefixLen]) == 0 {
-		if bytes.Equal(i.options.Prefix, key[:prefixLen]) {
-			break
-		}
-	}
-}
@@ -3,6 +3,7 @@ package go_kv
 import (
 	"go-kv/utils"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -185,3 +186,195 @@ func TestDB_Iterator_Multiple_Value(t *testing.T) {
 	}
 
 }
+
+// collectKeys returns all keys visited by the iterator from its current position.
+func collectKeys(iterator *Iterator) []string {
+	var keys []string
+	for ; iterator.Valid(); iterator.Next() {
+		keys = append(keys, string(iterator.Key()))
+	}
+	return keys
+}
+
+func TestDB_Iterator_Bounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		options IteratorOptions
+		seek    []byte
+		want    []string
+	}{
+		{
+			name:    "prefix",
+			options: IteratorOptions{Prefix: []byte("b")},
+			want:    []string{"b", "b1", "b2", "b3"},
+		},
+		{
+			name:    "prefix longer than some keys",
+			options: IteratorOptions{Prefix: []byte("b1x")},
+			want:    nil,
+		},
+		{
+			name:    "prefix reverse",
+			options: IteratorOptions{Prefix: []byte("b"), Reverse: true},
+			want:    []string{"b3", "b2", "b1", "b"},
+		},
+		{
+			name:    "lower and upper bound",
+			options: IteratorOptions{LowerBound: []byte("a2"), UpperBound: []byte("b2")},
+			want:    []string{"a2", "a3", "b", "b1"},
+		},
+		{
+			name:    "lower and upper bound reverse",
+			options: IteratorOptions{LowerBound: []byte("a2"), UpperBound: []byte("b2"), Reverse: true},
+			want:    []string{"b1", "b", "a3", "a2"},
+		},
+		{
+			name:    "upper bound between keys reverse",
+			options: IteratorOptions{UpperBound: []byte("b15"), Reverse: true, Limit: 2},
+			want:    []string{"b1", "b"},
+		},
+		{
+			name:    "prefix and bounds intersect",
+			options: IteratorOptions{Prefix: []byte("b"), LowerBound: []byte("b2"), UpperBound: []byte("c2")},
+			want:    []string{"b2", "b3"},
+		},
+		{
+			name:    "limit",
+			options: IteratorOptions{Limit: 3},
+			want:    []string{"a1", "a2", "a3"},
+		},
+		{
+			name:    "seek below lower bound is clamped",
+			options: IteratorOptions{LowerBound: []byte("b"), Limit: 2},
+			seek:    []byte("a"),
+			want:    []string{"b", "b1"},
+		},
+		{
+			name:    "seek above upper bound reverse is clamped",
+			options: IteratorOptions{UpperBound: []byte("b"), Reverse: true},
+			seek:    []byte("c"),
+			want:    []string{"a3", "a2", "a1"},
+		},
+	}
+	keys := []string{"a1", "a2", "a3", "b", "b1", "b2", "b3", "c1", "c2"}
+	for _, indexType := range []IndexType{Btree, ART, BPlusTree} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-iterator-bounds")
+		opts.DirPath = dir
+		opts.IndexType = indexType
+		db, err := Open(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if err = db.Put([]byte(key), []byte(key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				iterator := db.NewIterator(tt.options)
+				defer iterator.Close()
+				if tt.seek != nil {
+					iterator.Seek(tt.seek)
+				}
+				if got := collectKeys(iterator); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("index type %d keys = %v, want %v", indexType, got, tt.want)
+				}
+			})
+		}
+		destroyDB(db)
+	}
+}
+
+func TestDB_Iterator_Bidirectional(t *testing.T) {
+	tests := []struct {
+		name    string
+		options IteratorOptions
+		moves   func(iterator *Iterator) []string
+		want    []string
+	}{
+		{
+			name:    "last then prev within bounds",
+			options: IteratorOptions{LowerBound: []byte("a2"), UpperBound: []byte("b2")},
+			moves: func(iterator *Iterator) []string {
+				var keys []string
+				for iterator.Last(); iterator.Valid(); iterator.Prev() {
+					keys = append(keys, string(iterator.Key()))
+				}
+				return keys
+			},
+			want: []string{"b1", "b", "a3", "a2"},
+		},
+		{
+			name:    "last then prev reverse within prefix",
+			options: IteratorOptions{Prefix: []byte("b"), Reverse: true},
+			moves: func(iterator *Iterator) []string {
+				var keys []string
+				for iterator.Last(); iterator.Valid(); iterator.Prev() {
+					keys = append(keys, string(iterator.Key()))
+				}
+				return keys
+			},
+			want: []string{"b", "b1", "b2", "b3"},
+		},
+		{
+			name:    "seek for prev is clamped to bounds",
+			options: IteratorOptions{LowerBound: []byte("a2"), UpperBound: []byte("c1")},
+			moves: func(iterator *Iterator) []string {
+				var keys []string
+				for _, key := range []string{"b15", "c1", "z"} {
+					iterator.SeekForPrev([]byte(key))
+					keys = append(keys, string(iterator.Key()))
+				}
+				iterator.SeekForPrev([]byte("a1"))
+				if iterator.Valid() {
+					keys = append(keys, string(iterator.Key()))
+				}
+				return keys
+			},
+			want: []string{"b1", "b3", "b3"},
+		},
+		{
+			name:    "page forward then back",
+			options: IteratorOptions{Limit: 2},
+			moves: func(iterator *Iterator) []string {
+				// read the second page, then step back to the end of the first one
+				iterator.Seek([]byte("a3"))
+				keys := collectKeys(iterator)
+				iterator.SeekForPrev([]byte("a3"))
+				iterator.Prev()
+				return append(keys, string(iterator.Key()))
+			},
+			want: []string{"a3", "b", "a2"},
+		},
+	}
+	keys := []string{"a1", "a2", "a3", "b", "b1", "b2", "b3", "c1", "c2"}
+	for _, indexType := range []IndexType{Btree, ART, BPlusTree} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-iterator-bidirectional")
+		opts.DirPath = dir
+		opts.IndexType = indexType
+		db, err := Open(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if err = db.Put([]byte(key), []byte(key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				iterator := db.NewIterator(tt.options)
+				defer iterator.Close()
+				if got := tt.moves(iterator); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("index type %d keys = %v, want %v", indexType, got, tt.want)
+				}
+			})
+		}
+		destroyDB(db)
+	}
+}
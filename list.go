@@ -0,0 +1,259 @@
+package go_kv
+
+import "encoding/binary"
+
+// LPush inserts the elements at the head of the list stored at key, creating the list if it does not exist.
+// The elements are inserted one after the other, so the last one ends up first.
+// It returns the length of the list after the push.
+func (db *DB) LPush(key []byte, elements ...[]byte) (int, error) {
+	return db.pushList(key, true, elements)
+}
+
+// RPush inserts the elements at the tail of the list stored at key, creating the list if it does not exist.
+// It returns the length of the list after the push.
+func (db *DB) RPush(key []byte, elements ...[]byte) (int, error) {
+	return db.pushList(key, false, elements)
+}
+
+// LPop removes and returns the first element of the list stored at key.
+// It returns ErrKeyNotFound if the list does not exist.
+func (db *DB) LPop(key []byte) ([]byte, error) {
+	return db.popList(key, true)
+}
+
+// RPop removes and returns the last element of the list stored at key.
+// It returns ErrKeyNotFound if the list does not exist.
+func (db *DB) RPop(key []byte) ([]byte, error) {
+	return db.popList(key, false)
+}
+
+// pushList inserts the elements and the updated metadata atomically in one WriteBatch.
+func (db *DB) pushList(key []byte, left bool, elements [][]byte) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return 0, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureList)
+	if err != nil {
+		return 0, err
+	}
+	if len(elements) == 0 {
+		return int(meta.size), nil
+	}
+
+	wb := db.structureWriteBatch(len(elements))
+	for _, element := range elements {
+		var index uint64
+		if left {
+			meta.head--
+			index = meta.head
+		} else {
+			index = meta.tail
+			meta.tail++
+		}
+		meta.size++
+		if err = wb.PutCF(dataCF, listElementKey(key, meta.version, index), element); err != nil {
+			return 0, err
+		}
+	}
+	if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+		return 0, err
+	}
+	if err = wb.commit(); err != nil {
+		return 0, err
+	}
+	return int(meta.size), nil
+}
+
+// popList removes an element and writes the updated metadata atomically under the database write lock.
+func (db *DB) popList(key []byte, left bool) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return nil, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return nil, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureList)
+	if err != nil {
+		return nil, err
+	}
+	if meta.size == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	var index uint64
+	if left {
+		index = meta.head
+		meta.head++
+	} else {
+		meta.tail--
+		index = meta.tail
+	}
+	meta.size--
+
+	elementKey := listElementKey(key, meta.version, index)
+	element, err := db.getFamilyValue(dataCF, elementKey)
+	if err != nil {
+		return nil, err
+	}
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	if err = wb.delete(dataCF.id, dataCF.index, elementKey); err != nil {
+		return nil, err
+	}
+	if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+		return nil, err
+	}
+	if err = wb.commit(); err != nil {
+		return nil, err
+	}
+	return element, nil
+}
+
+// LRange returns the elements of the list stored at key between start and stop, both inclusive.
+// Negative offsets count from the end of the list, -1 is the last element.
+// Offsets out of range are clamped, an empty range returns no elements.
+// The elements are read with a column family iterator after the metadata,
+// elements pushed or popped meanwhile may or may not be returned.
+func (db *DB) LRange(key []byte, start, stop int) ([][]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return nil, nil
+	}
+
+	db.mut.RLock()
+	if metaCF.dropped || dataCF.dropped {
+		db.mut.RUnlock()
+		return nil, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureList)
+	db.mut.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	start, stop, ok = listRange(start, stop, int(meta.size))
+	if !ok {
+		return nil, nil
+	}
+
+	// elements are ordered by index, so the range is served by one bounded scan
+	iterator := dataCF.NewIterator(IteratorOptions{
+		LowerBound: listElementKey(key, meta.version, meta.head+uint64(start)),
+		UpperBound: listElementKey(key, meta.version, meta.head+uint64(stop)+1),
+	})
+	defer iterator.Close()
+	elements := make([][]byte, 0, stop-start+1)
+	for ; iterator.Valid(); iterator.Next() {
+		element, err := iterator.Value()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+// LLen returns the length of the list stored at key, 0 if the list does not exist.
+func (db *DB) LLen(key []byte) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return 0, nil
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureList)
+	if err != nil {
+		return 0, err
+	}
+	return int(meta.size), nil
+}
+
+// LIndex returns the element at the given offset of the list stored at key.
+// Negative offsets count from the end of the list, -1 is the last element.
+// It returns ErrKeyNotFound if the offset is out of range.
+func (db *DB) LIndex(key []byte, offset int) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return nil, ErrKeyNotFound
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return nil, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureList)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		offset += int(meta.size)
+	}
+	if offset < 0 || offset >= int(meta.size) {
+		return nil, ErrKeyNotFound
+	}
+	return db.getFamilyValue(dataCF, listElementKey(key, meta.version, meta.head+uint64(offset)))
+}
+
+// listRange translates the start and stop offsets of LRange into a range [start, stop] within a list of the given size.
+// It returns false if the range is empty.
+func listRange(start, stop, size int) (int, int, bool) {
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// listElementKey returns the sub-key of the list element at the given index.
+func listElementKey(key []byte, version uint64, index uint64) []byte {
+	suffix := make([]byte, 8)
+	binary.BigEndian.PutUint64(suffix, index)
+	return structureSubKey(key, version, suffix)
+}
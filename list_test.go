@@ -0,0 +1,114 @@
+package go_kv
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDB_List(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-list")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// list: c b a 1 2 3
+	if n, _ := db.RPush([]byte("queue"), []byte("1"), []byte("2"), []byte("3")); n != 3 {
+		t.Errorf("RPush() got = %d, want 3", n)
+	}
+	if n, _ := db.LPush([]byte("queue"), []byte("a"), []byte("b"), []byte("c")); n != 6 {
+		t.Errorf("LPush() got = %d, want 6", n)
+	}
+
+	// the list survives a restart
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+
+	rangeTests := []struct {
+		name  string
+		start int
+		stop  int
+		want  []string
+	}{
+		{name: "whole list", start: 0, stop: -1, want: []string{"c", "b", "a", "1", "2", "3"}},
+		{name: "middle", start: 2, stop: 3, want: []string{"a", "1"}},
+		{name: "negative offsets", start: -2, stop: -1, want: []string{"2", "3"}},
+		{name: "stop out of range", start: 4, stop: 100, want: []string{"2", "3"}},
+		{name: "start after stop", start: 3, stop: 2, want: nil},
+		{name: "start out of range", start: 10, stop: 20, want: nil},
+	}
+	for _, tt := range rangeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := db.LRange([]byte("queue"), tt.start, tt.stop)
+			if err != nil {
+				t.Errorf("LRange() error = %v", err)
+			}
+			var got []string
+			for _, element := range elements {
+				got = append(got, string(element))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LRange() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if element, _ := db.LIndex([]byte("queue"), -1); string(element) != "3" {
+		t.Errorf("LIndex() got = %s, want 3", element)
+	}
+	if _, err = db.LIndex([]byte("queue"), 6); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("LIndex() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+
+	var popped []string
+	for {
+		element, err := db.LPop([]byte("queue"))
+		if errors.Is(err, ErrKeyNotFound) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		popped = append(popped, string(element))
+		if element, err = db.RPop([]byte("queue")); err == nil {
+			popped = append(popped, string(element))
+		}
+	}
+	if want := []string{"c", "3", "b", "2", "a", "1"}; !reflect.DeepEqual(popped, want) {
+		t.Errorf("popped = %v, want %v", popped, want)
+	}
+	if n, _ := db.LLen([]byte("queue")); n != 0 {
+		t.Errorf("LLen() got = %d, want 0", n)
+	}
+
+	// an emptied list key can hold another type
+	if _, err = db.HSet([]byte("queue"), []byte("field"), []byte("value")); err != nil {
+		t.Errorf("HSet() error = %v", err)
+	}
+	if _, err = db.LPush([]byte("queue"), []byte("x")); !errors.Is(err, ErrWrongType) {
+		t.Errorf("LPush() error = %v, wantErr %v", err, ErrWrongType)
+	}
+
+	// more elements than a default WriteBatch holds are still pushed at once
+	many := make([][]byte, DefaultWriteBatchOptions.MaxBatchNum+1)
+	for i := range many {
+		many[i] = []byte("x")
+	}
+	if n, err := db.RPush([]byte("long"), many...); n != len(many) || err != nil {
+		t.Errorf("RPush() got = %d, %v, want %d", n, err, len(many))
+	}
+	if n, err := db.LPush([]byte("long"), many...); n != 2*len(many) || err != nil {
+		t.Errorf("LPush() got = %d, %v, want %d", n, err, 2*len(many))
+	}
+}
@@ -0,0 +1,350 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	go_kv "go-kv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// storeMode is the condition under which a storage command stores its item.
+type storeMode int
+
+const (
+	storeSet     storeMode = iota // always
+	storeAdd                      // only if the key does not exist
+	storeReplace                  // only if the key exists
+	storeCAS                      // only if the cas unique of the item matches
+)
+
+// handle runs the command of one line and returns its reply, nil if the client asked for none.
+// quit is true when the connection must be closed after the reply, and an error is returned
+// when the data block of a storage command cannot be read from the connection.
+func (s *Server) handle(c *client, line []byte) (reply []byte, quit bool, err error) {
+	args := bytes.Fields(line)
+	if len(args) == 0 {
+		return replyError, false, nil
+	}
+
+	switch string(args[0]) {
+	case "get":
+		return s.get(args, false), false, nil
+	case "gets":
+		return s.get(args, true), false, nil
+	case "set":
+		reply, err = s.store(c, args, storeSet)
+	case "add":
+		reply, err = s.store(c, args, storeAdd)
+	case "replace":
+		reply, err = s.store(c, args, storeReplace)
+	case "cas":
+		reply, err = s.store(c, args, storeCAS)
+	case "delete":
+		reply = s.delete(args)
+	case "incr":
+		reply = s.incrDecr(args, true)
+	case "decr":
+		reply = s.incrDecr(args, false)
+	case "touch":
+		reply = s.touch(args)
+	case "version":
+		return []byte("VERSION go-kv\r\n"), false, nil
+	case "quit":
+		return nil, true, nil
+	default:
+		return replyError, false, nil
+	}
+	return reply, false, err
+}
+
+// get writes a VALUE line and the data of every key found, followed by END.
+// withCAS adds the cas unique of the items for gets.
+func (s *Server) get(args [][]byte, withCAS bool) []byte {
+	if len(args) < 2 {
+		return replyError
+	}
+	now := time.Now()
+	var buf bytes.Buffer
+	for _, key := range args[1:] {
+		if !validKey(key) {
+			return replyBadFormat
+		}
+		it, err := s.lookup(key, now)
+		if err != nil {
+			return serverError(err.Error())
+		}
+		if it == nil {
+			continue
+		}
+		buf.WriteString("VALUE ")
+		buf.Write(key)
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatUint(uint64(it.flags), 10))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.Itoa(len(it.data)))
+		if withCAS {
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatUint(it.cas, 10))
+		}
+		buf.WriteString("\r\n")
+		buf.Write(it.data)
+		buf.WriteString("\r\n")
+	}
+	buf.Write(replyEnd)
+	return buf.Bytes()
+}
+
+// store reads the data block of a storage command and stores it according to the mode:
+//
+//	<command> <key> <flags> <exptime> <bytes> [noreply]
+//	cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+func (s *Server) store(c *client, args [][]byte, mode storeMode) ([]byte, error) {
+	numArgs := 5
+	if mode == storeCAS {
+		numArgs = 6
+	}
+	args, noreply := parseNoreply(args, numArgs)
+	if len(args) != numArgs || !validKey(args[1]) {
+		return replyBadFormat, nil
+	}
+	flags, err1 := strconv.ParseUint(string(args[2]), 10, 32)
+	exptime, err2 := strconv.ParseInt(string(args[3]), 10, 64)
+	size, err3 := strconv.Atoi(string(args[4]))
+	if err1 != nil || err2 != nil || err3 != nil || size < 0 {
+		return replyBadFormat, nil
+	}
+	var casUnique uint64
+	if mode == storeCAS {
+		var err error
+		if casUnique, err = strconv.ParseUint(string(args[5]), 10, 64); err != nil {
+			return replyBadFormat, nil
+		}
+	}
+
+	// the data block is consumed even if the item is rejected
+	if size > maxItemSize {
+		if _, err := c.r.Discard(size + 2); err != nil {
+			return nil, err
+		}
+		return withNoreply(replyTooLarge, noreply), nil
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		// skip the rest of the line, so the next line is read as a command
+		if data[size+1] != '\n' {
+			if _, err := c.r.ReadSlice('\n'); err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+				return nil, err
+			}
+		}
+		return withNoreply(replyBadChunk, noreply), nil
+	}
+
+	key := args[1]
+	now := time.Now()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	stored, err := s.storedItem(key)
+	if err != nil {
+		return withNoreply(serverError(err.Error()), noreply), nil
+	}
+	current := stored
+	if current != nil && current.expired(now) {
+		current = nil
+	}
+	switch {
+	case mode == storeAdd && current != nil, mode == storeReplace && current == nil:
+		return withNoreply(replyNotStored, noreply), nil
+	case mode == storeCAS && current == nil:
+		return withNoreply(replyNotFound, noreply), nil
+	case mode == storeCAS && current.cas != casUnique:
+		return withNoreply(replyExists, noreply), nil
+	}
+
+	it := &item{
+		flags:    uint32(flags),
+		deadline: deadlineOf(exptime, now),
+		cas:      s.cas.Add(1),
+		data:     data[:size],
+	}
+	if err = s.writeItem(key, stored, it); err != nil {
+		return withNoreply(serverError(err.Error()), noreply), nil
+	}
+	return withNoreply(replyStored, noreply), nil
+}
+
+// delete removes an item:
+//
+//	delete <key> [noreply]
+func (s *Server) delete(args [][]byte) []byte {
+	args, noreply := parseNoreply(args, 2)
+	if len(args) != 2 || !validKey(args[1]) {
+		return replyBadFormat
+	}
+
+	key := args[1]
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	it, err := s.lookup(key, time.Now())
+	if err != nil {
+		return withNoreply(serverError(err.Error()), noreply)
+	}
+	if it == nil {
+		return withNoreply(replyNotFound, noreply)
+	}
+	if err = s.writeItem(key, it, nil); err != nil {
+		return withNoreply(serverError(err.Error()), noreply)
+	}
+	return withNoreply(replyDeleted, noreply)
+}
+
+// incrDecr adds the delta to or subtracts it from a decimal 64-bit unsigned value.
+// Increments wrap around at 2^64 and decrements stop at 0, as in memcached:
+//
+//	incr <key> <delta> [noreply]
+//	decr <key> <delta> [noreply]
+func (s *Server) incrDecr(args [][]byte, incr bool) []byte {
+	args, noreply := parseNoreply(args, 3)
+	if len(args) != 3 || !validKey(args[1]) {
+		return replyBadFormat
+	}
+	delta, err := strconv.ParseUint(string(args[2]), 10, 64)
+	if err != nil {
+		return clientError("invalid numeric delta argument")
+	}
+
+	key := args[1]
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	it, err := s.lookup(key, time.Now())
+	if err != nil {
+		return withNoreply(serverError(err.Error()), noreply)
+	}
+	if it == nil {
+		return withNoreply(replyNotFound, noreply)
+	}
+	value, err := strconv.ParseUint(string(bytes.TrimSpace(it.data)), 10, 64)
+	if err != nil {
+		return withNoreply(replyNonNumeric, noreply)
+	}
+	switch {
+	case incr:
+		value += delta
+	case delta > value:
+		value = 0
+	default:
+		value -= delta
+	}
+
+	it.data = strconv.AppendUint(nil, value, 10)
+	it.cas = s.cas.Add(1)
+	if err = s.writeItem(key, it, it); err != nil {
+		return withNoreply(serverError(err.Error()), noreply)
+	}
+	return withNoreply(append(it.data, '\r', '\n'), noreply)
+}
+
+// touch updates the expiration time of an item without changing its data:
+//
+//	touch <key> <exptime> [noreply]
+func (s *Server) touch(args [][]byte) []byte {
+	args, noreply := parseNoreply(args, 3)
+	if len(args) != 3 || !validKey(args[1]) {
+		return replyBadFormat
+	}
+	exptime, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return replyBadFormat
+	}
+
+	key := args[1]
+	now := time.Now()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	it, err := s.lookup(key, now)
+	if err != nil {
+		return withNoreply(serverError(err.Error()), noreply)
+	}
+	if it == nil {
+		return withNoreply(replyNotFound, noreply)
+	}
+	touched := *it
+	touched.deadline = deadlineOf(exptime, now)
+	if err = s.writeItem(key, it, &touched); err != nil {
+		return withNoreply(serverError(err.Error()), noreply)
+	}
+	return withNoreply(replyTouched, noreply)
+}
+
+// lookup returns the item of the key, nil if the key does not exist or has expired.
+func (s *Server) lookup(key []byte, now time.Time) (*item, error) {
+	it, err := s.storedItem(key)
+	if err != nil || it == nil || it.expired(now) {
+		return nil, err
+	}
+	return it, nil
+}
+
+// storedItem returns the item stored for the key even if it has expired, nil if there is none.
+func (s *Server) storedItem(key []byte) (*item, error) {
+	value, err := s.items.Get(key)
+	if errors.Is(err, go_kv.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeItem(value)
+}
+
+// writeItem replaces the item stored for the key, prev, with it, or deletes it if it is nil,
+// and moves the entry of the item in the expiry family.
+// The writes are not batched, so they also work on a database that cannot create a WriteBatch.
+// They are ordered so that a crash in between never loses the entry of the stored item,
+// it can only leave an entry behind that removeExpired deletes.
+// Access this method needs s.writeMu is required.
+func (s *Server) writeItem(key []byte, prev, it *item) error {
+	if it != nil && it.deadline != 0 && (prev == nil || prev.deadline != it.deadline) {
+		if err := s.expiry.Put(expiryKey(it.deadline, key), nil); err != nil {
+			return err
+		}
+	}
+	var err error
+	if it == nil {
+		err = s.items.Delete(key)
+	} else {
+		err = s.items.Put(key, it.encode())
+	}
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.deadline != 0 && (it == nil || it.deadline != prev.deadline) {
+		return s.expiry.Delete(expiryKey(prev.deadline, key))
+	}
+	return nil
+}
+
+// parseNoreply strips a trailing noreply argument of a command taking numArgs arguments otherwise.
+func parseNoreply(args [][]byte, numArgs int) ([][]byte, bool) {
+	if len(args) == numArgs+1 && string(args[numArgs]) == "noreply" {
+		return args[:numArgs], true
+	}
+	return args, false
+}
+
+// withNoreply returns the reply, or nil if the client asked for no reply.
+func withNoreply(reply []byte, noreply bool) []byte {
+	if noreply {
+		return nil
+	}
+	return reply
+}
@@ -0,0 +1,133 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	maxKeySize         = 250               // maximum size of a key in bytes
+	maxItemSize        = 1024 * 1024       // maximum size of the data of an item in bytes
+	maxLineSize        = 64 * 1024         // maximum size of a command line in bytes
+	maxRelativeExptime = 60 * 60 * 24 * 30 // larger expiration times are absolute unix times
+	itemHeaderSize     = 4 + 8 + 8         // flags, deadline and cas unique
+)
+
+var (
+	// errLineTooLong is returned when a command line does not fit in the read buffer.
+	errLineTooLong = errors.New("line too long")
+	// errCorruptItem is returned when a stored item is shorter than its header.
+	errCorruptItem = errors.New("corrupt item")
+)
+
+// Replies of the memcached text protocol.
+var (
+	replyStored     = []byte("STORED\r\n")
+	replyNotStored  = []byte("NOT_STORED\r\n")
+	replyExists     = []byte("EXISTS\r\n")
+	replyNotFound   = []byte("NOT_FOUND\r\n")
+	replyDeleted    = []byte("DELETED\r\n")
+	replyTouched    = []byte("TOUCHED\r\n")
+	replyEnd        = []byte("END\r\n")
+	replyError      = []byte("ERROR\r\n")
+	replyBadFormat  = clientError("bad command line format")
+	replyBadChunk   = clientError("bad data chunk")
+	replyTooLarge   = serverError("object too large for cache")
+	replyNonNumeric = clientError("cannot increment or decrement non-numeric value")
+)
+
+// item is the value stored for a key: the client flags, the expiration deadline,
+// the cas unique and the data, encoded as a fixed size header followed by the data.
+type item struct {
+	flags    uint32
+	deadline int64 // unix seconds, 0 means the item never expires
+	cas      uint64
+	data     []byte
+}
+
+// encode encodes the item as it is stored in the database.
+func (it *item) encode() []byte {
+	buf := make([]byte, itemHeaderSize+len(it.data))
+	binary.BigEndian.PutUint32(buf[0:4], it.flags)
+	binary.BigEndian.PutUint64(buf[4:12], uint64(it.deadline))
+	binary.BigEndian.PutUint64(buf[12:20], it.cas)
+	copy(buf[itemHeaderSize:], it.data)
+	return buf
+}
+
+// decodeItem decodes an item stored in the database.
+func decodeItem(buf []byte) (*item, error) {
+	if len(buf) < itemHeaderSize {
+		return nil, errCorruptItem
+	}
+	return &item{
+		flags:    binary.BigEndian.Uint32(buf[0:4]),
+		deadline: int64(binary.BigEndian.Uint64(buf[4:12])),
+		cas:      binary.BigEndian.Uint64(buf[12:20]),
+		data:     buf[itemHeaderSize:],
+	}, nil
+}
+
+// expired reports whether the item has expired at the time.
+func (it *item) expired(now time.Time) bool {
+	return it.deadline != 0 && it.deadline <= now.Unix()
+}
+
+// expiryKey returns the key of an item in the expiry family: the deadline in big endian followed by the key,
+// so that the entries sort by deadline and the value is empty.
+func expiryKey(deadline int64, key []byte) []byte {
+	buf := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(buf, uint64(deadline))
+	copy(buf[8:], key)
+	return buf
+}
+
+// parseExpiryKey returns the deadline and the key of an entry of the expiry family.
+func parseExpiryKey(buf []byte) (int64, []byte) {
+	return int64(binary.BigEndian.Uint64(buf[:8])), buf[8:]
+}
+
+// deadlineOf converts an expiration time of a command to a deadline in unix seconds.
+// 0 never expires, up to 30 days is relative to now, larger values are absolute
+// unix times and negative values expire immediately.
+func deadlineOf(exptime int64, now time.Time) int64 {
+	switch {
+	case exptime == 0:
+		return 0
+	case exptime < 0:
+		return now.Unix()
+	case exptime <= maxRelativeExptime:
+		return now.Unix() + exptime
+	default:
+		return exptime
+	}
+}
+
+// readLine reads a line terminated by \r\n or \n without the terminator.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, errLineTooLong
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+	return append([]byte(nil), line...), nil
+}
+
+// validKey reports whether the key can be used in a command.
+func validKey(key []byte) bool {
+	return len(key) > 0 && len(key) <= maxKeySize
+}
+
+func clientError(msg string) []byte {
+	return []byte("CLIENT_ERROR " + msg + "\r\n")
+}
+
+func serverError(msg string) []byte {
+	return []byte("SERVER_ERROR " + msg + "\r\n")
+}
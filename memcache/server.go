@@ -0,0 +1,213 @@
+// Package memcache serves a go-kv database over the memcached text protocol,
+// so existing memcached clients can use it as a persistent cache.
+//
+// The storage commands set, add, replace and cas, the retrieval commands get and gets,
+// and delete, incr, decr, touch, version and quit are supported.
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	go_kv "go-kv"
+	"go-kv/internal/netserver"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// itemFamily is the column family holding the items, so they do not mix with plain keys of the DB.
+	itemFamily = "memcached"
+	// expiryFamily is the column family indexing the items that expire by deadline, see expiryKey.
+	expiryFamily = "memcached-expiry"
+	// expireInterval is the interval between two scans for expired items.
+	// Expired items are never returned, the scan only reclaims their space.
+	expireInterval = time.Second
+)
+
+// ErrServerClosed is returned by Serve after Close is called.
+var ErrServerClosed = errors.New("memcache: server closed")
+
+// Server serves memcached clients on top of a DB. The DB is owned by the caller and is not closed by the server.
+type Server struct {
+	db     *go_kv.DB
+	items  *go_kv.ColumnFamily
+	expiry *go_kv.ColumnFamily // key-only index of the deadlines of the items
+
+	writeMu sync.Mutex    // serializes the read-check-write sequences of the write commands
+	cas     atomic.Uint64 // last cas unique handed out
+
+	conns *netserver.Server // accepts the connections and tracks them until Close
+
+	closeOnce  sync.Once
+	expireStop chan struct{} // closed to stop the background expiration goroutine
+	expireDone chan struct{} // closed by the background expiration goroutine when it exits
+}
+
+// client is the state of one connection.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewServer creates a server for the database.
+func NewServer(db *go_kv.DB) (*Server, error) {
+	items, _, err := openFamily(db, itemFamily)
+	if err != nil {
+		return nil, err
+	}
+	expiry, created, err := openFamily(db, expiryFamily)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		db:         db,
+		items:      items,
+		expiry:     expiry,
+		conns:      netserver.New(ErrServerClosed),
+		expireStop: make(chan struct{}),
+		expireDone: make(chan struct{}),
+	}
+	// cas uniques start at the current time, so they keep growing across restarts
+	s.cas.Store(uint64(time.Now().UnixNano()))
+	if created {
+		// items stored before the expiry family existed
+		if err = s.indexDeadlines(); err != nil {
+			return nil, err
+		}
+	}
+	go s.expirePeriodically()
+	return s, nil
+}
+
+// openFamily returns the column family of the name, creating it if it does not exist.
+func openFamily(db *go_kv.DB, name string) (cf *go_kv.ColumnFamily, created bool, err error) {
+	cf, err = db.ColumnFamily(name)
+	if errors.Is(err, go_kv.ErrColumnFamilyNotFound) {
+		cf, err = db.CreateColumnFamily(name)
+		created = err == nil
+	}
+	return cf, created, err
+}
+
+// indexDeadlines adds the entries of all items with a deadline to the expiry family.
+func (s *Server) indexDeadlines() error {
+	iterator := s.items.NewIterator(go_kv.DefaultIteratorOptions)
+	defer iterator.Close()
+	for ; iterator.Valid(); iterator.Next() {
+		value, err := iterator.Value()
+		if err != nil {
+			return err
+		}
+		it, err := decodeItem(value)
+		if err != nil || it.deadline == 0 {
+			continue
+		}
+		if err = s.expiry.Put(expiryKey(it.deadline, iterator.Key()), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListenAndServe listens on the TCP address and serves clients until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	return s.conns.ListenAndServe(addr, s.serveConn)
+}
+
+// Serve accepts connections on the listener and serves each of them in its own goroutine
+// until Close is called, then it returns ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	return s.conns.Serve(listener, s.serveConn)
+}
+
+// Close stops accepting connections, closes all open connections and waits for their goroutines to exit.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.expireStop)
+		<-s.expireDone
+	})
+	return s.conns.Close()
+}
+
+// serveConn reads commands from the connection and writes their replies until the client quits.
+// Replies of pipelined commands are flushed together.
+func (s *Server) serveConn(conn net.Conn) {
+	c := &client{
+		conn: conn,
+		r:    bufio.NewReaderSize(conn, maxLineSize),
+		w:    bufio.NewWriter(conn),
+	}
+	for {
+		line, err := readLine(c.r)
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				_, _ = c.w.Write(clientError("line too long"))
+				_ = c.w.Flush()
+			}
+			return
+		}
+
+		reply, quit, err := s.handle(c, line)
+		if err != nil {
+			return
+		}
+		_, _ = c.w.Write(reply)
+		if quit || c.r.Buffered() == 0 {
+			if err = c.w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// expirePeriodically removes expired items every expireInterval until the server is closed.
+func (s *Server) expirePeriodically() {
+	defer close(s.expireDone)
+
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.removeExpired(time.Now())
+		case <-s.expireStop:
+			return
+		}
+	}
+}
+
+// removeExpired deletes all items that have expired at the time.
+// Only the entries of the expiry family that are due are visited.
+func (s *Server) removeExpired(now time.Time) error {
+	var due [][]byte
+	iterator := s.expiry.NewIterator(go_kv.IteratorOptions{UpperBound: expiryKey(now.Unix()+1, nil)})
+	for ; iterator.Valid(); iterator.Next() {
+		due = append(due, append([]byte(nil), iterator.Key()...))
+	}
+	iterator.Close()
+
+	// the item may have been rewritten since the scan
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	for _, entry := range due {
+		deadline, key := parseExpiryKey(entry)
+		it, err := s.storedItem(key)
+		if err != nil {
+			return err
+		}
+		if it == nil || it.deadline != deadline {
+			// not the entry of the stored item, left behind by a crash during writeItem
+			if err = s.expiry.Delete(entry); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = s.writeItem(key, it, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
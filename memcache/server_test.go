@@ -0,0 +1,359 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	go_kv "go-kv"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is a minimal memcached text protocol client for the tests.
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// startTestServer starts a server on a local listener and returns a client connected to it.
+func startTestServer(t *testing.T) (*Server, *testClient) {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-memcache")
+	opts.DirPath = dir
+	opts.IndexType = go_kv.Btree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return serveTestDB(t, db)
+}
+
+// openUnclosedBPlusTree returns a B+Tree database opened from a copy of a directory
+// whose database was never closed, so it has no sequence number file.
+func openUnclosedBPlusTree(t *testing.T) *go_kv.DB {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-memcache-unclosed")
+	opts.DirPath = dir
+	opts.IndexType = go_kv.BPlusTree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	copyDir, _ := os.MkdirTemp("", "bitcask-go-memcache-unclosed-copy")
+	err = os.CopyFS(copyDir, os.DirFS(dir))
+	_ = db.Close()
+	_ = os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.DirPath = copyDir
+	db, err = go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(copyDir)
+	})
+	if err = db.CheckWriteBatch(); !errors.Is(err, go_kv.ErrSeqNoFileNotFound) {
+		t.Fatalf("CheckWriteBatch() error = %v, wantErr %v", err, go_kv.ErrSeqNoFileNotFound)
+	}
+	return db
+}
+
+// serveTestDB starts a server for the database on a local listener and returns a client connected to it.
+func serveTestDB(t *testing.T, db *go_kv.DB) (*Server, *testClient) {
+	server, err := NewServer(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = server.Close()
+		if err := <-served; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve() error = %v, wantErr %v", err, ErrServerClosed)
+		}
+	})
+	return server, &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// do sends a request and returns its reply, see readTestReply.
+func (c *testClient) do(t *testing.T, request string) string {
+	if _, err := c.conn.Write([]byte(request)); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := readTestReply(c.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+// readTestReply reads one reply: a single line, or the VALUE lines and data blocks of a retrieval up to END.
+func readTestReply(r *bufio.Reader) (string, error) {
+	var reply strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		reply.WriteString(line)
+		if !strings.HasPrefix(line, "VALUE ") {
+			return reply.String(), nil
+		}
+		fields := strings.Fields(line)
+		size, _ := strconv.Atoi(fields[3])
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		reply.Write(buf)
+	}
+}
+
+func TestServer_Commands(t *testing.T) {
+	_, client := startTestServer(t)
+
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{name: "set", request: "set user:1 5 0 5\r\nalice\r\n", want: "STORED\r\n"},
+		{name: "get", request: "get user:1\r\n", want: "VALUE user:1 5 5\r\nalice\r\nEND\r\n"},
+		{name: "get missing", request: "get user:9\r\n", want: "END\r\n"},
+		{name: "add existing", request: "add user:1 0 0 3\r\nbob\r\n", want: "NOT_STORED\r\n"},
+		{name: "replace missing", request: "replace user:2 0 0 3\r\nbob\r\n", want: "NOT_STORED\r\n"},
+		{name: "add missing", request: "add user:2 0 0 3\r\nbob\r\n", want: "STORED\r\n"},
+		{name: "replace existing", request: "replace user:2 7 0 5\r\ncarol\r\n", want: "STORED\r\n"},
+		{name: "get many", request: "get user:1 user:9 user:2\r\n", want: "VALUE user:1 5 5\r\nalice\r\nVALUE user:2 7 5\r\ncarol\r\nEND\r\n"},
+		{name: "binary data", request: "set bin 0 0 4\r\n\r\n\x00\n\r\n", want: "STORED\r\n"},
+		{name: "get binary data", request: "get bin\r\n", want: "VALUE bin 0 4\r\n\r\n\x00\n\r\nEND\r\n"},
+		{name: "delete", request: "delete user:1\r\n", want: "DELETED\r\n"},
+		{name: "delete missing", request: "delete user:1\r\n", want: "NOT_FOUND\r\n"},
+		{name: "set counter", request: "set counter 0 0 2\r\n10\r\n", want: "STORED\r\n"},
+		{name: "incr", request: "incr counter 5\r\n", want: "15\r\n"},
+		{name: "decr", request: "decr counter 3\r\n", want: "12\r\n"},
+		{name: "decr below zero", request: "decr counter 100\r\n", want: "0\r\n"},
+		{name: "get counter", request: "get counter\r\n", want: "VALUE counter 0 1\r\n0\r\nEND\r\n"},
+		{name: "incr wraps", request: "incr counter 18446744073709551615\r\n", want: "18446744073709551615\r\n"},
+		{name: "incr wrapped", request: "incr counter 2\r\n", want: "1\r\n"},
+		{name: "incr missing", request: "incr nothing 1\r\n", want: "NOT_FOUND\r\n"},
+		{name: "incr non numeric", request: "incr user:2 1\r\n", want: "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"},
+		{name: "incr invalid delta", request: "incr counter -1\r\n", want: "CLIENT_ERROR invalid numeric delta argument\r\n"},
+		{name: "touch", request: "touch user:2 100\r\n", want: "TOUCHED\r\n"},
+		{name: "touch missing", request: "touch user:1 100\r\n", want: "NOT_FOUND\r\n"},
+		{name: "bad data chunk", request: "set user:3 0 0 2\r\nabc\r\n", want: "CLIENT_ERROR bad data chunk\r\n"},
+		{name: "line after bad chunk", request: "get user:3\r\n", want: "END\r\n"},
+		{name: "bad format", request: "set user:3 x 0 1\r\n", want: "CLIENT_ERROR bad command line format\r\n"},
+		{name: "key too long", request: "get " + strings.Repeat("k", maxKeySize+1) + "\r\n", want: "CLIENT_ERROR bad command line format\r\n"},
+		{name: "unknown command", request: "flush_all\r\n", want: "ERROR\r\n"},
+		{name: "version", request: "version\r\n", want: "VERSION go-kv\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := client.do(t, tt.request); got != tt.want {
+				t.Errorf("%q got = %q, want %q", tt.request, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_UnclosedBPlusTree(t *testing.T) {
+	server, client := serveTestDB(t, openUnclosedBPlusTree(t))
+
+	// every command writing an item works without a WriteBatch
+	requests := []struct {
+		request string
+		want    string
+	}{
+		{request: "set user:1 0 100 5\r\nalice\r\n", want: "STORED\r\n"},
+		{request: "add user:2 0 0 2\r\n10\r\n", want: "STORED\r\n"},
+		{request: "incr user:2 5\r\n", want: "15\r\n"},
+		{request: "touch user:2 100\r\n", want: "TOUCHED\r\n"},
+		{request: "delete user:1\r\n", want: "DELETED\r\n"},
+		{request: "get user:1 user:2\r\n", want: "VALUE user:2 0 2\r\n15\r\nEND\r\n"},
+	}
+	for _, tt := range requests {
+		if got := client.do(t, tt.request); got != tt.want {
+			t.Errorf("%q got = %q, want %q", tt.request, got, tt.want)
+		}
+	}
+	cas := getsCAS(t, client, "user:2")
+	if got := client.do(t, "cas user:2 0 100 1 "+cas+"\r\n7\r\n"); got != "STORED\r\n" {
+		t.Errorf("cas got = %q, want %q", got, "STORED\r\n")
+	}
+
+	// the expiry family holds the entry of user:2 only
+	var entries int
+	iterator := server.expiry.NewIterator(go_kv.DefaultIteratorOptions)
+	for ; iterator.Valid(); iterator.Next() {
+		entries++
+	}
+	iterator.Close()
+	if entries != 1 {
+		t.Errorf("expiry entries = %d, want 1", entries)
+	}
+}
+
+func TestServer_CAS(t *testing.T) {
+	_, client := startTestServer(t)
+
+	if got := client.do(t, "cas user:1 0 0 5 1\r\nalice\r\n"); got != "NOT_FOUND\r\n" {
+		t.Errorf("cas missing got = %q, want %q", got, "NOT_FOUND\r\n")
+	}
+	client.do(t, "set user:1 0 0 5\r\nalice\r\n")
+	casUnique := getsCAS(t, client, "user:1")
+
+	// a write in between changes the cas unique
+	client.do(t, "set user:1 0 0 3\r\nbob\r\n")
+	if got := client.do(t, "cas user:1 0 0 5 "+casUnique+"\r\ncarol\r\n"); got != "EXISTS\r\n" {
+		t.Errorf("cas stale got = %q, want %q", got, "EXISTS\r\n")
+	}
+
+	casUnique = getsCAS(t, client, "user:1")
+	if got := client.do(t, "cas user:1 3 0 5 "+casUnique+"\r\ncarol\r\n"); got != "STORED\r\n" {
+		t.Errorf("cas got = %q, want %q", got, "STORED\r\n")
+	}
+	if got, want := client.do(t, "get user:1\r\n"), "VALUE user:1 3 5\r\ncarol\r\nEND\r\n"; got != want {
+		t.Errorf("get got = %q, want %q", got, want)
+	}
+	if getsCAS(t, client, "user:1") == casUnique {
+		t.Error("cas unique did not change after cas")
+	}
+}
+
+// getsCAS returns the cas unique of the key from a gets reply.
+func getsCAS(t *testing.T, client *testClient, key string) string {
+	reply := client.do(t, "gets "+key+"\r\n")
+	fields := strings.Fields(strings.SplitN(reply, "\r\n", 2)[0])
+	if len(fields) != 5 || fields[0] != "VALUE" {
+		t.Fatalf("gets %s got = %q", key, reply)
+	}
+	return fields[4]
+}
+
+func TestServer_Expire(t *testing.T) {
+	server, client := startTestServer(t)
+
+	client.do(t, "set session 0 100 2\r\nok\r\n")
+	client.do(t, "set gone 0 -1 2\r\nok\r\n")
+	if got, want := client.do(t, "get session gone\r\n"), "VALUE session 0 2\r\nok\r\nEND\r\n"; got != want {
+		t.Errorf("get got = %q, want %q", got, want)
+	}
+	if got := client.do(t, "add gone 0 0 2\r\nok\r\n"); got != "STORED\r\n" {
+		t.Errorf("add expired got = %q, want %q", got, "STORED\r\n")
+	}
+	if got := client.do(t, "touch gone -1\r\n"); got != "TOUCHED\r\n" {
+		t.Errorf("touch got = %q, want %q", got, "TOUCHED\r\n")
+	}
+	if got := client.do(t, "get gone\r\n"); got != "END\r\n" {
+		t.Errorf("get touched got = %q, want %q", got, "END\r\n")
+	}
+
+	// the scan reclaims expired items only
+	if err := server.removeExpired(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.items.Get([]byte("gone")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+	if _, err := server.items.Get([]byte("session")); err != nil {
+		t.Errorf("Get() error = %v, wantErr nil", err)
+	}
+	if err := server.removeExpired(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.items.Get([]byte("session")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+	iterator := server.expiry.NewIterator(go_kv.DefaultIteratorOptions)
+	defer iterator.Close()
+	if iterator.Valid() {
+		t.Errorf("expiry entry %q left, want none", iterator.Key())
+	}
+}
+
+func TestServer_IndexDeadlines(t *testing.T) {
+	server, _ := startTestServer(t)
+	// items of a database written before the expiry family existed
+	it := &item{deadline: time.Now().Unix() - 1, data: []byte("ok")}
+	if err := server.items.Put([]byte("old"), it.encode()); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.db.DropColumnFamily(expiryFamily); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewServer(server.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if err = server.removeExpired(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = server.items.Get([]byte("old")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+}
+
+func TestServer_NoreplyAndPipeline(t *testing.T) {
+	_, client := startTestServer(t)
+
+	// replies of noreply commands are suppressed, the get reply is the first one written
+	request := "set a 0 0 1 noreply\r\n1\r\nincr a 2 noreply\r\nset b 0 0 1\r\n2\r\nget a b\r\n"
+	if got, want := client.do(t, request), "STORED\r\n"; got != want {
+		t.Errorf("got = %q, want %q", got, want)
+	}
+	reply, err := readTestReply(client.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "VALUE a 0 1\r\n3\r\nVALUE b 0 1\r\n2\r\nEND\r\n"; reply != want {
+		t.Errorf("got = %q, want %q", reply, want)
+	}
+
+	// oversized data is consumed so the next command is read correctly
+	request = "set big 0 0 " + strconv.Itoa(maxItemSize+1) + "\r\n" + strings.Repeat("x", maxItemSize+1) + "\r\nget big\r\n"
+	if got, want := client.do(t, request), "SERVER_ERROR object too large for cache\r\n"; got != want {
+		t.Errorf("got = %q, want %q", got, want)
+	}
+	if reply, _ = readTestReply(client.r); reply != "END\r\n" {
+		t.Errorf("got = %q, want %q", reply, "END\r\n")
+	}
+
+	// quit closes the connection without a reply
+	if _, err = client.conn.Write([]byte("quit\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.r.ReadByte(); !errors.Is(err, io.EOF) {
+		t.Errorf("read after quit error = %v, wantErr %v", err, io.EOF)
+	}
+}
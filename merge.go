@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"time"
 )
 
 const (
@@ -19,6 +20,9 @@ const (
 // clear unused data in destination DB.
 // generate Hint file.
 func (db *DB) Merge() error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
 	// validate source DB
 	if db.activeFile == nil {
 		return nil
@@ -36,8 +40,14 @@ func (db *DB) Merge() error {
 	}
 	db.isMerging = true
 
+	// the data files a backup is linking must not be replaced
+	if db.isBackingUp {
+		db.mut.Unlock()
+		return ErrBackupIsProgress
+	}
+
 	// sync active file to disk
-	if err := db.activeFile.Sync(); err != nil {
+	if err := db.syncActiveFile(); err != nil {
 		db.mut.Unlock()
 		return err
 	}
@@ -80,6 +90,8 @@ func (db *DB) Merge() error {
 	mergeOptions := db.options
 	mergeOptions.DirPath = mergePath
 	mergeOptions.SyncWrites = false
+	mergeOptions.BytesPerSync = 0
+	mergeOptions.SyncInterval = 0
 	mergeDB, err := Open(mergeOptions)
 	if err != nil {
 		return err
@@ -104,9 +116,16 @@ func (db *DB) Merge() error {
 				return err
 			}
 
-			// parse data get real key
+			// parse data get real key, deleted records and range tombstones are never
+			// referenced by the index, so they are dropped from the merged files
+			// records of dropped column families have no index and are dropped as well
 			_, origKey := parseLogRecordKey(logRecord.Key)
-			logRecordPos := db.index.Get(origKey)
+			var logRecordPos *data.LogRecordPos
+			db.mut.RLock()
+			if indexer := db.indexOf(logRecord.Family); indexer != nil {
+				logRecordPos = indexer.Get(origKey)
+			}
+			db.mut.RUnlock()
 			// compare log record position with index position
 			if logRecordPos != nil &&
 				logRecordPos.Fid == dataFile.FileId &&
@@ -119,7 +138,7 @@ func (db *DB) Merge() error {
 				}
 
 				// write current position index to Hint file
-				err = hintFile.WriteHintRecord(origKey, pos)
+				err = hintFile.WriteHintRecord(origKey, logRecord.Family, pos)
 				if err != nil {
 					return err
 				}
@@ -143,9 +162,11 @@ func (db *DB) Merge() error {
 		return err
 	}
 
+	// the timestamp tells point-in-time restores which points the merged files can serve
 	mergeFinRecord := &data.LogRecord{
-		Key:   []byte(mergeFinishedKey),
-		Value: []byte(strconv.Itoa(int(nonMergeFileId))),
+		Key:       []byte(mergeFinishedKey),
+		Value:     []byte(strconv.Itoa(int(nonMergeFileId))),
+		Timestamp: time.Now().UnixMilli(),
 	}
 	encRecord, _ := data.EncodeLogRecord(mergeFinRecord)
 	if err = mergeFinishedFile.Write(encRecord); err != nil {
@@ -204,11 +225,11 @@ func (db *DB) loadMergeFiles() error {
 		return err
 	}
 
-	// delete old data files
+	// delete old data files, the non-merge file and newer ones are kept
 	var fileId uint32
-	for ; fileId <= nonMergeFileId; fileId++ {
+	for ; fileId < nonMergeFileId; fileId++ {
 		fileName := data.GetDataFileName(db.options.DirPath, fileId)
-		if _, err := os.Stat(fileName); err != nil {
+		if _, err := os.Stat(fileName); err == nil {
 			if err = os.Remove(fileName); err != nil {
 				return err
 			}
@@ -227,11 +248,14 @@ func (db *DB) loadMergeFiles() error {
 	return nil
 }
 
+// getNonMergeFileId reads the id of the first data file merge did not rewrite from the merge finished file in dirPath.
+// The file is only read, so it is opened read-only.
 func (db *DB) getNonMergeFileId(dirPath string) (uint32, error) {
-	mergeFinishedFile, err := data.OpenMergeFinishedFile(dirPath)
+	mergeFinishedFile, err := data.OpenFileReadOnly(filepath.Join(dirPath, data.MergeFinishedFileName), 0)
 	if err != nil {
 		return 0, err
 	}
+	defer mergeFinishedFile.Close()
 	record, _, err := mergeFinishedFile.ReadLogRecord(0)
 	if err != nil {
 		return 0, err
@@ -252,7 +276,13 @@ func (db *DB) loadIndexFormHintFile() error {
 	}
 
 	// open hint index file
-	hintFile, err := data.OpenHintFile(db.options.DirPath)
+	var hintFile *data.DataFile
+	var err error
+	if db.options.ReadOnly {
+		hintFile, err = data.OpenFileReadOnly(hintFileName, 0)
+	} else {
+		hintFile, err = data.OpenHintFile(db.options.DirPath)
+	}
 	if err != nil {
 		return err
 	}
@@ -268,9 +298,11 @@ func (db *DB) loadIndexFormHintFile() error {
 			return err
 		}
 
-		// decode log record index position
+		// decode log record index position, hints of dropped column families are skipped
 		pos := data.DecodeLogRecordPos(logRecord.Value)
-		db.index.Put(logRecord.Key, pos)
+		if indexer := db.indexOf(logRecord.Family); indexer != nil {
+			indexer.Put(logRecord.Key, pos)
+		}
 
 		// move offset to next record
 		offset += size
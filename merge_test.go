@@ -0,0 +1,96 @@
+package go_kv
+
+import (
+	"fmt"
+	"go-kv/data"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// dataFilesSize returns the total size of the data files in dirPath with an id not less than minFileId.
+func dataFilesSize(t *testing.T, dirPath string, minFileId uint32) int64 {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), data.DataFileNameSuffix) {
+			continue
+		}
+		fileId, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), data.DataFileNameSuffix), 10, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint32(fileId) < minFileId {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+	}
+	return size
+}
+
+func TestDB_loadMergeFiles(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge")
+	opts.DirPath = dir
+	opts.DataFileSize = 4 * 1024
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every key is written twice, merge keeps the second value only
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 200; i++ {
+			if err = db.Put([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("value-%03d-%d", i, round))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	// written to the data file merge did not rewrite, it must survive applying the merge
+	if err = db.Put([]byte("after"), []byte("merge")); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the merged files replace every data file before the non-merge file
+	mergePath := db.getMergePath()
+	nonMergeFileId, err := db.getNonMergeFileId(mergePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSize := dataFilesSize(t, mergePath, 0) + dataFilesSize(t, dir, nonMergeFileId)
+
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+	if _, err = os.Stat(mergePath); !os.IsNotExist(err) {
+		t.Errorf("merge directory left after Open(), Stat() error = %v", err)
+	}
+	if size := dataFilesSize(t, dir, 0); size != wantSize {
+		t.Errorf("data files size = %d after applying the merge, want %d", size, wantSize)
+	}
+	for i := 0; i < 200; i++ {
+		want := fmt.Sprintf("value-%03d-1", i)
+		if value, err := db.Get([]byte(fmt.Sprintf("key-%03d", i))); err != nil || string(value) != want {
+			t.Fatalf("Get(key-%03d) = %q, %v, want %q", i, value, err, want)
+		}
+	}
+	if value, err := db.Get([]byte("after")); err != nil || string(value) != "merge" {
+		t.Errorf("Get(after) = %q, %v, want %q", value, err, "merge")
+	}
+}
@@ -1,18 +1,30 @@
 package go_kv
 
-import "os"
+import (
+	"go-kv/data"
+	"go-kv/index"
+	"os"
+	"strings"
+	"time"
+)
 
 type Options struct {
-	DirPath      string    // directory path to store the data
-	DataFileSize int64     // size of each data file in bytes
-	SyncWrites   bool      // whether to sync writes to disk or not
-	IndexType    IndexType // type of index to use for lookups
+	DirPath      string        // directory path to store the data
+	DataFileSize int64         // size of each data file in bytes
+	SyncWrites   bool          // whether to sync writes to disk or not
+	BytesPerSync uint          // sync to disk after this many bytes are written, 0 disables it
+	SyncInterval time.Duration // sync to disk periodically in the background, 0 disables it
+	IndexType    IndexType     // type of index to use for lookups
+	ReadOnly     bool          // open an existing database without changing any of its files, writes return ErrReadOnly
 }
 
 // IteratorOptions is a struct for options to be used while iterating over the data.
 type IteratorOptions struct {
-	Prefix  []byte // prefix to filter keys by, default is nil to return all keys
-	Reverse bool   // whether to iterate in reverse order or not, default is false
+	Prefix     []byte // prefix to filter keys by, default is nil to return all keys
+	Reverse    bool   // whether to iterate in reverse order or not, default is false
+	LowerBound []byte // inclusive lower bound of keys, default is nil for no lower bound
+	UpperBound []byte // exclusive upper bound of keys, default is nil for no upper bound
+	Limit      int    // maximum number of keys to visit after positioning, default is 0 for no limit
 }
 
 // WriteBatchOptions is a struct for options to be used while writing a batch of data.
@@ -32,16 +44,40 @@ const (
 	BPlusTree
 )
 
+// DetectIndexType returns the index type the database in dirPath was written with.
+// Only BPlusTree keeps its index on disk, Btree and ART rebuild theirs from the same data files,
+// so Btree is returned for both. ok is false if dirPath is missing or holds no database yet.
+func DetectIndexType(dirPath string) (typ IndexType, ok bool) {
+	if index.BPlusTreeExists(dirPath) {
+		return BPlusTree, true
+	}
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return 0, false
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), data.DataFileNameSuffix) {
+			return Btree, true
+		}
+	}
+	return 0, false
+}
+
 var DefaultOptions = Options{
 	DirPath:      os.TempDir(),
 	DataFileSize: 256 * 1024 * 1024, // 256MB
 	SyncWrites:   false,
+	BytesPerSync: 0,
+	SyncInterval: 0,
 	IndexType:    BPlusTree,
 }
 
 var DefaultIteratorOptions = IteratorOptions{
-	Prefix:  nil,
-	Reverse: false,
+	Prefix:     nil,
+	Reverse:    false,
+	LowerBound: nil,
+	UpperBound: nil,
+	Limit:      0,
 }
 
 var DefaultWriteBatchOptions = WriteBatchOptions{
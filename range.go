@@ -0,0 +1,105 @@
+package go_kv
+
+import (
+	"bytes"
+	"go-kv/data"
+	"go-kv/index"
+)
+
+// deleteRangeChunkSize is the number of keys collected from the memory index before they are deleted,
+// so deleting a large range does not copy all of its keys at once.
+const deleteRangeChunkSize = 1024
+
+// DeleteRange deletes all keys in the range [start, end).
+// An empty start begins from the first key, an empty end runs to the last key.
+// The whole range is recorded as a single range tombstone log record.
+// It returns ErrInvalidRange if end is not greater than start.
+func (db *DB) DeleteRange(start, end []byte) error {
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return ErrInvalidRange
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+	return db.deleteFamilyRange(defaultFamilyId, db.index, start, end)
+}
+
+// deleteFamilyRange deletes all keys in the range [start, end) of a column family with one range tombstone.
+// Access this method needs db.mut is required.
+func (db *DB) deleteFamilyRange(family uint32, indexer index.Indexer, start, end []byte) error {
+	// nothing to delete, skip writing the range tombstone
+	if len(indexKeysInRange(indexer, start, end, 1)) == 0 {
+		return nil
+	}
+
+	// new range tombstone log record
+	logRecord := &data.LogRecord{
+		Key:    logRecordKeyWithSeq(start, nonTransactionalSeqNo),
+		Value:  end,
+		Type:   data.LogRecordRangeDeleted,
+		Family: family,
+	}
+	if _, err := db.appendLogRecord(logRecord); err != nil {
+		return err
+	}
+
+	// update memory index
+	return deleteIndexRange(indexer, start, end)
+}
+
+// DeletePrefix deletes all keys starting with the given prefix.
+func (db *DB) DeletePrefix(prefix []byte) error {
+	if len(prefix) == 0 {
+		return ErrKeyIsEmpty
+	}
+	return db.DeleteRange(prefix, prefixUpperBound(prefix))
+}
+
+// deleteIndexRange removes all keys in the range [start, end) from the memory index,
+// deleteRangeChunkSize keys at a time.
+func deleteIndexRange(indexer index.Indexer, start, end []byte) error {
+	for {
+		keys := indexKeysInRange(indexer, start, end, deleteRangeChunkSize)
+		for _, key := range keys {
+			if ok := indexer.Delete(key); !ok {
+				return ErrIndexUpdateFailed
+			}
+		}
+		if len(keys) < deleteRangeChunkSize {
+			return nil
+		}
+		// the next chunk starts after the last deleted key
+		start = keys[len(keys)-1]
+	}
+}
+
+// indexKeysInRange returns a copy of the first keys of the memory index in the range [start, end), at most limit of them.
+// An empty end means the range is unbounded.
+// The iterator is closed before returning, so the keys can be deleted from the index.
+func indexKeysInRange(indexer index.Indexer, start, end []byte, limit int) [][]byte {
+	iterator := indexer.Iterator(false)
+	defer iterator.Close()
+
+	var keys [][]byte
+	for iterator.Seek(start); iterator.Valid() && len(keys) < limit; iterator.Next() {
+		key := iterator.Key()
+		if len(end) > 0 && bytes.Compare(key, end) >= 0 {
+			break
+		}
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	return keys
+}
+
+// prefixUpperBound returns the smallest key greater than all keys with the given prefix.
+// It returns nil if no such key exists, i.e. the prefix consists only of 0xff bytes.
+func prefixUpperBound(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
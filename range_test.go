@@ -0,0 +1,246 @@
+package go_kv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// putTenantKeys writes n keys for each tenant, keyed as "tenant-<t>/key-<i>".
+func putTenantKeys(t *testing.T, db *DB, tenants []string, n int) {
+	for _, tenant := range tenants {
+		for i := 0; i < n; i++ {
+			key := []byte(fmt.Sprintf("%s/key-%03d", tenant, i))
+			if err := db.Put(key, []byte(tenant)); err != nil {
+				t.Errorf("Put() error = %v", err)
+			}
+		}
+	}
+}
+
+// countPrefix returns the number of listed keys with the given prefix.
+func countPrefix(db *DB, prefix string) int {
+	var n int
+	for _, key := range db.ListKeys() {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDB_DeleteRange(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-delete-range")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+	putTenantKeys(t, db, []string{"tenant-a", "tenant-b", "tenant-c"}, 10)
+
+	tests := []struct {
+		name      string
+		start     []byte
+		end       []byte
+		wantErr   error
+		wantCount map[string]int
+	}{
+		{
+			name:      "delete half of one tenant",
+			start:     []byte("tenant-a/key-005"),
+			end:       []byte("tenant-b"),
+			wantCount: map[string]int{"tenant-a": 5, "tenant-b": 10, "tenant-c": 10},
+		},
+		{
+			name:      "delete empty range",
+			start:     []byte("tenant-0"),
+			end:       []byte("tenant-1"),
+			wantCount: map[string]int{"tenant-a": 5, "tenant-b": 10, "tenant-c": 10},
+		},
+		{
+			name:      "delete with start after end",
+			start:     []byte("tenant-c"),
+			end:       []byte("tenant-b"),
+			wantErr:   ErrInvalidRange,
+			wantCount: map[string]int{"tenant-a": 5, "tenant-b": 10, "tenant-c": 10},
+		},
+		{
+			name:      "delete unbounded end",
+			start:     []byte("tenant-c"),
+			end:       nil,
+			wantCount: map[string]int{"tenant-a": 5, "tenant-b": 10, "tenant-c": 0},
+		},
+		{
+			name:      "delete unbounded start",
+			start:     nil,
+			end:       []byte("tenant-b/key-002"),
+			wantCount: map[string]int{"tenant-a": 0, "tenant-b": 8, "tenant-c": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.DeleteRange(tt.start, tt.end); !errors.Is(err, tt.wantErr) {
+				t.Errorf("DeleteRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for prefix, want := range tt.wantCount {
+				if got := countPrefix(db, prefix); got != want {
+					t.Errorf("keys with prefix %s = %d, want %d", prefix, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDB_DeleteRange_Chunks(t *testing.T) {
+	for _, indexType := range []IndexType{Btree, ART, BPlusTree} {
+		t.Run(fmt.Sprint(indexType), func(t *testing.T) {
+			opts := DefaultOptions
+			dir, _ := os.MkdirTemp("", "bitcask-go-delete-range-chunks")
+			opts.DirPath = dir
+			opts.IndexType = indexType
+			db, err := Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				destroyDB(db)
+			}()
+
+			// the range spans several chunks and does not end on a chunk boundary
+			n := 2*deleteRangeChunkSize + 10
+			for i := 0; i < n; i++ {
+				if err = db.Put([]byte(fmt.Sprintf("key-%05d", i)), []byte("value")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = db.DeleteRange([]byte("key-00001"), []byte(fmt.Sprintf("key-%05d", n-1))); err != nil {
+				t.Fatal(err)
+			}
+			if got := countPrefix(db, "key-"); got != 2 {
+				t.Errorf("keys = %d, want 2", got)
+			}
+
+			// the deletion survives reopening, the range tombstone is replayed by the memory indexes
+			if err = db.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if db, err = Open(opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := countPrefix(db, "key-"); got != 2 {
+				t.Errorf("keys after Open() = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestDB_DeletePrefix(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-delete-prefix")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	opts.DataFileSize = 4 * 1024
+
+	tests := []struct {
+		name string
+		pre  func(db *DB) *DB
+	}{
+		{
+			name: "deleted prefix stays deleted after restart",
+			pre: func(db *DB) *DB {
+				if err := db.Close(); err != nil {
+					t.Errorf("Close() error = %v", err)
+				}
+				db, err := Open(opts)
+				if err != nil {
+					t.Errorf("Open() error = %v", err)
+				}
+				return db
+			},
+		},
+		{
+			name: "deleted prefix stays deleted after merge and restart",
+			pre: func(db *DB) *DB {
+				if err := db.Merge(); err != nil {
+					t.Errorf("Merge() error = %v", err)
+				}
+				if err := db.Close(); err != nil {
+					t.Errorf("Close() error = %v", err)
+				}
+				db, err := Open(opts)
+				if err != nil {
+					t.Errorf("Open() error = %v", err)
+				}
+				return db
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(opts)
+			if err != nil {
+				t.Errorf("Open() error = %v", err)
+			}
+			putTenantKeys(t, db, []string{"tenant-a", "tenant-b"}, 50)
+			if err = db.DeletePrefix([]byte("tenant-a/")); err != nil {
+				t.Errorf("DeletePrefix() error = %v", err)
+			}
+			// keys written after the tombstone must survive replay
+			if err = db.Put([]byte("tenant-a/key-new"), []byte("tenant-a")); err != nil {
+				t.Errorf("Put() error = %v", err)
+			}
+
+			db = tt.pre(db)
+			defer destroyDB(db)
+			if got := countPrefix(db, "tenant-a/"); got != 1 {
+				t.Errorf("keys with prefix tenant-a/ = %d, want 1", got)
+			}
+			if got := countPrefix(db, "tenant-b/"); got != 50 {
+				t.Errorf("keys with prefix tenant-b/ = %d, want 50", got)
+			}
+			if _, err = db.Get([]byte("tenant-a/key-new")); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDB_DeletePrefix_EmptyPrefix(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-delete-prefix-empty")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	defer destroyDB(db)
+
+	if err = db.DeletePrefix(nil); !errors.Is(err, ErrKeyIsEmpty) {
+		t.Errorf("DeletePrefix() error = %v, wantErr %v", err, ErrKeyIsEmpty)
+	}
+}
+
+func Test_prefixUpperBound(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix []byte
+		want   []byte
+	}{
+		{name: "simple prefix", prefix: []byte("abc"), want: []byte("abd")},
+		{name: "trailing 0xff", prefix: []byte{'a', 0xff}, want: []byte{'b'}},
+		{name: "only 0xff", prefix: []byte{0xff, 0xff}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prefixUpperBound(tt.prefix); !bytes.Equal(got, tt.want) {
+				t.Errorf("prefixUpperBound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,564 @@
+package resp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	go_kv "go-kv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// command describes a supported command. Exactly one of read and write is set.
+type command struct {
+	minArgs int // minimum number of arguments, including the command name
+	maxArgs int // maximum number of arguments, including the command name, -1 means unlimited
+
+	read  func(tx *txn, args [][]byte) any // reads the database and the writes staged before it by the transaction
+	write func(tx *txn, args [][]byte) any // stages its writes in the transaction of EXEC, or applies them outside one
+}
+
+// arityOK reports whether the command accepts n arguments.
+func (c command) arityOK(n int) bool {
+	return n >= c.minArgs && (c.maxArgs < 0 || n <= c.maxArgs)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":    {minArgs: 1, maxArgs: 2, read: ping},
+		"ECHO":    {minArgs: 2, maxArgs: 2, read: echo},
+		"GET":     {minArgs: 2, maxArgs: 2, read: get},
+		"EXISTS":  {minArgs: 2, maxArgs: -1, read: exists},
+		"KEYS":    {minArgs: 2, maxArgs: 2, read: keys},
+		"SCAN":    {minArgs: 2, maxArgs: 6, read: scan},
+		"TTL":     {minArgs: 2, maxArgs: 2, read: ttl},
+		"PTTL":    {minArgs: 2, maxArgs: 2, read: pttl},
+		"SET":     {minArgs: 3, maxArgs: 6, write: set},
+		"DEL":     {minArgs: 2, maxArgs: -1, write: del},
+		"EXPIRE":  {minArgs: 3, maxArgs: 3, write: expire},
+		"PEXPIRE": {minArgs: 3, maxArgs: 3, write: pexpire},
+	}
+}
+
+var (
+	errSyntax     = errorReply("ERR syntax error")
+	errNotInteger = errorReply("ERR value is not an integer or out of range")
+)
+
+// txn stages the writes of the commands of one EXEC in a WriteBatch.
+// Writes are not visible to reads until the batch commits, so values and deadlines track
+// the writes staged by the transaction so far. A command outside a transaction has no batch,
+// its writes are applied one by one with Put and Delete.
+type txn struct {
+	s         *Server
+	wb        *go_kv.WriteBatch
+	values    map[string][]byte // values staged so far, nil for a deleted key
+	deadlines map[string]int64  // expiration deadlines staged so far, 0 for no deadline
+	now       time.Time
+}
+
+// newTxn creates a transaction staging its writes in wb.
+func (s *Server) newTxn(wb *go_kv.WriteBatch) *txn {
+	return &txn{
+		s:         s,
+		wb:        wb,
+		values:    make(map[string][]byte),
+		deadlines: make(map[string]int64),
+		now:       time.Now(),
+	}
+}
+
+// exec runs commands as one transaction: their writes are committed atomically in one WriteBatch,
+// and reads see the writes of the commands before them.
+func (s *Server) exec(queued [][][]byte) ([]any, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	// a B+Tree database that was not closed cannot create a WriteBatch
+	if err := s.db.CheckWriteBatch(); err != nil {
+		return nil, err
+	}
+
+	// every key written is deleted from the expiration family as well
+	options := go_kv.DefaultWriteBatchOptions
+	var numArgs uint
+	for _, args := range queued {
+		numArgs += uint(len(args))
+	}
+	options.MaxBatchNum = max(options.MaxBatchNum, 2*numArgs)
+
+	tx := s.newTxn(s.db.NewWriteBatch(options))
+	replies := make([]any, len(queued))
+	for i, args := range queued {
+		cmd := commands[strings.ToUpper(string(args[0]))]
+		if cmd.write != nil {
+			replies[i] = cmd.write(tx, args)
+		} else {
+			replies[i] = cmd.read(tx, args)
+		}
+	}
+	if err := tx.wb.Commit(); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+// apply runs a write command outside a transaction, its writes are applied directly.
+func (s *Server) apply(args [][]byte) any {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	cmd := commands[strings.ToUpper(string(args[0]))]
+	return cmd.write(s.newTxn(nil), args)
+}
+
+// has reports whether the key exists and has not expired, taking the staged writes into account.
+func (tx *txn) has(key []byte) (bool, error) {
+	_, found, err := tx.lookup(key)
+	return found, err
+}
+
+// lookup returns the value of the key, taking the staged writes into account.
+// found is false if the key does not exist or has expired.
+func (tx *txn) lookup(key []byte) (value []byte, found bool, err error) {
+	value, staged := tx.values[string(key)]
+	if !staged {
+		value, err = tx.s.db.Get(key)
+		if errors.Is(err, go_kv.ErrKeyNotFound) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	} else if value == nil {
+		return nil, false, nil
+	}
+	expired, err := tx.expired(key)
+	if err != nil || expired {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// deadline returns the expiration deadline of the key in unix milliseconds, 0 if it does not expire,
+// taking the staged writes into account.
+func (tx *txn) deadline(key []byte) (int64, error) {
+	if deadline, staged := tx.deadlines[string(key)]; staged {
+		return deadline, nil
+	}
+	return tx.s.deadline(key)
+}
+
+// expired reports whether the key has an expiration deadline that has passed.
+func (tx *txn) expired(key []byte) (bool, error) {
+	deadline, err := tx.deadline(key)
+	if err != nil {
+		return false, err
+	}
+	return deadline > 0 && deadline <= tx.now.UnixMilli(), nil
+}
+
+// put stages a write of the key, with an expiration deadline if deadline is positive.
+// Without a batch the value and the deadline are two writes,
+// a crash in between may leave the new value with the previous deadline.
+func (tx *txn) put(key, value []byte, deadline int64) error {
+	var err error
+	if tx.wb == nil {
+		err = tx.s.db.Put(key, value)
+	} else {
+		err = tx.wb.Put(key, value)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.setDeadline(key, deadline); err != nil {
+		return err
+	}
+	// copied so that a staged empty value is not nil
+	tx.values[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+// setDeadline stages the expiration deadline of the key in unix milliseconds, 0 removes it.
+func (tx *txn) setDeadline(key []byte, deadline int64) error {
+	var err error
+	switch {
+	case deadline > 0 && tx.wb == nil:
+		err = tx.s.ttl.Put(key, []byte(strconv.FormatInt(deadline, 10)))
+	case deadline > 0:
+		err = tx.wb.PutCF(tx.s.ttl, key, []byte(strconv.FormatInt(deadline, 10)))
+	case tx.wb == nil:
+		err = tx.s.ttl.Delete(key)
+	default:
+		err = tx.wb.DeleteCF(tx.s.ttl, key)
+	}
+	if err != nil {
+		return err
+	}
+	tx.deadlines[string(key)] = max(deadline, 0)
+	return nil
+}
+
+// delete stages a delete of the key and its expiration deadline.
+// Without a batch the key is deleted first, a crash in between only leaves a deadline without a key.
+func (tx *txn) delete(key []byte) error {
+	if tx.wb == nil {
+		if err := tx.s.db.Delete(key); err != nil {
+			return err
+		}
+		if err := tx.s.ttl.Delete(key); err != nil {
+			return err
+		}
+	} else {
+		if err := tx.wb.Delete(key); err != nil {
+			return err
+		}
+		if err := tx.wb.DeleteCF(tx.s.ttl, key); err != nil {
+			return err
+		}
+	}
+	tx.values[string(key)] = nil
+	tx.deadlines[string(key)] = 0
+	return nil
+}
+
+// deadline returns the expiration deadline of the key in unix milliseconds, 0 if it does not expire.
+func (s *Server) deadline(key []byte) (int64, error) {
+	value, err := s.ttl.Get(key)
+	if errors.Is(err, go_kv.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+func ping(_ *txn, args [][]byte) any {
+	if len(args) == 2 {
+		return args[1]
+	}
+	return simpleString("PONG")
+}
+
+func echo(_ *txn, args [][]byte) any {
+	return args[1]
+}
+
+func get(tx *txn, args [][]byte) any {
+	value, found, err := tx.lookup(args[1])
+	if err != nil {
+		return errorReply("ERR " + err.Error())
+	}
+	if !found {
+		return nullReply
+	}
+	return value
+}
+
+func exists(tx *txn, args [][]byte) any {
+	var n int64
+	for _, key := range args[1:] {
+		found, err := tx.has(key)
+		if err != nil {
+			return errorReply("ERR " + err.Error())
+		}
+		if found {
+			n++
+		}
+	}
+	return n
+}
+
+// keys returns all keys matching the pattern, only the keys sharing its literal prefix are visited.
+// Like SCAN, it lists the keys committed to the database, not the ones staged by its transaction.
+func keys(tx *txn, args [][]byte) any {
+	pattern := args[1]
+	result := []any{}
+	for key := range tx.s.db.Keys(go_kv.IteratorOptions{Prefix: literalPrefix(pattern)}) {
+		if !matchPattern(pattern, key) {
+			continue
+		}
+		expired, err := tx.expired(key)
+		if err != nil {
+			return errorReply("ERR " + err.Error())
+		}
+		if !expired {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// scan visits COUNT keys in ascending order after the cursor. The cursor is 0 on the first and the last call,
+// otherwise it is the last key visited encoded in base64, so every key that exists during the whole scan
+// is returned exactly once, whatever is written between two calls.
+func scan(tx *txn, args [][]byte) any {
+	options := go_kv.DefaultIteratorOptions
+	if cursor := string(args[1]); cursor != "0" {
+		lastKey, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return errorReply("ERR invalid cursor")
+		}
+		// the smallest key after the last key visited
+		options.LowerBound = append(lastKey, 0)
+	}
+	var pattern []byte
+	count := 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			var err error
+			if count, err = strconv.Atoi(string(args[i+1])); err != nil || count < 1 {
+				return errSyntax
+			}
+		default:
+			return errSyntax
+		}
+	}
+
+	iterator := tx.s.db.NewIterator(options)
+	defer iterator.Close()
+	result := []any{}
+	var lastKey []byte
+	for visited := 0; iterator.Valid() && visited < count; iterator.Next() {
+		visited++
+		lastKey = iterator.Key()
+		if pattern != nil && !matchPattern(pattern, lastKey) {
+			continue
+		}
+		expired, err := tx.expired(lastKey)
+		if err != nil {
+			return errorReply("ERR " + err.Error())
+		}
+		if !expired {
+			result = append(result, append([]byte(nil), lastKey...))
+		}
+	}
+
+	next := "0"
+	if iterator.Valid() {
+		next = base64.RawURLEncoding.EncodeToString(lastKey)
+	}
+	return []any{[]byte(next), result}
+}
+
+func ttl(tx *txn, args [][]byte) any {
+	return remainingTTL(tx, args[1], time.Second)
+}
+
+func pttl(tx *txn, args [][]byte) any {
+	return remainingTTL(tx, args[1], time.Millisecond)
+}
+
+// remainingTTL returns the time to live of the key in the given unit,
+// -2 if the key does not exist and -1 if it does not expire.
+func remainingTTL(tx *txn, key []byte, unit time.Duration) any {
+	found, err := tx.has(key)
+	if err != nil {
+		return errorReply("ERR " + err.Error())
+	}
+	if !found {
+		return int64(-2)
+	}
+	deadline, err := tx.deadline(key)
+	if err != nil {
+		return errorReply("ERR " + err.Error())
+	}
+	if deadline == 0 {
+		return int64(-1)
+	}
+	remaining := time.Duration(deadline-tx.now.UnixMilli()) * time.Millisecond
+	return int64((remaining + unit/2) / unit)
+}
+
+// set handles SET key value [EX seconds | PX milliseconds] [NX | XX].
+func set(tx *txn, args [][]byte) any {
+	var deadline int64
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if deadline != 0 || i+1 >= len(args) {
+				return errSyntax
+			}
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil || n <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command")
+			}
+			if option == "EX" {
+				n *= 1000
+			}
+			deadline = tx.now.UnixMilli() + n
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx {
+		return errSyntax
+	}
+
+	if nx || xx {
+		found, err := tx.has(args[1])
+		if err != nil {
+			return errorReply("ERR " + err.Error())
+		}
+		if (nx && found) || (xx && !found) {
+			return nullReply
+		}
+	}
+	if err := tx.put(args[1], args[2], deadline); err != nil {
+		return errorReply("ERR " + err.Error())
+	}
+	return okReply
+}
+
+func del(tx *txn, args [][]byte) any {
+	var n int64
+	for _, key := range args[1:] {
+		found, err := tx.has(key)
+		if err != nil {
+			return errorReply("ERR " + err.Error())
+		}
+		if found {
+			n++
+		}
+		// expired keys are removed as well
+		if err = tx.delete(key); err != nil {
+			return errorReply("ERR " + err.Error())
+		}
+	}
+	return n
+}
+
+func expire(tx *txn, args [][]byte) any {
+	return setExpire(tx, args, time.Second)
+}
+
+func pexpire(tx *txn, args [][]byte) any {
+	return setExpire(tx, args, time.Millisecond)
+}
+
+// setExpire sets the time to live of a key in the given unit, a time to live that is not positive deletes the key.
+// It returns 1 if the key exists, 0 otherwise.
+func setExpire(tx *txn, args [][]byte, unit time.Duration) any {
+	n, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	found, err := tx.has(args[1])
+	if err != nil {
+		return errorReply("ERR " + err.Error())
+	}
+	if !found {
+		return int64(0)
+	}
+
+	if n <= 0 {
+		err = tx.delete(args[1])
+	} else {
+		err = tx.setDeadline(args[1], tx.now.Add(time.Duration(n)*unit).UnixMilli())
+	}
+	if err != nil {
+		return errorReply("ERR " + err.Error())
+	}
+	return int64(1)
+}
+
+// literalPrefix returns the part of a glob pattern before its first special character.
+func literalPrefix(pattern []byte) []byte {
+	if i := bytes.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// matchPattern reports whether the key matches the glob pattern.
+// It supports *, ?, character classes like [abc], [^a] and [a-z], and \ to escape a special character.
+func matchPattern(pattern, key []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchPattern(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end := bytes.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// an unterminated class matches literally
+				if key[0] != '[' {
+					return false
+				}
+				pattern, key = pattern[1:], key[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			if !matchClass(class, key[0]) {
+				return false
+			}
+			pattern, key = pattern[end+2:], key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass reports whether c is in the character class, without its brackets.
+func matchClass(class []byte, c byte) bool {
+	negate := len(class) > 0 && class[0] == '^'
+	if negate {
+		class = class[1:]
+	}
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			matched = true
+		}
+	}
+	return matched != negate
+}
@@ -0,0 +1,133 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	maxArgs     = 1024 * 1024       // maximum number of arguments of a command
+	maxBulkSize = 512 * 1024 * 1024 // maximum size of an argument in bytes
+
+	bulkChunkSize = 64 * 1024 // initial buffer size of a large argument
+)
+
+// ErrProtocol is returned when a client sends a request that is not valid RESP.
+var ErrProtocol = errors.New("protocol error")
+
+// simpleString is a RESP simple string reply, e.g. +OK.
+type simpleString string
+
+// errorReply is a RESP error reply, the message starts with the error kind, e.g. ERR.
+type errorReply string
+
+// nullBulk is the RESP null bulk string reply.
+type nullBulk struct{}
+
+var (
+	okReply   = simpleString("OK")
+	nullReply = nullBulk{}
+)
+
+// readCommand reads one command from the client, either a RESP array of bulk strings
+// or an inline command separated by spaces. It returns no arguments for an empty line.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, ErrProtocol
+	}
+	// the count is not trusted, the arguments grow as they arrive
+	var args [][]byte
+	for i := 0; i < n; i++ {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, ErrProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkSize {
+			return nil, ErrProtocol
+		}
+
+		// neither is the size, the buffer grows as the bytes arrive
+		buf := bytes.NewBuffer(make([]byte, 0, min(size+2, bulkChunkSize)))
+		if _, err = io.CopyN(buf, r, int64(size+2)); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		arg := buf.Bytes()
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, ErrProtocol
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// readLine reads a line terminated by \r\n, or by \n for inline commands, without the terminator.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, ErrProtocol
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+	return append([]byte(nil), line...), nil
+}
+
+// writeReply encodes a reply to the client.
+// A []byte is a bulk string, an int64 an integer and a []any an array of replies.
+func writeReply(w *bufio.Writer, reply any) {
+	switch v := reply.(type) {
+	case simpleString:
+		w.WriteByte('+')
+		w.WriteString(string(v))
+		w.WriteString("\r\n")
+	case errorReply:
+		w.WriteByte('-')
+		w.WriteString(string(v))
+		w.WriteString("\r\n")
+	case int64:
+		w.WriteByte(':')
+		w.WriteString(strconv.FormatInt(v, 10))
+		w.WriteString("\r\n")
+	case []byte:
+		w.WriteByte('$')
+		w.WriteString(strconv.Itoa(len(v)))
+		w.WriteString("\r\n")
+		w.Write(v)
+		w.WriteString("\r\n")
+	case nullBulk:
+		w.WriteString("$-1\r\n")
+	case []any:
+		w.WriteByte('*')
+		w.WriteString(strconv.Itoa(len(v)))
+		w.WriteString("\r\n")
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	default:
+		writeReply(w, errorReply(fmt.Sprintf("ERR unsupported reply type %T", reply)))
+	}
+}
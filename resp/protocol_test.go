@@ -0,0 +1,48 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]byte
+		wantErr error
+	}{
+		{name: "array", input: "*2\r\n$3\r\nGET\r\n$0\r\n\r\n", want: [][]byte{[]byte("GET"), {}}},
+		{name: "inline", input: "GET key\r\n", want: [][]byte{[]byte("GET"), []byte("key")}},
+		{name: "large count without arguments", input: "*1048576\r\n$3\r\nGET\r\n", wantErr: io.EOF},
+		{name: "large size without bytes", input: "*1\r\n$536870912\r\nGET\r\n", wantErr: io.ErrUnexpectedEOF},
+		{name: "count too large", input: "*1048577\r\n", wantErr: ErrProtocol},
+		{name: "missing terminator", input: "*1\r\n$3\r\nGETxx", wantErr: ErrProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCommand(bufio.NewReader(strings.NewReader(tt.input)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteReply_Unsupported(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeReply(w, []any{int64(1), 1.5})
+	_ = w.Flush()
+	if want := "*2\r\n:1\r\n-ERR unsupported reply type float64\r\n"; buf.String() != want {
+		t.Errorf("writeReply() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,237 @@
+// Package resp serves a go-kv database over the Redis serialization protocol (RESP2),
+// so existing Redis clients can use it as a persistent key-value store.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	go_kv "go-kv"
+	"go-kv/internal/netserver"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ttlFamily is the column family holding the expiration deadlines of keys.
+	ttlFamily = "resp-ttl"
+	// expireInterval is the interval between two scans for expired keys.
+	// Expired keys are never returned, the scan only reclaims their space.
+	expireInterval = time.Second
+)
+
+// ErrServerClosed is returned by Serve after Close is called.
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Server serves RESP clients on top of a DB. The DB is owned by the caller and is not closed by the server.
+type Server struct {
+	db  *go_kv.DB
+	ttl *go_kv.ColumnFamily // expiration deadlines in unix milliseconds
+
+	writeMu sync.Mutex // serializes the read-check-write sequences of the write commands
+
+	conns *netserver.Server // accepts the connections and tracks them until Close
+
+	closeOnce  sync.Once
+	expireStop chan struct{} // closed to stop the background expiration goroutine
+	expireDone chan struct{} // closed by the background expiration goroutine when it exits
+}
+
+// client is the state of one connection.
+type client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	inMulti bool       // set between MULTI and EXEC or DISCARD
+	queued  [][][]byte // commands queued since MULTI
+	aborted bool       // a command could not be queued, EXEC discards the transaction
+}
+
+// NewServer creates a server for the database.
+func NewServer(db *go_kv.DB) (*Server, error) {
+	ttl, err := db.ColumnFamily(ttlFamily)
+	if errors.Is(err, go_kv.ErrColumnFamilyNotFound) {
+		ttl, err = db.CreateColumnFamily(ttlFamily)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		db:         db,
+		ttl:        ttl,
+		conns:      netserver.New(ErrServerClosed),
+		expireStop: make(chan struct{}),
+		expireDone: make(chan struct{}),
+	}
+	go s.expirePeriodically()
+	return s, nil
+}
+
+// ListenAndServe listens on the TCP address and serves clients until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	return s.conns.ListenAndServe(addr, s.serveConn)
+}
+
+// Serve accepts connections on the listener and serves each of them in its own goroutine
+// until Close is called, then it returns ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	return s.conns.Serve(listener, s.serveConn)
+}
+
+// Close stops accepting connections, closes all open connections and waits for their goroutines to exit.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.expireStop)
+		<-s.expireDone
+	})
+	return s.conns.Close()
+}
+
+// serveConn reads commands from the connection and writes their replies until the client quits.
+// Replies of pipelined commands are flushed together.
+func (s *Server) serveConn(conn net.Conn) {
+	c := &client{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+	for {
+		args, err := readCommand(c.r)
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				writeReply(c.w, errorReply("ERR Protocol error"))
+				_ = c.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := strings.EqualFold(string(args[0]), "QUIT")
+		if quit {
+			writeReply(c.w, okReply)
+		} else {
+			writeReply(c.w, s.handle(c, args))
+		}
+		if quit || c.r.Buffered() == 0 {
+			if err = c.w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// handle runs a command or queues it inside a transaction.
+func (s *Server) handle(c *client, args [][]byte) any {
+	name := strings.ToUpper(string(args[0]))
+	switch name {
+	case "MULTI":
+		if c.inMulti {
+			return errorReply("ERR MULTI calls can not be nested")
+		}
+		c.inMulti, c.queued, c.aborted = true, nil, false
+		return okReply
+	case "EXEC":
+		if !c.inMulti {
+			return errorReply("ERR EXEC without MULTI")
+		}
+		queued, aborted := c.queued, c.aborted
+		c.inMulti, c.queued, c.aborted = false, nil, false
+		if aborted {
+			return errorReply("EXECABORT Transaction discarded because of previous errors.")
+		}
+		replies, err := s.exec(queued)
+		if err != nil {
+			return errorReply("ERR " + err.Error())
+		}
+		return replies
+	case "DISCARD":
+		if !c.inMulti {
+			return errorReply("ERR DISCARD without MULTI")
+		}
+		c.inMulti, c.queued, c.aborted = false, nil, false
+		return okReply
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		if c.inMulti {
+			c.aborted = true
+		}
+		return errorReply("ERR unknown command '" + string(args[0]) + "'")
+	}
+	if !cmd.arityOK(len(args)) {
+		if c.inMulti {
+			c.aborted = true
+		}
+		return errorReply("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+	}
+
+	if c.inMulti {
+		c.queued = append(c.queued, args)
+		return simpleString("QUEUED")
+	}
+	if cmd.write != nil {
+		return s.apply(args)
+	}
+	return cmd.read(s.newTxn(nil), args)
+}
+
+// expirePeriodically removes expired keys every expireInterval until the server is closed.
+func (s *Server) expirePeriodically() {
+	defer close(s.expireDone)
+
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.removeExpired(time.Now())
+		case <-s.expireStop:
+			return
+		}
+	}
+}
+
+// removeExpired deletes all keys whose expiration deadline has passed, together with their deadlines.
+func (s *Server) removeExpired(now time.Time) error {
+	var expiredKeys [][]byte
+	iterator := s.ttl.NewIterator(go_kv.DefaultIteratorOptions)
+	for ; iterator.Valid(); iterator.Next() {
+		value, err := iterator.Value()
+		if err != nil {
+			iterator.Close()
+			return err
+		}
+		deadline, err := strconv.ParseInt(string(value), 10, 64)
+		if err == nil && deadline <= now.UnixMilli() {
+			expiredKeys = append(expiredKeys, append([]byte(nil), iterator.Key()...))
+		}
+	}
+	iterator.Close()
+	if len(expiredKeys) == 0 {
+		return nil
+	}
+
+	// the deadline may have changed since the scan, each key is deleted on its own
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx := s.newTxn(nil)
+	tx.now = now
+	for _, key := range expiredKeys {
+		expired, err := tx.expired(key)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			continue
+		}
+		if err = tx.delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
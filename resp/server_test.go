@@ -0,0 +1,356 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	go_kv "go-kv"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testClient is a minimal RESP client for the tests.
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// startTestServer starts a server on a local listener and returns a client connected to it.
+func startTestServer(t *testing.T) (*Server, *testClient) {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-resp")
+	opts.DirPath = dir
+	opts.IndexType = go_kv.Btree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return serveTestDB(t, db)
+}
+
+// openUnclosedBPlusTree returns a B+Tree database opened from a copy of a directory
+// whose database was never closed, so it has no sequence number file.
+func openUnclosedBPlusTree(t *testing.T) *go_kv.DB {
+	opts := go_kv.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-resp-unclosed")
+	opts.DirPath = dir
+	opts.IndexType = go_kv.BPlusTree
+	db, err := go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	copyDir, _ := os.MkdirTemp("", "bitcask-go-resp-unclosed-copy")
+	err = os.CopyFS(copyDir, os.DirFS(dir))
+	_ = db.Close()
+	_ = os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.DirPath = copyDir
+	db, err = go_kv.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(copyDir)
+	})
+	if err = db.CheckWriteBatch(); !errors.Is(err, go_kv.ErrSeqNoFileNotFound) {
+		t.Fatalf("CheckWriteBatch() error = %v, wantErr %v", err, go_kv.ErrSeqNoFileNotFound)
+	}
+	return db
+}
+
+// serveTestDB starts a server for the database on a local listener and returns a client connected to it.
+func serveTestDB(t *testing.T, db *go_kv.DB) (*Server, *testClient) {
+	server, err := NewServer(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = server.Close()
+		if err := <-served; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve() error = %v, wantErr %v", err, ErrServerClosed)
+		}
+	})
+	return server, &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// do sends a command and returns its reply, see readTestReply.
+func (c *testClient) do(t *testing.T, args ...string) any {
+	request := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		request += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(request)); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := readTestReply(c.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+// readTestReply reads one reply: simple strings as "+OK", errors as "-ERR ...",
+// integers as int64, bulk strings as string, the null bulk string as nil and arrays as []any.
+func readTestReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+', '-':
+		return line, nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, _ := strconv.Atoi(line[1:])
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readTestReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
+
+func TestServer_Commands(t *testing.T) {
+	_, client := startTestServer(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want any
+	}{
+		{name: "ping", args: []string{"PING"}, want: "+PONG"},
+		{name: "set", args: []string{"SET", "user:1", "alice"}, want: "+OK"},
+		{name: "get", args: []string{"get", "user:1"}, want: "alice"},
+		{name: "get missing", args: []string{"GET", "user:9"}, want: nil},
+		{name: "set nx existing", args: []string{"SET", "user:1", "bob", "NX"}, want: nil},
+		{name: "set xx missing", args: []string{"SET", "user:2", "bob", "XX"}, want: nil},
+		{name: "set nx missing", args: []string{"SET", "user:2", "bob", "NX"}, want: "+OK"},
+		{name: "set syntax error", args: []string{"SET", "user:3", "carol", "XY"}, want: "-ERR syntax error"},
+		{name: "set more", args: []string{"SET", "order:1", "book"}, want: "+OK"},
+		{name: "exists", args: []string{"EXISTS", "user:1", "user:2", "user:3"}, want: int64(2)},
+		{name: "keys", args: []string{"KEYS", "user:*"}, want: []any{"user:1", "user:2"}},
+		{name: "keys class", args: []string{"KEYS", "[ou]*:[12]"}, want: []any{"order:1", "user:1", "user:2"}},
+		{name: "scan first page", args: []string{"SCAN", "0", "COUNT", "2"}, want: []any{"dXNlcjox", []any{"order:1", "user:1"}}},
+		{name: "set before the cursor", args: []string{"SET", "a", "1"}, want: "+OK"},
+		{name: "scan last page", args: []string{"SCAN", "dXNlcjox", "COUNT", "2"}, want: []any{"0", []any{"user:2"}}},
+		{name: "scan invalid cursor", args: []string{"SCAN", "!"}, want: "-ERR invalid cursor"},
+		{name: "del before the cursor", args: []string{"DEL", "a"}, want: int64(1)},
+		{name: "scan match", args: []string{"SCAN", "0", "MATCH", "user:*"}, want: []any{"0", []any{"user:1", "user:2"}}},
+		{name: "ttl without expire", args: []string{"TTL", "user:1"}, want: int64(-1)},
+		{name: "ttl missing", args: []string{"TTL", "user:9"}, want: int64(-2)},
+		{name: "expire", args: []string{"EXPIRE", "user:1", "100"}, want: int64(1)},
+		{name: "ttl", args: []string{"TTL", "user:1"}, want: int64(100)},
+		{name: "set clears ttl", args: []string{"SET", "user:1", "alice"}, want: "+OK"},
+		{name: "ttl cleared", args: []string{"TTL", "user:1"}, want: int64(-1)},
+		{name: "del", args: []string{"DEL", "user:1", "user:9"}, want: int64(1)},
+		{name: "unknown command", args: []string{"FLUSHALL"}, want: "-ERR unknown command 'FLUSHALL'"},
+		{name: "wrong arity", args: []string{"GET"}, want: "-ERR wrong number of arguments for 'get' command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := client.do(t, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%v got = %#v, want %#v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_MultiExec(t *testing.T) {
+	_, client := startTestServer(t)
+	client.do(t, "SET", "a", "1")
+
+	tests := []struct {
+		name string
+		args [][]string
+		want []any
+	}{
+		{
+			name: "exec",
+			args: [][]string{{"MULTI"}, {"SET", "b", "2"}, {"DEL", "a", "b"}, {"SET", "c", "3", "NX"}, {"EXEC"}, {"KEYS", "*"}},
+			want: []any{"+OK", "+QUEUED", "+QUEUED", "+QUEUED", []any{"+OK", int64(2), "+OK"}, []any{"c"}},
+		},
+		{
+			name: "discard",
+			args: [][]string{{"MULTI"}, {"SET", "d", "4"}, {"DISCARD"}, {"EXISTS", "d"}},
+			want: []any{"+OK", "+QUEUED", "+OK", int64(0)},
+		},
+		{
+			name: "reads see earlier writes",
+			args: [][]string{
+				{"MULTI"}, {"GET", "c"}, {"SET", "f", "6", "EX", "100"}, {"GET", "f"}, {"TTL", "f"},
+				{"DEL", "f"}, {"EXISTS", "f"}, {"GET", "f"}, {"EXEC"},
+			},
+			want: []any{
+				"+OK", "+QUEUED", "+QUEUED", "+QUEUED", "+QUEUED", "+QUEUED", "+QUEUED", "+QUEUED",
+				[]any{"3", "+OK", "6", int64(100), int64(1), int64(0), nil},
+			},
+		},
+		{
+			name: "queueing error aborts",
+			args: [][]string{{"MULTI"}, {"SET", "e", "5"}, {"GET"}, {"EXEC"}, {"EXISTS", "e"}},
+			want: []any{
+				"+OK", "+QUEUED", "-ERR wrong number of arguments for 'get' command",
+				"-EXECABORT Transaction discarded because of previous errors.", int64(0),
+			},
+		},
+		{
+			name: "exec without multi",
+			args: [][]string{{"EXEC"}},
+			want: []any{"-ERR EXEC without MULTI"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []any
+			for _, args := range tt.args {
+				got = append(got, client.do(t, args...))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replies = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_UnclosedBPlusTree(t *testing.T) {
+	_, client := serveTestDB(t, openUnclosedBPlusTree(t))
+
+	// commands outside a transaction do not need a WriteBatch
+	args := [][]string{
+		{"GET", "a"}, {"SET", "b", "2", "EX", "100"}, {"TTL", "b"}, {"DEL", "a"}, {"PEXPIRE", "b", "0"},
+		{"MULTI"}, {"SET", "c", "3"}, {"EXEC"}, {"KEYS", "*"},
+	}
+	want := []any{
+		"1", "+OK", int64(100), int64(1), int64(1),
+		"+OK", "+QUEUED", "-ERR " + go_kv.ErrSeqNoFileNotFound.Error(), []any{},
+	}
+	var got []any
+	for _, args := range args {
+		got = append(got, client.do(t, args...))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replies = %#v, want %#v", got, want)
+	}
+}
+
+func TestServer_Expire(t *testing.T) {
+	server, client := startTestServer(t)
+
+	client.do(t, "SET", "session", "token", "PX", "50")
+	client.do(t, "SET", "kept", "value")
+	client.do(t, "PEXPIRE", "kept", "100000")
+	if got := client.do(t, "GET", "session"); got != "token" {
+		t.Errorf("GET got = %v, want token", got)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := client.do(t, "GET", "session"); got != nil {
+		t.Errorf("GET got = %v, want nil", got)
+	}
+	if got := client.do(t, "EXISTS", "session"); got != int64(0) {
+		t.Errorf("EXISTS got = %v, want 0", got)
+	}
+
+	// the expired key is reclaimed, the other one is kept with its deadline
+	if err := server.removeExpired(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.db.Get([]byte("session")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+	if _, err := server.ttl.Get([]byte("session")); !errors.Is(err, go_kv.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, wantErr %v", err, go_kv.ErrKeyNotFound)
+	}
+	if got := client.do(t, "TTL", "kept"); got != int64(100) {
+		t.Errorf("TTL got = %v, want 100", got)
+	}
+}
+
+func TestServer_InlineAndPipeline(t *testing.T) {
+	_, client := startTestServer(t)
+
+	// two inline commands sent at once are answered in order
+	if _, err := client.conn.Write([]byte("SET greeting hello\r\nGET greeting\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []any{"+OK", "hello"} {
+		got, err := readTestReply(client.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("reply = %v, want %v", got, want)
+		}
+	}
+}
+
+func Test_matchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{pattern: "*", key: "", want: true},
+		{pattern: "user:*", key: "user:1", want: true},
+		{pattern: "user:*", key: "order:1", want: false},
+		{pattern: "h?llo", key: "hello", want: true},
+		{pattern: "h?llo", key: "hllo", want: false},
+		{pattern: "h[ae]llo", key: "hallo", want: true},
+		{pattern: "h[^e]llo", key: "hello", want: false},
+		{pattern: "h[a-c]llo", key: "hbllo", want: true},
+		{pattern: `h\*llo`, key: "h*llo", want: true},
+		{pattern: `h\*llo`, key: "hello", want: false},
+		{pattern: "a*b*c", key: "axxbyyc", want: true},
+		{pattern: "a*b*c", key: "axxbyy", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.key, func(t *testing.T) {
+			if got := matchPattern([]byte(tt.pattern), []byte(tt.key)); got != tt.want {
+				t.Errorf("matchPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
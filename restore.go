@@ -0,0 +1,462 @@
+package go_kv
+
+import (
+	"fmt"
+	"go-kv/data"
+	"go-kv/index"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Restore rebuilds a data directory in destDir from a chain of backups, the manifest of a full backup
+// followed by the manifests of its incremental backups in the order they were taken, as read by ReadBackupManifest.
+// The database is restored to the point in time of the last manifest. Every data file is taken from the
+// latest backup that shipped it, so data files rewritten by a Merge replace the ones of earlier backups.
+// destDir must not exist or be empty. It returns ErrBackupChainBroken if the manifests do not form a chain
+// or a data file of the last manifest is missing.
+func Restore(manifests []*BackupManifest, destDir string) error {
+	if err := checkBackupChain(manifests); err != nil {
+		return err
+	}
+	if err := checkBackupDir(destDir); err != nil {
+		return err
+	}
+	last := manifests[len(manifests)-1]
+
+	for i, file := range last.Files {
+		srcDir, ok := shippedIn(manifests, file)
+		if !ok {
+			return fmt.Errorf("%w: data file %d is not in any backup", ErrBackupChainBroken, file.FileId)
+		}
+		srcPath := data.GetDataFileName(srcDir, file.FileId)
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return err
+		}
+		if info.Size() != file.Size {
+			return fmt.Errorf("%w: data file %d has %d bytes, want %d", ErrBackupChainBroken, file.FileId, info.Size(), file.Size)
+		}
+
+		// the restored database appends to its last data file
+		destPath := data.GetDataFileName(destDir, file.FileId)
+		if i == len(last.Files)-1 {
+			err = copyFile(srcPath, destPath, -1)
+		} else {
+			err = linkOrCopyFile(srcPath, destPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// hint file and merge finished file are shipped by the first backup after a merge
+	if last.MergeFileId != 0 {
+		for i := len(manifests) - 1; i >= 0 && manifests[i].MergeFileId == last.MergeFileId; i-- {
+			if _, err := os.Stat(filepath.Join(manifests[i].dir, data.MergeFinishedFileName)); err != nil {
+				continue
+			}
+			for _, name := range []string{data.HintFileName, data.MergeFinishedFileName} {
+				if err := copyFile(filepath.Join(manifests[i].dir, name), filepath.Join(destDir, name), -1); err != nil {
+					return err
+				}
+			}
+			break
+		}
+	}
+
+	// the sequence number, column family catalog and B+Tree indexes of the last backup
+	if err := copyBackupState(last.dir, destDir); err != nil {
+		return err
+	}
+	return syncDir(destDir)
+}
+
+// checkBackupChain checks that the manifests start with a full backup and each one follows the previous one.
+func checkBackupChain(manifests []*BackupManifest) error {
+	if len(manifests) == 0 {
+		return fmt.Errorf("%w: no backups", ErrBackupChainBroken)
+	}
+	if manifests[0].Parent != "" {
+		return fmt.Errorf("%w: backup %s is not a full backup", ErrBackupChainBroken, manifests[0].ID)
+	}
+	for i := 1; i < len(manifests); i++ {
+		if manifests[i].Parent != manifests[i-1].ID || manifests[i].IndexType != manifests[0].IndexType {
+			return fmt.Errorf("%w: backup %s does not follow backup %s", ErrBackupChainBroken, manifests[i].ID, manifests[i-1].ID)
+		}
+	}
+	return nil
+}
+
+// shippedIn returns the directory of the latest backup of the chain that shipped the data file.
+func shippedIn(manifests []*BackupManifest, file BackupFile) (string, bool) {
+	for i := len(manifests) - 1; i >= 0; i-- {
+		for _, shipped := range manifests[i].Files {
+			if shipped.FileId == file.FileId && shipped.Shipped && shipped.Size == file.Size {
+				return manifests[i].dir, true
+			}
+		}
+	}
+	return "", false
+}
+
+// copyBackupState copies the files of a backup that are not data files, merge files or the manifest,
+// and the B+Tree index directories of the column families.
+func copyBackupState(srcDir, destDir string) error {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case name == backupManifestFileName, name == data.HintFileName, name == data.MergeFinishedFileName,
+			strings.HasSuffix(name, data.DataFileNameSuffix):
+			continue
+		case entry.IsDir():
+			if err = os.MkdirAll(filepath.Join(destDir, name), os.ModePerm); err != nil {
+				return err
+			}
+			if err = copyBackupState(filepath.Join(srcDir, name), filepath.Join(destDir, name)); err != nil {
+				return err
+			}
+		default:
+			if err = copyFile(filepath.Join(srcDir, name), filepath.Join(destDir, name), -1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RestorePoint selects the writes a point-in-time restore keeps, the zero RestorePoint keeps all of them.
+// If both fields are set the restore stops at whichever point comes first.
+type RestorePoint struct {
+	Time  time.Time // keep the writes made at or before Time
+	SeqNo uint64    // keep the writes up to the commit of the WriteBatch with this sequence number
+}
+
+// restoreCut is the position of the first record a point-in-time restore drops.
+type restoreCut struct {
+	fileId    uint32
+	offset    int64
+	timestamp int64 // timestamp of the last record kept
+	ok        bool  // false if every record is kept
+}
+
+// restoreBound is the end of what a point-in-time restore of an open database reads, taken under db.mut,
+// so records appended while the restore runs are never read half written.
+type restoreBound struct {
+	fileId     uint32 // active data file, later data files are not read
+	offset     int64  // bytes of the active data file written
+	familySize int64  // bytes of the column family catalog written
+}
+
+// RestoreToPoint writes the database as it was at the restore point to the new data directory options.DirPath,
+// see the RestoreToPoint function. Only the records written before it was called are read.
+func (db *DB) RestoreToPoint(point RestorePoint, options Options) error {
+	db.mut.RLock()
+	bound := &restoreBound{}
+	if db.activeFile != nil {
+		bound.fileId, bound.offset = db.activeFile.FileId, db.activeFile.WriteOff
+	}
+	if db.familyFile != nil {
+		bound.familySize = db.familyFile.WriteOff
+	}
+	db.mut.RUnlock()
+	return restoreToPoint(db.options.DirPath, point, options, bound)
+}
+
+// RestoreToPoint writes the database in the data directory srcDir as it was at the restore point to
+// the new data directory options.DirPath, which must not exist or be empty, and builds the index of options.IndexType.
+// srcDir is a data directory, a full backup or the result of Restore, no database may be writing to it,
+// use the RestoreToPoint method of an open database instead.
+// The data files are replayed in the order they were written up to the first record after the point,
+// a WriteBatch committed after the point is dropped as a whole. Records written before records carried
+// timestamps are kept by a Time restore point.
+// Merge keeps only the latest record of every key, so a Time point must not be before the last merge of srcDir
+// finished, otherwise ErrRestorePointMerged is returned. The WriteBatch of SeqNo is only looked up in the data files
+// the last merge did not rewrite, it returns ErrRestorePointNotFound if it is not there.
+func RestoreToPoint(srcDir string, point RestorePoint, options Options) error {
+	return restoreToPoint(srcDir, point, options, nil)
+}
+
+// restoreToPoint does a point-in-time restore of srcDir, reading nothing past bound if it is not nil.
+func restoreToPoint(srcDir string, point RestorePoint, options Options, bound *restoreBound) error {
+	if err := checkOptions(options); err != nil {
+		return err
+	}
+	fileIds, err := dataFileIds(srcDir)
+	if err != nil {
+		return err
+	}
+	if bound != nil {
+		// data files created after the bound was taken
+		for len(fileIds) > 0 && fileIds[len(fileIds)-1] > bound.fileId {
+			fileIds = fileIds[:len(fileIds)-1]
+		}
+	}
+
+	// data files before the merge file id were rewritten by the last merge
+	var merged bool
+	var mergeFileId uint32
+	var mergeTime int64
+	if _, err = os.Stat(filepath.Join(srcDir, data.MergeFinishedFileName)); err == nil {
+		mergeFinishedFile, err := data.OpenMergeFinishedFile(srcDir)
+		if err != nil {
+			return err
+		}
+		record, _, err := mergeFinishedFile.ReadLogRecord(0)
+		_ = mergeFinishedFile.Close()
+		if err != nil {
+			return err
+		}
+		fileId, err := strconv.ParseUint(string(record.Value), 10, 32)
+		if err != nil {
+			return ErrDataDirectoryCorrupted
+		}
+		merged, mergeFileId, mergeTime = true, uint32(fileId), record.Timestamp
+	}
+
+	limit := int64(math.MaxInt64)
+	if !point.Time.IsZero() {
+		limit = point.Time.UnixMilli()
+	}
+	// a merge without a timestamp finished at an unknown time
+	if merged && !point.Time.IsZero() && (mergeTime == 0 || limit < mergeTime) {
+		return ErrRestorePointMerged
+	}
+
+	var replayIds []uint32
+	for _, fileId := range fileIds {
+		if !merged || fileId >= mergeFileId {
+			replayIds = append(replayIds, fileId)
+		}
+	}
+	cut, err := findRestoreCut(srcDir, replayIds, limit, point.SeqNo, bound)
+	if err != nil {
+		return err
+	}
+	// the commit of a WriteBatch found in the replayed data files was written after the merge began,
+	// a commit without a timestamp does not move the limit of the column family catalog
+	if point.SeqNo != 0 {
+		switch {
+		case !cut.ok:
+			return fmt.Errorf("%w: transaction %d", ErrRestorePointNotFound, point.SeqNo)
+		case cut.timestamp != 0 && cut.timestamp < limit:
+			limit = cut.timestamp
+		}
+	}
+
+	if err = checkBackupDir(options.DirPath); err != nil {
+		return err
+	}
+	if err = restoreDataFiles(srcDir, options.DirPath, fileIds, cut, bound); err != nil {
+		return err
+	}
+	if merged {
+		for _, name := range []string{data.HintFileName, data.MergeFinishedFileName} {
+			srcPath := filepath.Join(srcDir, name)
+			if _, err = os.Stat(srcPath); os.IsNotExist(err) {
+				continue
+			}
+			if err = copyFile(srcPath, filepath.Join(options.DirPath, name), -1); err != nil {
+				return err
+			}
+		}
+	}
+	if err = restoreColumnFamilies(srcDir, options.DirPath, limit, bound); err != nil {
+		return err
+	}
+
+	// open the restored directory to rebuild its index, closing it writes the sequence number file
+	openOptions := options
+	openOptions.IndexType = Btree
+	db, err := Open(openOptions)
+	if err != nil {
+		return err
+	}
+	if options.IndexType == BPlusTree {
+		if err = db.writeBPlusTreeIndexes(); err != nil {
+			_ = db.Close()
+			return err
+		}
+	}
+	if err = db.Close(); err != nil {
+		return err
+	}
+	return syncDir(options.DirPath)
+}
+
+// findRestoreCut reads the data files in order and returns the position of the first record
+// written after limit, or the position after the commit of the WriteBatch seqNo if it comes first.
+func findRestoreCut(dirPath string, fileIds []uint32, limit int64, seqNo uint64, bound *restoreBound) (*restoreCut, error) {
+	cut := &restoreCut{}
+	for _, fileId := range fileIds {
+		dataFile, err := data.OpenDataFile(dirPath, fileId)
+		if err != nil {
+			return nil, err
+		}
+		var offset int64
+		for !cut.ok {
+			if bound != nil && fileId == bound.fileId && offset >= bound.offset {
+				break
+			}
+			record, size, err := dataFile.ReadLogRecord(offset)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				_ = dataFile.Close()
+				return nil, err
+			}
+
+			if record.Timestamp > limit {
+				cut.fileId, cut.offset, cut.ok = fileId, offset, true
+				break
+			}
+			cut.timestamp = record.Timestamp
+			if seqNo != nonTransactionalSeqNo && record.Type == data.LogRecordTxFinished {
+				if recordSeqNo, _ := parseLogRecordKey(record.Key); recordSeqNo == seqNo {
+					cut.fileId, cut.offset, cut.ok = fileId, offset+size, true
+				}
+			}
+			offset += size
+		}
+		if err = dataFile.Close(); err != nil {
+			return nil, err
+		}
+		if cut.ok {
+			return cut, nil
+		}
+	}
+	return cut, nil
+}
+
+// restoreDataFiles links or copies the data files before the cut to destDir and copies the part
+// of the cut file before it. The last data file is always copied, the restored database appends to it.
+// The active data file of the bound is copied up to the bound.
+func restoreDataFiles(srcDir, destDir string, fileIds []uint32, cut *restoreCut, bound *restoreBound) error {
+	type restoreFile struct {
+		fileId uint32
+		size   int64 // bytes to copy, negative for the whole file
+	}
+	var files []restoreFile
+	for _, fileId := range fileIds {
+		if cut.ok && fileId == cut.fileId && cut.offset > 0 {
+			files = append(files, restoreFile{fileId: fileId, size: cut.offset})
+		}
+		if cut.ok && fileId >= cut.fileId {
+			break
+		}
+		size := int64(-1)
+		if bound != nil && fileId == bound.fileId {
+			size = bound.offset
+		}
+		files = append(files, restoreFile{fileId: fileId, size: size})
+	}
+
+	for i, file := range files {
+		srcPath := data.GetDataFileName(srcDir, file.fileId)
+		destPath := data.GetDataFileName(destDir, file.fileId)
+		var err error
+		if i == len(files)-1 || file.size >= 0 {
+			err = copyFile(srcPath, destPath, file.size)
+		} else {
+			err = linkOrCopyFile(srcPath, destPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreColumnFamilies copies the records of the column family catalog written at or before limit
+// and before the bound.
+func restoreColumnFamilies(srcDir, destDir string, limit int64, bound *restoreBound) error {
+	if _, err := os.Stat(filepath.Join(srcDir, data.ColumnFamilyFileName)); os.IsNotExist(err) {
+		return nil
+	}
+	familyFile, err := data.OpenColumnFamilyFile(srcDir)
+	if err != nil {
+		return err
+	}
+	defer familyFile.Close()
+
+	var offset int64
+	for bound == nil || offset < bound.familySize {
+		record, size, err := familyFile.ReadLogRecord(offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if record.Timestamp > limit {
+			break
+		}
+		offset += size
+	}
+	if offset == 0 {
+		return nil
+	}
+	srcPath := filepath.Join(srcDir, data.ColumnFamilyFileName)
+	return copyFile(srcPath, filepath.Join(destDir, data.ColumnFamilyFileName), offset)
+}
+
+// dataFileIds returns the ids of the data files in a directory in ascending order.
+func dataFileIds(dirPath string) ([]uint32, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	var fileIds []uint32
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), data.DataFileNameSuffix)
+		if !ok {
+			continue
+		}
+		fileId, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			return nil, ErrDataDirectoryCorrupted
+		}
+		fileIds = append(fileIds, uint32(fileId))
+	}
+	sort.Slice(fileIds, func(i, j int) bool {
+		return fileIds[i] < fileIds[j]
+	})
+	return fileIds, nil
+}
+
+// writeBPlusTreeIndexes writes the memory indexes of the database and its column families to
+// B+Tree index files, so the data directory can be opened with the BPlusTree index type.
+func (db *DB) writeBPlusTreeIndexes() error {
+	indexes := map[string]index.Indexer{db.options.DirPath: db.index}
+	for id, cf := range db.families {
+		dirPath := db.familyDirPath(id)
+		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+			return err
+		}
+		indexes[dirPath] = cf.index
+	}
+	for dirPath, indexer := range indexes {
+		bpt := index.NewBPlusTree(dirPath, false)
+		it := indexer.Iterator(false)
+		err := bpt.Load(it)
+		it.Close()
+		if err != nil {
+			_ = bpt.Close()
+			return err
+		}
+		if err = bpt.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
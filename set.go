@@ -0,0 +1,181 @@
+package go_kv
+
+import "bytes"
+
+// SAdd adds the members to the set stored at key, creating the set if it does not exist.
+// The members and the set metadata are written atomically in one WriteBatch.
+// It returns the number of members that were not in the set before.
+func (db *DB) SAdd(key []byte, members ...[]byte) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return 0, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureSet)
+	if err != nil {
+		return 0, err
+	}
+
+	wb := db.structureWriteBatch(len(members))
+	added := make(map[string]struct{})
+	for _, member := range members {
+		subKey := structureSubKey(key, meta.version, member)
+		if _, ok := added[string(subKey)]; ok || dataCF.index.Get(subKey) != nil {
+			continue
+		}
+		added[string(subKey)] = struct{}{}
+		if err = wb.PutCF(dataCF, subKey, nil); err != nil {
+			return 0, err
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	meta.size += uint32(len(added))
+	if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+		return 0, err
+	}
+	if err = wb.commit(); err != nil {
+		return 0, err
+	}
+	return len(added), nil
+}
+
+// SRem removes the members from the set stored at key.
+// It returns the number of members that were removed.
+func (db *DB) SRem(key []byte, members ...[]byte) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return 0, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureSet)
+	if err != nil || meta.size == 0 {
+		return 0, err
+	}
+
+	wb := db.structureWriteBatch(len(members))
+	removed := make(map[string]struct{})
+	for _, member := range members {
+		subKey := structureSubKey(key, meta.version, member)
+		if _, ok := removed[string(subKey)]; ok || dataCF.index.Get(subKey) == nil {
+			continue
+		}
+		removed[string(subKey)] = struct{}{}
+		if err = wb.delete(dataCF.id, dataCF.index, subKey); err != nil {
+			return 0, err
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+
+	meta.size -= uint32(len(removed))
+	if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+		return 0, err
+	}
+	if err = wb.commit(); err != nil {
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// SIsMember reports whether the member is in the set stored at key.
+func (db *DB) SIsMember(key, member []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return false, nil
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return false, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureSet)
+	if err != nil || meta.size == 0 {
+		return false, err
+	}
+	return dataCF.index.Get(structureSubKey(key, meta.version, member)) != nil, nil
+}
+
+// SMembers returns all members of the set stored at key in ascending order.
+func (db *DB) SMembers(key []byte) ([][]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return nil, nil
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return nil, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureSet)
+	if err != nil || meta.size == 0 {
+		return nil, err
+	}
+
+	// members are never read from the data files, the sub-keys carry them
+	prefix := structureSubKey(key, meta.version, nil)
+	iterator := db.newIterator(dataCF.index, IteratorOptions{Prefix: prefix})
+	defer iterator.Close()
+	members := make([][]byte, 0, meta.size)
+	for ; iterator.Valid(); iterator.Next() {
+		members = append(members, copyBytes(bytes.TrimPrefix(iterator.Key(), prefix)))
+	}
+	return members, nil
+}
+
+// SCard returns the number of members of the set stored at key, 0 if the set does not exist.
+func (db *DB) SCard(key []byte) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return 0, nil
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureSet)
+	if err != nil {
+		return 0, err
+	}
+	return int(meta.size), nil
+}
@@ -0,0 +1,95 @@
+package go_kv
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDB_Set(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-set")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+
+	tests := []struct {
+		name      string
+		add       []string
+		remove    []string
+		wantAdded int
+		wantRem   int
+		want      []string
+	}{
+		{name: "add members", add: []string{"go", "kv", "go"}, wantAdded: 2, want: []string{"go", "kv"}},
+		{name: "add existing and new", add: []string{"kv", "db"}, wantAdded: 1, want: []string{"db", "go", "kv"}},
+		{name: "remove members", remove: []string{"go", "missing", "go"}, wantRem: 1, want: []string{"db", "kv"}},
+		{name: "remove all", remove: []string{"db", "kv"}, wantRem: 2, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.add) > 0 {
+				if added, err := db.SAdd([]byte("tags"), toByteSlices(tt.add)...); added != tt.wantAdded || err != nil {
+					t.Errorf("SAdd() got = %d, %v, want %d", added, err, tt.wantAdded)
+				}
+			}
+			if len(tt.remove) > 0 {
+				if removed, err := db.SRem([]byte("tags"), toByteSlices(tt.remove)...); removed != tt.wantRem || err != nil {
+					t.Errorf("SRem() got = %d, %v, want %d", removed, err, tt.wantRem)
+				}
+			}
+
+			members, err := db.SMembers([]byte("tags"))
+			if err != nil {
+				t.Errorf("SMembers() error = %v", err)
+			}
+			var got []string
+			for _, member := range members {
+				got = append(got, string(member))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SMembers() got = %v, want %v", got, tt.want)
+			}
+			if n, _ := db.SCard([]byte("tags")); n != len(tt.want) {
+				t.Errorf("SCard() got = %d, want %d", n, len(tt.want))
+			}
+			for _, member := range tt.want {
+				if ok, _ := db.SIsMember([]byte("tags"), []byte(member)); !ok {
+					t.Errorf("SIsMember(%s) got = false, want true", member)
+				}
+			}
+		})
+	}
+
+	_, _ = db.RPush([]byte("queue"), []byte("x"))
+	if _, err = db.SAdd([]byte("queue"), []byte("x")); !errors.Is(err, ErrWrongType) {
+		t.Errorf("SAdd() error = %v, wantErr %v", err, ErrWrongType)
+	}
+
+	// more members than a default WriteBatch holds are still added and removed at once
+	many := make([][]byte, DefaultWriteBatchOptions.MaxBatchNum+1)
+	for i := range many {
+		many[i] = []byte(strconv.Itoa(i))
+	}
+	if added, err := db.SAdd([]byte("numbers"), many...); added != len(many) || err != nil {
+		t.Errorf("SAdd() got = %d, %v, want %d", added, err, len(many))
+	}
+	if removed, err := db.SRem([]byte("numbers"), many...); removed != len(many) || err != nil {
+		t.Errorf("SRem() got = %d, %v, want %d", removed, err, len(many))
+	}
+}
+
+// toByteSlices converts strings to byte slices.
+func toByteSlices(strs []string) [][]byte {
+	bs := make([][]byte, len(strs))
+	for i, s := range strs {
+		bs[i] = []byte(s)
+	}
+	return bs
+}
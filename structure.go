@@ -0,0 +1,224 @@
+package go_kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// structureType is the type of a data structure stored by the Redis-style layers.
+type structureType = byte
+
+const (
+	// structureHash is a map of fields to values.
+	structureHash structureType = iota + 1
+	// structureList is a sequence of elements ordered by insertion.
+	structureList
+	// structureSet is an unordered collection of unique members.
+	structureSet
+	// structureZSet is a collection of unique members ordered by score.
+	structureZSet
+)
+
+// initialListIndex is the head and tail index of a new list, so it can grow in both directions.
+const initialListIndex uint64 = math.MaxUint64 / 2
+
+const (
+	// structureMetaFamily keeps one metadata record per data structure key.
+	structureMetaFamily = "redis-meta"
+	// structureDataFamily keeps the fields, elements and members of all data structures.
+	structureDataFamily = "redis-data"
+)
+
+// structureMeta is the metadata record of a data structure.
+// Its sub-keys embed the version, so bumping the version drops all of them at once.
+type structureMeta struct {
+	dataType structureType // type of the data structure
+	version  uint64        // version embedded in every sub-key
+	size     uint32        // number of fields, elements or members, 0 means the structure does not exist
+	head     uint64        // index of the first element of a list
+	tail     uint64        // index after the last element of a list
+}
+
+// encode encodes the metadata record, head and tail are only stored for lists.
+// +-------------------------------------------------------------------------------------------------------------------+
+// | type | version                  | size                    | head (list only)         | tail (list only)         |
+// +-------------------------------------------------------------------------------------------------------------------+
+// | 1    | Variable length (max 10) | Variable length (max 5) | Variable length (max 10) | Variable length (max 10) |
+func (m *structureMeta) encode() []byte {
+	buf := make([]byte, 1+binary.MaxVarintLen64*3+binary.MaxVarintLen32)
+	buf[0] = m.dataType
+	index := 1
+	index += binary.PutUvarint(buf[index:], m.version)
+	index += binary.PutUvarint(buf[index:], uint64(m.size))
+	if m.dataType == structureList {
+		index += binary.PutUvarint(buf[index:], m.head)
+		index += binary.PutUvarint(buf[index:], m.tail)
+	}
+	return buf[:index]
+}
+
+// decodeStructureMeta decodes a metadata record.
+func decodeStructureMeta(buf []byte) *structureMeta {
+	meta := &structureMeta{dataType: buf[0]}
+	index := 1
+	version, n := binary.Uvarint(buf[index:])
+	meta.version = version
+	index += n
+	size, n := binary.Uvarint(buf[index:])
+	meta.size = uint32(size)
+	index += n
+	if meta.dataType == structureList {
+		head, n := binary.Uvarint(buf[index:])
+		meta.head = head
+		index += n
+		meta.tail, _ = binary.Uvarint(buf[index:])
+	}
+	return meta
+}
+
+// structureSubKey returns the sub-key of a data structure, sub-keys of one key and version sort by suffix.
+// +--------------------------------------------------------------------+
+// | key size                 | key | version (big endian) | suffix      |
+// +--------------------------------------------------------------------+
+// | Variable length (max 10) | n   | 8                    | n           |
+func structureSubKey(key []byte, version uint64, suffix []byte) []byte {
+	buf := make([]byte, binary.MaxVarintLen64+len(key)+8+len(suffix))
+	index := binary.PutUvarint(buf, uint64(len(key)))
+	index += copy(buf[index:], key)
+	binary.BigEndian.PutUint64(buf[index:], version)
+	index += 8
+	index += copy(buf[index:], suffix)
+	return buf[:index]
+}
+
+// DeleteStructure deletes the data structure stored at key by bumping the version in its metadata.
+// The sub-keys of the old version are then removed with one range tombstone,
+// a crash in between only leaves sub-keys that can no longer be reached.
+// It returns false if no data structure is stored at key.
+func (db *DB) DeleteStructure(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return false, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return false, ErrColumnFamilyDropped
+	}
+	value, err := db.getFamilyValue(metaCF, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	meta := decodeStructureMeta(value)
+	if meta.size == 0 {
+		return false, nil
+	}
+
+	version := meta.version
+	meta.version++
+	meta.size = 0
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+		return false, err
+	}
+	if err = wb.commit(); err != nil {
+		return false, err
+	}
+	start, end := structureSubKey(key, version, nil), structureSubKey(key, version+1, nil)
+	return true, db.deleteFamilyRange(dataCF.id, dataCF.index, start, end)
+}
+
+// structureFamilies returns the column families of the data structures, creating them on first use.
+// Only writes create them, reads use lookupStructureFamilies.
+func (db *DB) structureFamilies() (metaCF, dataCF *ColumnFamily, err error) {
+	if metaCF, err = db.getOrCreateColumnFamily(structureMetaFamily); err != nil {
+		return nil, nil, err
+	}
+	if dataCF, err = db.getOrCreateColumnFamily(structureDataFamily); err != nil {
+		return nil, nil, err
+	}
+	return metaCF, dataCF, nil
+}
+
+// lookupStructureFamilies returns the column families of the data structures without creating them,
+// so reads work on a read-only database. It returns false if either of them does not exist.
+func (db *DB) lookupStructureFamilies() (metaCF, dataCF *ColumnFamily, ok bool) {
+	var err error
+	if metaCF, err = db.ColumnFamily(structureMetaFamily); err != nil {
+		return nil, nil, false
+	}
+	if dataCF, err = db.ColumnFamily(structureDataFamily); err != nil {
+		return nil, nil, false
+	}
+	return metaCF, dataCF, true
+}
+
+// getOrCreateColumnFamily returns the column family with the given name, creating it if it does not exist.
+func (db *DB) getOrCreateColumnFamily(name string) (*ColumnFamily, error) {
+	cf, err := db.ColumnFamily(name)
+	if !errors.Is(err, ErrColumnFamilyNotFound) {
+		return cf, err
+	}
+	cf, err = db.CreateColumnFamily(name)
+	if errors.Is(err, ErrColumnFamilyExists) {
+		// created concurrently
+		return db.ColumnFamily(name)
+	}
+	return cf, err
+}
+
+// findStructureMeta returns the metadata of the data structure of the given type stored at key.
+// A new metadata record is returned if no data structure is stored at key, its version
+// is greater than any version used by the key before.
+// It returns ErrWrongType if a data structure of another type is stored at key.
+// Access this method needs db.mut is required.
+func (db *DB) findStructureMeta(metaCF *ColumnFamily, key []byte, dataType structureType) (*structureMeta, error) {
+	var version uint64 = 1
+	value, err := db.getFamilyValue(metaCF, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		meta := decodeStructureMeta(value)
+		if meta.size > 0 {
+			if meta.dataType != dataType {
+				return nil, ErrWrongType
+			}
+			return meta, nil
+		}
+		version = meta.version + 1
+	}
+
+	meta := &structureMeta{dataType: dataType, version: version}
+	if dataType == structureList {
+		meta.head, meta.tail = initialListIndex, initialListIndex
+	}
+	return meta, nil
+}
+
+// structureWriteBatch returns a WriteBatch holding n sub-key writes and the metadata record of a data structure,
+// so a command with more arguments than DefaultWriteBatchOptions.MaxBatchNum is still written atomically.
+func (db *DB) structureWriteBatch(n int) *WriteBatch {
+	options := DefaultWriteBatchOptions
+	options.MaxBatchNum = max(options.MaxBatchNum, uint(n)+1)
+	return db.NewWriteBatch(options)
+}
+
+// getFamilyValue retrieves the value of a key from the column family.
+// Access this method needs db.mut is required.
+func (db *DB) getFamilyValue(cf *ColumnFamily, key []byte) ([]byte, error) {
+	logRecordPos := cf.index.Get(key)
+	if logRecordPos == nil {
+		return nil, ErrKeyNotFound
+	}
+	return db.getValueByPosition(logRecordPos)
+}
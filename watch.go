@@ -0,0 +1,385 @@
+package go_kv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go-kv/data"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// watchBufferSize is the number of events buffered for a watcher before it falls behind.
+const watchBufferSize = 1024
+
+// EventType is the type of a change data capture event.
+type EventType int8
+
+const (
+	// EventPut is a key set to a value.
+	EventPut EventType = iota + 1
+	// EventDelete is a deleted key.
+	EventDelete
+	// EventDeleteRange is a deleted range of keys, Key is the inclusive start and Value the exclusive end.
+	EventDeleteRange
+	// EventBatchCommit is the commit of the WriteBatch SeqNo, the events of the batch come before it.
+	EventBatchCommit
+)
+
+// Event is a write to the database, in the order the writes were appended to the data files.
+// The events of a WriteBatch carry its sequence number and only take effect with its EventBatchCommit,
+// a batch that failed to commit never gets one.
+type Event struct {
+	Type   EventType
+	Family string            // column family name, empty for the default column family
+	Key    []byte            // key, nil for EventBatchCommit
+	Value  []byte            // value of EventPut, exclusive end of EventDeleteRange
+	SeqNo  uint64            // sequence number of the WriteBatch, 0 for writes outside a batch
+	Time   time.Time         // time the write was appended, zero if the record has no timestamp
+	Pos    data.LogRecordPos // position of the record in the data files, a Tail resumes after it
+}
+
+// TailOptions selects where a Tail starts reading the data files.
+// With neither From nor SeqNo set it starts at the first record of the data files.
+type TailOptions struct {
+	Prefix []byte             // only keys with the prefix, nil for all keys
+	From   *data.LogRecordPos // start after the record at From
+	SeqNo  uint64             // start after the commit of the WriteBatch with this sequence number, if From is nil
+}
+
+// eventFilter selects the events of the keys with a prefix.
+type eventFilter struct {
+	prefix    []byte
+	lastBatch uint64 // sequence number of the last WriteBatch an event was selected of
+}
+
+// watcher is a subscriber of the events of new writes.
+type watcher struct {
+	filter eventFilter // guarded by db.mut
+	events chan Event
+	err    error // why events was closed
+	closed bool
+}
+
+// Watch returns a channel of the events of new writes to keys with the given prefix,
+// and a function that reports why the channel was closed.
+// The channel is closed with the context error when ctx is done, with nil when the database is closed,
+// and with ErrWatchLagged when the receiver falls too far behind the writes,
+// the last received Event.Pos can then be passed to Tail to carry on.
+func (db *DB) Watch(ctx context.Context, prefix []byte) (<-chan Event, func() error) {
+	db.mut.Lock()
+	w := db.addWatcher(prefix)
+	db.mut.Unlock()
+	return db.forwardEvents(ctx, w, nil)
+}
+
+// Tail returns a channel of the events of the data files from the start point of the options on,
+// followed by the events of new writes without a gap, and a function that reports why the channel was closed.
+// It is a durable Watch: the data files are read again after a restart, so a receiver that stores the
+// Pos of the last event it handled can resume from it.
+// It returns ErrTailPositionNotFound if the start point is not in the data files, and ErrTailPositionMerged
+// if it is in a data file rewritten by a merge since. The channel is closed like the one of Watch.
+func (db *DB) Tail(ctx context.Context, options TailOptions) (<-chan Event, func() error) {
+	// live events start at the end of the data files as of registering the watcher
+	db.mut.Lock()
+	w := db.addWatcher(options.Prefix)
+	var end data.LogRecordPos
+	var fileIds []uint32
+	if db.activeFile != nil {
+		end = data.LogRecordPos{Fid: db.activeFile.FileId, Offset: db.activeFile.WriteOff}
+		fileIds = append(fileIds, db.activeFile.FileId)
+	}
+	for fileId := range db.olderFiles {
+		fileIds = append(fileIds, fileId)
+	}
+	db.mut.Unlock()
+	sort.Slice(fileIds, func(i, j int) bool {
+		return fileIds[i] < fileIds[j]
+	})
+
+	replay := func(send func(Event) bool) error {
+		start, err := db.tailStart(options, fileIds, end)
+		if err != nil {
+			return err
+		}
+		filter := &eventFilter{prefix: w.filter.prefix}
+		return db.readEvents(filter, fileIds, start, end, send)
+	}
+	return db.forwardEvents(ctx, w, replay)
+}
+
+// addWatcher registers a watcher of the events of new writes.
+// Access this method needs db.mut is required.
+func (db *DB) addWatcher(prefix []byte) *watcher {
+	w := &watcher{
+		filter: eventFilter{prefix: copyBytes(prefix)},
+		events: make(chan Event, watchBufferSize),
+	}
+	if db.watchers == nil {
+		db.watchers = make(map[*watcher]struct{})
+	}
+	db.watchers[w] = struct{}{}
+	return w
+}
+
+// removeWatcher unregisters a watcher and closes its events channel with the given reason.
+// Access this method needs db.mut is required.
+func (db *DB) removeWatcher(w *watcher, err error) {
+	if w.closed {
+		return
+	}
+	w.closed, w.err = true, err
+	delete(db.watchers, w)
+	close(w.events)
+}
+
+// forwardEvents sends the events of replay, if any, and then the events of new writes of the watcher
+// to the returned channel until ctx is done or the watcher is closed.
+func (db *DB) forwardEvents(ctx context.Context, w *watcher, replay func(send func(Event) bool) error) (<-chan Event, func() error) {
+	out := make(chan Event)
+	var err error
+	go func() {
+		defer close(out)
+		send := func(event Event) bool {
+			select {
+			case out <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		stop := func(reason error) {
+			db.mut.Lock()
+			db.removeWatcher(w, reason)
+			db.mut.Unlock()
+			err = w.err
+		}
+
+		if replay != nil {
+			if replayErr := replay(send); replayErr != nil {
+				stop(replayErr)
+				return
+			}
+			if ctx.Err() != nil {
+				stop(ctx.Err())
+				return
+			}
+		}
+		for {
+			select {
+			case event, ok := <-w.events:
+				if !ok {
+					// closed by the database, w.err is set before the channel is closed
+					err = w.err
+					return
+				}
+				if !send(event) {
+					stop(ctx.Err())
+					return
+				}
+			case <-ctx.Done():
+				stop(ctx.Err())
+				return
+			}
+		}
+	}()
+	return out, func() error { return err }
+}
+
+// notifyWatchers sends the event of an appended log record to the watchers of its key.
+// A watcher whose buffer is full is closed with ErrWatchLagged, writes never wait for watchers.
+// Access this method needs db.mut is required.
+func (db *DB) notifyWatchers(logRecord *data.LogRecord, pos *data.LogRecordPos) {
+	if len(db.watchers) == 0 {
+		return
+	}
+	event, ok := db.newEvent(logRecord, *pos)
+	if !ok {
+		return
+	}
+	// the record may reuse the buffers of the caller
+	event.Key, event.Value = copyBytes(event.Key), copyBytes(event.Value)
+	for w := range db.watchers {
+		if !w.filter.match(&event) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			db.removeWatcher(w, ErrWatchLagged)
+		}
+	}
+}
+
+// newEvent returns the event of a log record, ok is false for records of dropped column families.
+// Access this method needs db.mut is required.
+func (db *DB) newEvent(logRecord *data.LogRecord, pos data.LogRecordPos) (event Event, ok bool) {
+	seqNo, key := parseLogRecordKey(logRecord.Key)
+	event = Event{Key: key, Value: logRecord.Value, SeqNo: seqNo, Pos: pos}
+	switch logRecord.Type {
+	case data.LogRecordNormal:
+		event.Type = EventPut
+	case data.LogRecordDeleted:
+		event.Type, event.Value = EventDelete, nil
+	case data.LogRecordRangeDeleted:
+		event.Type = EventDeleteRange
+	case data.LogRecordTxFinished:
+		event.Type, event.Key, event.Value = EventBatchCommit, nil, nil
+	default:
+		return Event{}, false
+	}
+	if logRecord.Timestamp != 0 {
+		event.Time = time.UnixMilli(logRecord.Timestamp)
+	}
+	if logRecord.Family != defaultFamilyId {
+		cf, ok := db.families[logRecord.Family]
+		if !ok {
+			return Event{}, false
+		}
+		event.Family = cf.name
+	}
+	return event, true
+}
+
+// match reports whether the event is selected and remembers the batches events were selected of,
+// the commit of a batch is selected if one of its events was.
+func (f *eventFilter) match(event *Event) bool {
+	var ok bool
+	switch event.Type {
+	case EventBatchCommit:
+		return event.SeqNo == f.lastBatch
+	case EventDeleteRange:
+		// the range [Key, Value) overlaps the keys with the prefix
+		upper := prefixUpperBound(f.prefix)
+		ok = bytes.Compare(event.Value, f.prefix) > 0 && (upper == nil || bytes.Compare(event.Key, upper) < 0)
+	default:
+		ok = bytes.HasPrefix(event.Key, f.prefix)
+	}
+	if ok && event.SeqNo != nonTransactionalSeqNo {
+		f.lastBatch = event.SeqNo
+	}
+	return ok
+}
+
+// tailStart returns the position of the first record a Tail reads from the data files.
+func (db *DB) tailStart(options TailOptions, fileIds []uint32, end data.LogRecordPos) (data.LogRecordPos, error) {
+	var start data.LogRecordPos
+	if len(fileIds) > 0 {
+		start.Fid = fileIds[0]
+	}
+
+	// data files before the merge file id were rewritten by the last merge applied by Open
+	var mergeFileId uint32
+	if _, err := os.Stat(filepath.Join(db.options.DirPath, data.MergeFinishedFileName)); err == nil {
+		if mergeFileId, err = db.getNonMergeFileId(db.options.DirPath); err != nil {
+			return start, err
+		}
+	}
+
+	switch {
+	case options.From != nil:
+		if options.From.Fid < mergeFileId {
+			return start, ErrTailPositionMerged
+		}
+		i := sort.Search(len(fileIds), func(i int) bool { return fileIds[i] >= options.From.Fid })
+		if i == len(fileIds) || fileIds[i] != options.From.Fid {
+			return start, fmt.Errorf("%w: data file %d", ErrTailPositionNotFound, options.From.Fid)
+		}
+		// skip the record at From
+		dataFile, err := data.OpenDataFile(db.options.DirPath, options.From.Fid)
+		if err != nil {
+			return start, err
+		}
+		defer dataFile.Close()
+		_, size, err := dataFile.ReadLogRecord(options.From.Offset)
+		if err != nil {
+			return start, fmt.Errorf("%w: %d:%d: %v", ErrTailPositionNotFound, options.From.Fid, options.From.Offset, err)
+		}
+		return data.LogRecordPos{Fid: options.From.Fid, Offset: options.From.Offset + size}, nil
+	case options.SeqNo != nonTransactionalSeqNo:
+		// merge drops the sequence numbers, the commit can only be in the files after it
+		var afterMerge []uint32
+		for _, fileId := range fileIds {
+			if fileId >= mergeFileId {
+				afterMerge = append(afterMerge, fileId)
+			}
+		}
+		var commit *data.LogRecordPos
+		err := db.readRecords(afterMerge, data.LogRecordPos{}, end, func(logRecord *data.LogRecord, pos data.LogRecordPos, size int64) bool {
+			if logRecord.Type == data.LogRecordTxFinished {
+				if seqNo, _ := parseLogRecordKey(logRecord.Key); seqNo == options.SeqNo {
+					commit = &data.LogRecordPos{Fid: pos.Fid, Offset: pos.Offset + size}
+				}
+			}
+			return commit == nil
+		})
+		if err != nil {
+			return start, err
+		}
+		if commit == nil {
+			if mergeFileId > 0 {
+				return start, fmt.Errorf("%w: transaction %d is not after the last merge", ErrTailPositionNotFound, options.SeqNo)
+			}
+			return start, fmt.Errorf("%w: transaction %d", ErrTailPositionNotFound, options.SeqNo)
+		}
+		return *commit, nil
+	}
+	return start, nil
+}
+
+// readEvents sends the events of the records from start up to end selected by the filter,
+// until send returns false.
+func (db *DB) readEvents(filter *eventFilter, fileIds []uint32, start, end data.LogRecordPos, send func(Event) bool) error {
+	return db.readRecords(fileIds, start, end, func(logRecord *data.LogRecord, pos data.LogRecordPos, _ int64) bool {
+		db.mut.RLock()
+		event, ok := db.newEvent(logRecord, pos)
+		db.mut.RUnlock()
+		if ok && filter.match(&event) {
+			return send(event)
+		}
+		return true
+	})
+}
+
+// readRecords calls fn for every record of the data files from start up to end in write order,
+// until fn returns false.
+func (db *DB) readRecords(fileIds []uint32, start, end data.LogRecordPos,
+	fn func(logRecord *data.LogRecord, pos data.LogRecordPos, size int64) bool) error {
+	for _, fileId := range fileIds {
+		if fileId < start.Fid {
+			continue
+		}
+		if fileId > end.Fid {
+			return nil
+		}
+		dataFile, err := data.OpenDataFile(db.options.DirPath, fileId)
+		if err != nil {
+			return err
+		}
+		var offset int64
+		if fileId == start.Fid {
+			offset = start.Offset
+		}
+		for fileId < end.Fid || offset < end.Offset {
+			logRecord, size, err := dataFile.ReadLogRecord(offset)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				_ = dataFile.Close()
+				return err
+			}
+			if !fn(logRecord, data.LogRecordPos{Fid: fileId, Offset: offset}, size) {
+				return dataFile.Close()
+			}
+			offset += size
+		}
+		if err = dataFile.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
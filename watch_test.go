@@ -0,0 +1,243 @@
+package go_kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-kv/data"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// receiveEvents receives n events, failing the test if they do not arrive in time.
+func receiveEvents(t *testing.T, events <-chan Event, n int) []Event {
+	t.Helper()
+	var got []Event
+	for len(got) < n {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed after %d events, want %d", len(got), n)
+			}
+			got = append(got, event)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d events, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+// waitClosed waits until the events channel is closed and returns the events received before.
+func waitClosed(t *testing.T, events <-chan Event) []Event {
+	t.Helper()
+	var got []Event
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return got
+			}
+			got = append(got, event)
+		case <-time.After(time.Second):
+			t.Fatal("events not closed")
+		}
+	}
+}
+
+// eventString formats the parts of an event the tests compare.
+func eventString(event Event) string {
+	return fmt.Sprintf("%d %s %s=%s seq=%d", event.Type, event.Family, event.Key, event.Value, event.SeqNo)
+}
+
+func TestDB_Watch(t *testing.T) {
+	db, _ := openColumnFamilyTestDB(t)
+	defer destroyDB(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, errFn := db.Watch(ctx, []byte("user:"))
+
+	_ = db.Put([]byte("other"), []byte("x"))
+	_ = db.Put([]byte("user:1"), []byte("alice"))
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("user:2"), []byte("bob"))
+	_ = wb.Put([]byte("other"), []byte("y"))
+	_ = wb.Commit()
+	batchSeqNo := db.seqNo
+	// a batch without a key of the prefix has no commit event either
+	wb = db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("other"), []byte("z"))
+	_ = wb.Commit()
+	_ = db.Delete([]byte("user:1"))
+	_ = db.DeleteRange([]byte("a"), []byte("b"))
+	_ = db.DeleteRange([]byte("u"), []byte("v"))
+	users, _ := db.CreateColumnFamily("users")
+	_ = users.Put([]byte("user:3"), []byte("carol"))
+
+	want := []string{
+		"1  user:1=alice seq=0",
+		fmt.Sprintf("1  user:2=bob seq=%d", batchSeqNo),
+		fmt.Sprintf("4  = seq=%d", batchSeqNo),
+		"2  user:1= seq=0",
+		"3  u=v seq=0",
+		"1 users user:3=carol seq=0",
+	}
+	got := receiveEvents(t, events, len(want))
+	var gotStrings []string
+	for _, event := range got {
+		gotStrings = append(gotStrings, eventString(event))
+		if event.Time.IsZero() {
+			t.Errorf("event %s has no time", eventString(event))
+		}
+	}
+	if !reflect.DeepEqual(gotStrings, want) {
+		t.Errorf("events = %q, want %q", gotStrings, want)
+	}
+	if value, err := db.getValueByPosition(&got[0].Pos); err != nil || string(value) != "alice" {
+		t.Errorf("value at event position = %q, %v, want %q, nil", value, err, "alice")
+	}
+
+	cancel()
+	waitClosed(t, events)
+	if err := errFn(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch() error = %v, wantErr %v", err, context.Canceled)
+	}
+	if len(db.watchers) != 0 {
+		t.Errorf("%d watchers left after cancel", len(db.watchers))
+	}
+}
+
+func TestDB_Watch_LaggedAndClose(t *testing.T) {
+	db, _ := openColumnFamilyTestDB(t)
+	defer destroyDB(db)
+
+	lagged, laggedErr := db.Watch(context.Background(), nil)
+	closed, closedErr := db.Watch(context.Background(), []byte("none"))
+	// writes never wait for the watcher that is not received from
+	for i := 0; i < 2*watchBufferSize; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte("v"))
+	}
+	got := waitClosed(t, lagged)
+	if err := laggedErr(); !errors.Is(err, ErrWatchLagged) {
+		t.Errorf("Watch() error = %v, wantErr %v", err, ErrWatchLagged)
+	}
+	if len(got) == 0 || len(got) >= 2*watchBufferSize {
+		t.Errorf("lagged watcher received %d events", len(got))
+	}
+
+	_ = db.Close()
+	waitClosed(t, closed)
+	if err := closedErr(); err != nil {
+		t.Errorf("Watch() error = %v after close, want nil", err)
+	}
+}
+
+func TestDB_Watch_CloseEmpty(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-watch-empty")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the database is closed before anything is written
+	watched, watchErr := db.Watch(context.Background(), nil)
+	tailed, tailErr := db.Tail(context.Background(), TailOptions{})
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, watched)
+	waitClosed(t, tailed)
+	if err = watchErr(); err != nil {
+		t.Errorf("Watch() error = %v after close, want nil", err)
+	}
+	if err = tailErr(); err != nil {
+		t.Errorf("Tail() error = %v after close, want nil", err)
+	}
+}
+
+func TestDB_Tail(t *testing.T) {
+	db, opts := openColumnFamilyTestDB(t)
+	opts.DataFileSize = 1024
+	_ = db.Close()
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// enough records for several data files
+	for i := 0; i < 50; i++ {
+		_ = db.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprintf("value-%02d", i)))
+	}
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put([]byte("key-tx"), []byte("tx"))
+	_ = wb.Commit()
+	batchSeqNo := db.seqNo
+	_ = db.Put([]byte("key-after"), []byte("after"))
+
+	all, _ := db.Tail(ctx, TailOptions{Prefix: []byte("key-")})
+	got := receiveEvents(t, all, 53)
+	if got[0].Pos.Fid == got[52].Pos.Fid {
+		t.Fatalf("events are in one data file, want several")
+	}
+	if eventString(got[49]) != "1  key-49=value-49 seq=0" || got[51].Type != EventBatchCommit {
+		t.Errorf("events = %s ... %s", eventString(got[49]), eventString(got[51]))
+	}
+	// new writes follow the data files
+	_ = db.Put([]byte("key-live"), []byte("live"))
+	if live := receiveEvents(t, all, 1); eventString(live[0]) != "1  key-live=live seq=0" {
+		t.Errorf("live event = %s", eventString(live[0]))
+	}
+
+	// resume after a restart
+	resumeAt := got[29].Pos
+	cancel()
+	_ = db.Close()
+	if db, err = Open(opts); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	fromPos, _ := db.Tail(ctx, TailOptions{From: &resumeAt})
+	if first := receiveEvents(t, fromPos, 1); eventString(first[0]) != "1  key-30=value-30 seq=0" {
+		t.Errorf("first event after position = %s", eventString(first[0]))
+	}
+	fromSeqNo, _ := db.Tail(ctx, TailOptions{SeqNo: batchSeqNo})
+	if first := receiveEvents(t, fromSeqNo, 1); eventString(first[0]) != "1  key-after=after seq=0" {
+		t.Errorf("first event after batch = %s", eventString(first[0]))
+	}
+
+	notFound := []TailOptions{
+		{SeqNo: 1000},
+		{From: &data.LogRecordPos{Fid: 1000}},
+	}
+	for _, options := range notFound {
+		events, errFn := db.Tail(ctx, options)
+		waitClosed(t, events)
+		if err = errFn(); !errors.Is(err, ErrTailPositionNotFound) {
+			t.Errorf("Tail(%+v) error = %v, wantErr %v", options, err, ErrTailPositionNotFound)
+		}
+	}
+
+	// positions in the data files rewritten by a merge are gone
+	if err = db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	if db, err = Open(opts); err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+	events, errFn := db.Tail(ctx, TailOptions{From: &got[0].Pos})
+	waitClosed(t, events)
+	if err = errFn(); !errors.Is(err, ErrTailPositionMerged) {
+		t.Errorf("Tail() error = %v, wantErr %v", err, ErrTailPositionMerged)
+	}
+}
@@ -0,0 +1,298 @@
+package go_kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const (
+	// zsetMemberTag marks the sub-key of a member, its value is the score of the member.
+	zsetMemberTag byte = 'm'
+	// zsetScoreTag marks the score-ordered sub-key of a member, it carries the score and the member.
+	zsetScoreTag byte = 's'
+)
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member []byte
+	Score  float64
+}
+
+// ZAdd adds the member with the given score to the sorted set stored at key,
+// or updates its score if it is already a member. The sorted set is created if it does not exist.
+// It returns true if the member is new.
+func (db *DB) ZAdd(key []byte, score float64, member []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	if math.IsNaN(score) {
+		return false, ErrScoreIsNaN
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return false, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return false, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureZSet)
+	if err != nil {
+		return false, err
+	}
+
+	memberKey := zsetMemberKey(key, meta.version, member)
+	oldScore, err := db.getFamilyValue(dataCF, memberKey)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return false, err
+	}
+	isNew := err != nil
+
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	if isNew {
+		meta.size++
+		if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+			return false, err
+		}
+	} else {
+		if decodeZSetScore(oldScore) == score {
+			return false, nil
+		}
+		oldScoreKey := zsetScoreKey(key, meta.version, decodeZSetScore(oldScore), member)
+		if err = wb.delete(dataCF.id, dataCF.index, oldScoreKey); err != nil {
+			return false, err
+		}
+	}
+	if err = wb.PutCF(dataCF, memberKey, encodeZSetScore(score)); err != nil {
+		return false, err
+	}
+	if err = wb.PutCF(dataCF, zsetScoreKey(key, meta.version, score, member), nil); err != nil {
+		return false, err
+	}
+	return isNew, wb.commit()
+}
+
+// ZScore returns the score of the member in the sorted set stored at key.
+// It returns ErrKeyNotFound if the sorted set or the member does not exist.
+func (db *DB) ZScore(key, member []byte) (float64, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return 0, ErrKeyNotFound
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureZSet)
+	if err != nil {
+		return 0, err
+	}
+	if meta.size == 0 {
+		return 0, ErrKeyNotFound
+	}
+	score, err := db.getFamilyValue(dataCF, zsetMemberKey(key, meta.version, member))
+	if err != nil {
+		return 0, err
+	}
+	return decodeZSetScore(score), nil
+}
+
+// ZRem removes the member from the sorted set stored at key.
+// It returns false if the sorted set or the member does not exist.
+func (db *DB) ZRem(key, member []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, err := db.structureFamilies()
+	if err != nil {
+		return false, err
+	}
+
+	db.mut.Lock()
+	defer db.mut.Unlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return false, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureZSet)
+	if err != nil || meta.size == 0 {
+		return false, err
+	}
+
+	memberKey := zsetMemberKey(key, meta.version, member)
+	score, err := db.getFamilyValue(dataCF, memberKey)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	meta.size--
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	if err = wb.PutCF(metaCF, key, meta.encode()); err != nil {
+		return false, err
+	}
+	if err = wb.delete(dataCF.id, dataCF.index, memberKey); err != nil {
+		return false, err
+	}
+	scoreKey := zsetScoreKey(key, meta.version, decodeZSetScore(score), member)
+	if err = wb.delete(dataCF.id, dataCF.index, scoreKey); err != nil {
+		return false, err
+	}
+	return true, wb.commit()
+}
+
+// ZRangeByScore returns the members of the sorted set stored at key with a score
+// between min and max, both inclusive, ordered by score and then by member.
+func (db *DB) ZRangeByScore(key []byte, min, max float64) ([]ZMember, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	if math.IsNaN(min) || math.IsNaN(max) {
+		return nil, ErrScoreIsNaN
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return nil, nil
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return nil, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureZSet)
+	if err != nil || meta.size == 0 || min > max {
+		return nil, err
+	}
+
+	// score keys are ordered by score, so the range is served by one bounded scan
+	prefix := zsetScorePrefix(key, meta.version)
+	iterator := db.newIterator(dataCF.index, IteratorOptions{
+		LowerBound: zsetScoreKey(key, meta.version, min, nil),
+		UpperBound: prefixUpperBound(zsetScoreKey(key, meta.version, max, nil)),
+	})
+	defer iterator.Close()
+	var members []ZMember
+	for ; iterator.Valid(); iterator.Next() {
+		suffix := iterator.Key()[len(prefix):]
+		members = append(members, ZMember{
+			Member: copyBytes(suffix[8:]),
+			Score:  decodeZSetScore(suffix[:8]),
+		})
+	}
+	return members, nil
+}
+
+// ZRank returns the rank of the member in the sorted set stored at key, the member with the lowest score has rank 0.
+// No rank is stored, the score keys before the one of the member are counted,
+// so it takes time linear in the rank.
+// It returns ErrKeyNotFound if the sorted set or the member does not exist.
+func (db *DB) ZRank(key, member []byte) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrKeyIsEmpty
+	}
+	metaCF, dataCF, ok := db.lookupStructureFamilies()
+	if !ok {
+		// no data structure was ever written
+		return 0, ErrKeyNotFound
+	}
+
+	db.mut.RLock()
+	defer db.mut.RUnlock()
+
+	if metaCF.dropped || dataCF.dropped {
+		return 0, ErrColumnFamilyDropped
+	}
+	meta, err := db.findStructureMeta(metaCF, key, structureZSet)
+	if err != nil {
+		return 0, err
+	}
+	if meta.size == 0 {
+		return 0, ErrKeyNotFound
+	}
+	score, err := db.getFamilyValue(dataCF, zsetMemberKey(key, meta.version, member))
+	if err != nil {
+		return 0, err
+	}
+
+	// count the score keys before the one of the member
+	iterator := db.newIterator(dataCF.index, IteratorOptions{
+		LowerBound: zsetScorePrefix(key, meta.version),
+		UpperBound: zsetScoreKey(key, meta.version, decodeZSetScore(score), member),
+	})
+	defer iterator.Close()
+	var rank int
+	for ; iterator.Valid(); iterator.Next() {
+		rank++
+	}
+	return rank, nil
+}
+
+// zsetMemberKey returns the sub-key holding the score of a member.
+func zsetMemberKey(key []byte, version uint64, member []byte) []byte {
+	suffix := make([]byte, 1+len(member))
+	suffix[0] = zsetMemberTag
+	copy(suffix[1:], member)
+	return structureSubKey(key, version, suffix)
+}
+
+// zsetScoreKey returns the score-ordered sub-key of a member.
+// +-----------------------------------------+
+// | tag | score (order preserving) | member |
+// +-----------------------------------------+
+// | 1   | 8                        | n      |
+func zsetScoreKey(key []byte, version uint64, score float64, member []byte) []byte {
+	suffix := make([]byte, 1+8+len(member))
+	suffix[0] = zsetScoreTag
+	copy(suffix[1:], encodeZSetScore(score))
+	copy(suffix[9:], member)
+	return structureSubKey(key, version, suffix)
+}
+
+// zsetScorePrefix returns the common prefix of all score-ordered sub-keys of a sorted set.
+func zsetScorePrefix(key []byte, version uint64) []byte {
+	return structureSubKey(key, version, []byte{zsetScoreTag})
+}
+
+// encodeZSetScore encodes a score so that the byte order of the encodings matches the order of the scores.
+func encodeZSetScore(score float64) []byte {
+	if score == 0 {
+		// -0 and +0 are the same score
+		score = 0
+	}
+	bits := math.Float64bits(score)
+	if bits&(1<<63) == 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, bits)
+	return buf
+}
+
+// decodeZSetScore decodes a score encoded by encodeZSetScore.
+func decodeZSetScore(buf []byte) float64 {
+	bits := binary.BigEndian.Uint64(buf)
+	if bits&(1<<63) != 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
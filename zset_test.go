@@ -0,0 +1,126 @@
+package go_kv
+
+import (
+	"errors"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDB_ZSet(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-zset")
+	opts.DirPath = dir
+	opts.IndexType = Btree
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range []ZMember{
+		{Member: []byte("alice"), Score: 30},
+		{Member: []byte("bob"), Score: -2.5},
+		{Member: []byte("carol"), Score: 30},
+		{Member: []byte("dave"), Score: 100},
+		{Member: []byte("erin"), Score: 0},
+	} {
+		if isNew, err := db.ZAdd([]byte("board"), m.Score, m.Member); !isNew || err != nil {
+			t.Errorf("ZAdd(%s) got = %v, %v, want true", m.Member, isNew, err)
+		}
+	}
+	// updating a score moves the member
+	if isNew, _ := db.ZAdd([]byte("board"), 50, []byte("alice")); isNew {
+		t.Errorf("ZAdd() got = true, want false")
+	}
+	if _, err = db.ZAdd([]byte("board"), math.NaN(), []byte("nan")); !errors.Is(err, ErrScoreIsNaN) {
+		t.Errorf("ZAdd() error = %v, wantErr %v", err, ErrScoreIsNaN)
+	}
+
+	// the sorted set survives a restart
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destroyDB(db)
+
+	rangeTests := []struct {
+		name string
+		min  float64
+		max  float64
+		want []ZMember
+	}{
+		{
+			name: "all scores",
+			min:  math.Inf(-1),
+			max:  math.Inf(1),
+			want: []ZMember{
+				{Member: []byte("bob"), Score: -2.5},
+				{Member: []byte("erin"), Score: 0},
+				{Member: []byte("carol"), Score: 30},
+				{Member: []byte("alice"), Score: 50},
+				{Member: []byte("dave"), Score: 100},
+			},
+		},
+		{
+			name: "inclusive bounds",
+			min:  0,
+			max:  50,
+			want: []ZMember{
+				{Member: []byte("erin"), Score: 0},
+				{Member: []byte("carol"), Score: 30},
+				{Member: []byte("alice"), Score: 50},
+			},
+		},
+		{
+			name: "negative range",
+			min:  -10,
+			max:  -1,
+			want: []ZMember{{Member: []byte("bob"), Score: -2.5}},
+		},
+		{name: "empty range", min: 31, max: 49, want: nil},
+		{name: "min after max", min: 50, max: 0, want: nil},
+	}
+	for _, tt := range rangeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.ZRangeByScore([]byte("board"), tt.min, tt.max)
+			if err != nil {
+				t.Errorf("ZRangeByScore() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ZRangeByScore() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if score, _ := db.ZScore([]byte("board"), []byte("alice")); score != 50 {
+		t.Errorf("ZScore() got = %v, want 50", score)
+	}
+	if rank, _ := db.ZRank([]byte("board"), []byte("alice")); rank != 3 {
+		t.Errorf("ZRank() got = %d, want 3", rank)
+	}
+	if ok, _ := db.ZRem([]byte("board"), []byte("erin")); !ok {
+		t.Errorf("ZRem() got = false, want true")
+	}
+	if rank, _ := db.ZRank([]byte("board"), []byte("alice")); rank != 2 {
+		t.Errorf("ZRank() got = %d, want 2", rank)
+	}
+	if _, err = db.ZRank([]byte("board"), []byte("erin")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("ZRank() error = %v, wantErr %v", err, ErrKeyNotFound)
+	}
+}
+
+func Test_encodeZSetScore(t *testing.T) {
+	scores := []float64{math.Inf(-1), -1e300, -2.5, -1, math.Copysign(0, -1), 1e-300, 1, 2.5, 1e300, math.Inf(1)}
+	for i, score := range scores {
+		if got := decodeZSetScore(encodeZSetScore(score)); got != score {
+			t.Errorf("decodeZSetScore() got = %v, want %v", got, score)
+		}
+		if i > 0 && string(encodeZSetScore(scores[i-1])) >= string(encodeZSetScore(score)) {
+			t.Errorf("encodeZSetScore(%v) does not sort after encodeZSetScore(%v)", score, scores[i-1])
+		}
+	}
+}